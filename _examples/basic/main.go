@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+	"net/netip"
 	"os"
 	"time"
 
@@ -15,7 +16,7 @@ func main() {
 		Path:     "/zroot/jails/build",
 		Name:     "jailname",
 		Hostname: "hostname",
-		IP4:      "192.168.0.200",
+		IP4Addrs: []netip.Addr{netip.MustParseAddr("192.168.0.200")},
 		Chdir:    true,
 	}
 	jid, err := jail.Jail(o)
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/briandowns/jail"
+)
+
+func main() {
+	jails, err := jail.List(context.Background(), "jid", "host.hostname", "path")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%5s  %-20s %s\n", "JID", "Hostname", "Path")
+	for _, j := range jails {
+		fmt.Printf("%5d  %-20s %s\n", j.JID, j.Hostname, j.Path)
+	}
+}
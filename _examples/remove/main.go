@@ -9,27 +9,31 @@ import (
 )
 
 func main() {
-	o := &jail.Opts{
-		Version:  uint32(2),
-		Path:     "/zroot/jails/build",
-		Name:     "jailname",
-		Hostname: "hostname",
-		IP4:      "192.168.0.200",
-		Chdir:    true,
+	params := jail.NewParams()
+	params.Add("name", "jailname")
+	params.Add("path", "/zroot/jails/build")
+	params.Add("host.hostname", "hostname")
+	params.Add("ip4.addr", "192.168.0.200")
+	params.Add("persist", true)
+
+	// the jail is created without attaching this process to it, doing
+	// so would move every goroutine in to the jail.
+	if err := jail.Set(params, jail.CreateFlag); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
-	var j int32
-	go func(jid *int32) {
-		jailID, err := jail.Jail(o)
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
-		*jid = jailID
-		time.Sleep(30 * time.Second)
-	}(&j)
+
+	jid, err := jail.ID("jailname")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// here so a `jls` can be ran seperately to see that the jail is running
 	time.Sleep(5 * time.Second)
-	fmt.Printf("removing JID: %d\n", j)
-	if err := jail.Remove(j); err != nil {
+
+	fmt.Printf("removing JID: %d\n", jid)
+	if err := jail.Remove(jid); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
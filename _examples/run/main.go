@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/briandowns/jail"
+)
+
+func init() {
+	jail.Register(listRoot)
+}
+
+// listRoot is run in a child process attached to the jail.
+func listRoot() error {
+	entries, err := os.ReadDir("/")
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		fmt.Println(e.Name())
+	}
+
+	return nil
+}
+
+func main() {
+	jail.Init()
+
+	jid, err := jail.ID("jailname")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if err := jail.RunInJail(jid, listRoot); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,216 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package jail
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// Addresses are handed to the kernel as arrays of struct in_addr and
+// struct in6_addr. Both hold the address in network byte order, most
+// significant byte first, whatever the byte order of the host.
+const (
+	// InAddrLen is the size of a struct in_addr.
+	InAddrLen = net.IPv4len
+
+	// In6AddrLen is the size of a struct in6_addr.
+	In6AddrLen = net.IPv6len
+)
+
+// ParseAddr parses an IPv4 or IPv6 address. The address may be given
+// in CIDR notation, e.g. 192.0.2.1/24, as in jail.conf(5), in which
+// case the prefix length is ignored.
+func ParseAddr(s string) (netip.Addr, error) {
+	if strings.IndexByte(s, '/') != -1 {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Addr{}, fmt.Errorf("%w: %v", ErrInvalidValue, err)
+		}
+		return p.Addr(), nil
+	}
+
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("%w: %v", ErrInvalidValue, err)
+	}
+
+	return a, nil
+}
+
+// ToAddrs converts the given net.IP, netip.Addr, netip.Prefix or
+// string, or slice of any of them, in to a slice of netip.Addr.
+// Strings are parsed with ParseAddr. IPv4 addresses held by a
+// net.IP in its 16 byte form are returned as IPv4 addresses.
+func ToAddrs(v interface{}) ([]netip.Addr, error) {
+	var addrs []netip.Addr
+	switch v := v.(type) {
+	case netip.Addr:
+		addrs = []netip.Addr{v}
+	case []netip.Addr:
+		addrs = append(addrs, v...)
+	case netip.Prefix:
+		addrs = []netip.Addr{v.Addr()}
+	case []netip.Prefix:
+		addrs = make([]netip.Addr, len(v))
+		for i := range v {
+			addrs[i] = v[i].Addr()
+		}
+	case net.IP:
+		addrs = make([]netip.Addr, 1)
+		if !parseNetIP(v, &addrs[0]) {
+			return nil, fmt.Errorf("%w: invalid address %v", ErrInvalidValue, v)
+		}
+	case []net.IP:
+		addrs = make([]netip.Addr, len(v))
+		for i := range v {
+			if !parseNetIP(v[i], &addrs[i]) {
+				return nil, fmt.Errorf("%w: invalid address %v", ErrInvalidValue, v[i])
+			}
+		}
+	case string:
+		a, err := ParseAddr(v)
+		if err != nil {
+			return nil, err
+		}
+		addrs = []netip.Addr{a}
+	case []string:
+		addrs = make([]netip.Addr, len(v))
+		for i := range v {
+			a, err := ParseAddr(v[i])
+			if err != nil {
+				return nil, err
+			}
+			addrs[i] = a
+		}
+	default:
+		return nil, fmt.Errorf("%w: %T is not an address", ErrInvalidValue, v)
+	}
+
+	for _, a := range addrs {
+		if !a.IsValid() {
+			return nil, fmt.Errorf("%w: invalid address", ErrInvalidValue)
+		}
+	}
+
+	return addrs, nil
+}
+
+// parseNetIP converts the net.IP to a netip.Addr, reporting
+// whether it was valid.
+func parseNetIP(ip net.IP, addr *netip.Addr) bool {
+	a, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	if ip.To4() != nil {
+		a = a.Unmap()
+	}
+	*addr = a
+
+	return true
+}
+
+// AppendInAddr appends each of the IPv4 addresses to b as a struct
+// in_addr. IPv4-mapped IPv6 addresses are accepted and unmapped.
+func AppendInAddr(b []byte, addrs ...netip.Addr) ([]byte, error) {
+	for _, a := range addrs {
+		a = a.Unmap()
+		if !a.Is4() {
+			return nil, fmt.Errorf("%w: %s is not an IPv4 address", ErrInvalidValue, a)
+		}
+		a4 := a.As4()
+		b = append(b, a4[:]...)
+	}
+
+	return b, nil
+}
+
+// AppendIn6Addr appends each of the IPv6 addresses to b as a struct
+// in6_addr. IPv4-mapped IPv6 addresses are rejected as they cannot
+// be assigned to a jail.
+func AppendIn6Addr(b []byte, addrs ...netip.Addr) ([]byte, error) {
+	for _, a := range addrs {
+		if !a.Is6() || a.Is4In6() {
+			return nil, fmt.Errorf("%w: %s is not an IPv6 address", ErrInvalidValue, a)
+		}
+		a16 := a.As16()
+		b = append(b, a16[:]...)
+	}
+
+	return b, nil
+}
+
+// ParseInAddr parses an array of struct in_addr.
+func ParseInAddr(b []byte) ([]netip.Addr, error) {
+	return parseAddrs(b, InAddrLen)
+}
+
+// ParseIn6Addr parses an array of struct in6_addr.
+func ParseIn6Addr(b []byte) ([]netip.Addr, error) {
+	return parseAddrs(b, In6AddrLen)
+}
+
+// parseAddrs splits b in to addresses of the given size.
+func parseAddrs(b []byte, size int) ([]netip.Addr, error) {
+	if len(b)%size != 0 {
+		return nil, fmt.Errorf("%w: %d bytes is not a multiple of %d", ErrInvalidValue, len(b), size)
+	}
+
+	addrs := make([]netip.Addr, 0, len(b)/size)
+	for i := 0; i < len(b); i += size {
+		a, _ := netip.AddrFromSlice(b[i : i+size])
+		addrs = append(addrs, a)
+	}
+
+	return addrs, nil
+}
+
+// InAddrToUint32 returns the IPv4 address as an integer in host
+// byte order, the value ntohl(3) gives for its s_addr, so that
+// 192.0.2.1 is 0xc0000201.
+func InAddrToUint32(a netip.Addr) (uint32, error) {
+	a = a.Unmap()
+	if !a.Is4() {
+		return 0, fmt.Errorf("%w: %s is not an IPv4 address", ErrInvalidValue, a)
+	}
+	a4 := a.As4()
+
+	return binary.BigEndian.Uint32(a4[:]), nil
+}
+
+// Uint32ToInAddr is the inverse of InAddrToUint32.
+func Uint32ToInAddr(n uint32) netip.Addr {
+	var a4 [InAddrLen]byte
+	binary.BigEndian.PutUint32(a4[:], n)
+
+	return netip.AddrFrom4(a4)
+}
@@ -0,0 +1,274 @@
+package jail
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestParseAddr(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    netip.Addr
+		wantErr bool
+	}{
+		{name: "ipv4", s: "192.0.2.1", want: netip.MustParseAddr("192.0.2.1")},
+		{name: "ipv4 cidr", s: "192.0.2.1/24", want: netip.MustParseAddr("192.0.2.1")},
+		{name: "ipv6", s: "2001:db8::1", want: netip.MustParseAddr("2001:db8::1")},
+		{name: "ipv6 cidr", s: "2001:db8::1/64", want: netip.MustParseAddr("2001:db8::1")},
+		{name: "bad prefix", s: "192.0.2.1/33", wantErr: true},
+		{name: "short", s: "192.0.2", wantErr: true},
+		{name: "empty", s: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAddr(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAddr() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidValue) {
+				t.Errorf("ParseAddr() error = %v, want %v", err, ErrInvalidValue)
+			}
+			if got != tt.want {
+				t.Errorf("ParseAddr() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToAddrs(t *testing.T) {
+	v4 := netip.MustParseAddr("192.0.2.1")
+	v6 := netip.MustParseAddr("2001:db8::1")
+	tests := []struct {
+		name    string
+		v       interface{}
+		want    []netip.Addr
+		wantErr bool
+	}{
+		{name: "netip", v: v4, want: []netip.Addr{v4}},
+		{name: "netip slice", v: []netip.Addr{v4, v6}, want: []netip.Addr{v4, v6}},
+		{name: "prefix", v: netip.MustParsePrefix("192.0.2.1/24"), want: []netip.Addr{v4}},
+		{name: "net.IP", v: net.ParseIP("192.0.2.1"), want: []netip.Addr{v4}},
+		{name: "net.IP slice", v: []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")}, want: []netip.Addr{v4, v6}},
+		{name: "string", v: "2001:db8::1/64", want: []netip.Addr{v6}},
+		{name: "strings", v: []string{"192.0.2.1/24", "2001:db8::1"}, want: []netip.Addr{v4, v6}},
+		{name: "bad string", v: []string{"192.0.2.1", "nope"}, wantErr: true},
+		{name: "bad net.IP", v: net.IP{1, 2, 3}, wantErr: true},
+		{name: "zero addr", v: netip.Addr{}, wantErr: true},
+		{name: "wrong type", v: 42, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToAddrs(tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ToAddrs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ToAddrs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendInAddr(t *testing.T) {
+	tests := []struct {
+		name    string
+		addrs   []netip.Addr
+		want    []byte
+		wantErr bool
+	}{
+		{name: "none", want: nil},
+		{
+			name:  "network order",
+			addrs: []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("127.0.1.1")},
+			want:  []byte{192, 0, 2, 1, 127, 0, 1, 1},
+		},
+		{
+			name:  "mapped",
+			addrs: []netip.Addr{netip.MustParseAddr("::ffff:10.0.0.1")},
+			want:  []byte{10, 0, 0, 1},
+		},
+		{name: "ipv6", addrs: []netip.Addr{netip.MustParseAddr("::1")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AppendInAddr(nil, tt.addrs...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AppendInAddr() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("AppendInAddr() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendIn6Addr(t *testing.T) {
+	tests := []struct {
+		name    string
+		addrs   []netip.Addr
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:  "network order",
+			addrs: []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+			want:  []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+		},
+		{name: "ipv4", addrs: []netip.Addr{netip.MustParseAddr("192.0.2.1")}, wantErr: true},
+		{name: "mapped", addrs: []netip.Addr{netip.MustParseAddr("::ffff:10.0.0.1")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AppendIn6Addr(nil, tt.addrs...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AppendIn6Addr() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("AppendIn6Addr() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInAddr(t *testing.T) {
+	got, err := ParseInAddr([]byte{192, 0, 2, 1, 127, 0, 1, 1})
+	if err != nil {
+		t.Fatalf("ParseInAddr() error = %v", err)
+	}
+	want := []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("127.0.1.1")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseInAddr() = %v, want %v", got, want)
+	}
+
+	if _, err := ParseInAddr([]byte{1, 2, 3}); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ParseInAddr() error = %v, want %v", err, ErrInvalidValue)
+	}
+	if _, err := ParseIn6Addr(make([]byte, 20)); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ParseIn6Addr() error = %v, want %v", err, ErrInvalidValue)
+	}
+}
+
+func TestInAddrToUint32(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    netip.Addr
+		want    uint32
+		wantErr bool
+	}{
+		{name: "documentation", addr: netip.MustParseAddr("192.0.2.1"), want: 0xc0000201},
+		{name: "loopback", addr: netip.MustParseAddr("127.0.0.1"), want: 0x7f000001},
+		{name: "broadcast", addr: netip.MustParseAddr("255.255.255.255"), want: 0xffffffff},
+		{name: "mapped", addr: netip.MustParseAddr("::ffff:10.0.0.1"), want: 0x0a000001},
+		{name: "ipv6", addr: netip.MustParseAddr("::1"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InAddrToUint32(tt.addr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InAddrToUint32() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("InAddrToUint32() = %#x, want %#x", got, tt.want)
+			}
+			if err == nil && Uint32ToInAddr(got) != tt.addr.Unmap() {
+				t.Errorf("Uint32ToInAddr() = %v, want %v", Uint32ToInAddr(got), tt.addr.Unmap())
+			}
+		})
+	}
+}
+
+func FuzzInAddr(f *testing.F) {
+	f.Add([]byte{192, 0, 2, 1})
+	f.Add([]byte{0, 0, 0, 0, 255, 255, 255, 255})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		addrs, err := ParseInAddr(b)
+		if err != nil {
+			if len(b)%InAddrLen == 0 {
+				t.Fatalf("ParseInAddr(%v) error = %v", b, err)
+			}
+			return
+		}
+		got, err := AppendInAddr(nil, addrs...)
+		if err != nil {
+			t.Fatalf("AppendInAddr(%v) error = %v", addrs, err)
+		}
+		if !bytes.Equal(got, b) && len(b) != 0 {
+			t.Fatalf("round trip of %v = %v", b, got)
+		}
+		for i, a := range addrs {
+			n, err := InAddrToUint32(a)
+			if err != nil {
+				t.Fatalf("InAddrToUint32(%v) error = %v", a, err)
+			}
+			if Uint32ToInAddr(n) != a {
+				t.Fatalf("Uint32ToInAddr(%#x) = %v, want %v", n, Uint32ToInAddr(n), a)
+			}
+			if n>>24 != uint32(b[i*InAddrLen]) {
+				t.Fatalf("InAddrToUint32(%v) = %#x is not in network order", a, n)
+			}
+		}
+	})
+}
+
+func FuzzIn6Addr(f *testing.F) {
+	f.Add([]byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		addrs, err := ParseIn6Addr(b)
+		if err != nil {
+			return
+		}
+		got, err := AppendIn6Addr(nil, addrs...)
+		if err != nil {
+			for _, a := range addrs {
+				if a.Is4In6() {
+					return
+				}
+			}
+			t.Fatalf("AppendIn6Addr(%v) error = %v", addrs, err)
+		}
+		if !bytes.Equal(got, b) && len(b) != 0 {
+			t.Fatalf("round trip of %v = %v", b, got)
+		}
+	})
+}
+
+func FuzzParseAddr(f *testing.F) {
+	f.Add("192.0.2.1")
+	f.Add("192.0.2.1/24")
+	f.Add("2001:db8::1/64")
+	f.Add("fe80::1%em0")
+	f.Fuzz(func(t *testing.T, s string) {
+		a, err := ParseAddr(s)
+		if err != nil {
+			return
+		}
+		var b []byte
+		if a.Is4() {
+			b, err = AppendInAddr(nil, a)
+		} else if !a.Is4In6() && a.Zone() == "" {
+			b, err = AppendIn6Addr(nil, a)
+		} else {
+			return
+		}
+		if err != nil {
+			t.Fatalf("encoding %v error = %v", a, err)
+		}
+		addrs, err := ToAddrs(s)
+		if err != nil || len(addrs) != 1 || addrs[0] != a {
+			t.Fatalf("ToAddrs(%q) = %v, %v, want %v", s, addrs, err, a)
+		}
+		parse := ParseInAddr
+		if a.Is6() {
+			parse = ParseIn6Addr
+		}
+		got, err := parse(b)
+		if err != nil || len(got) != 1 || got[0] != a {
+			t.Fatalf("round trip of %v = %v, %v", a, got, err)
+		}
+	})
+}
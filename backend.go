@@ -0,0 +1,76 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package jail
+
+// Backend performs the jail system calls on behalf of the package level
+// functions. The default backend talks to the FreeBSD kernel. Other
+// implementations, such as the in-memory one in the jailtest package, can
+// be installed with SetBackend so code built on this package can be run
+// off of a FreeBSD host.
+//
+// Failed calls return the unix.Errno the kernel would. When the params
+// given to Set or Get hold an errmsg key, the kernel's error message is
+// written to it.
+type Backend interface {
+	// Jail creates a new jail as with jail(2) and returns its JID.
+	Jail(o *Opts) (int32, error)
+
+	// Set creates or modifies a jail as with jail_set(2) and returns
+	// the JID of the jail.
+	Set(params Params, flags uintptr) (int32, error)
+
+	// Get retrieves the parameters of a jail as with jail_get(2). The
+	// values found are written back in to params and the JID of the
+	// jail is returned.
+	Get(params Params, flags uintptr) (int32, error)
+
+	// Attach attaches the current process to the jail as with
+	// jail_attach(2).
+	Attach(jid int32) error
+
+	// Remove removes the jail as with jail_remove(2).
+	Remove(jid int32) error
+}
+
+// backend is the Backend used by the package level functions.
+var backend Backend = kernelBackend{}
+
+// SetBackend replaces the Backend used by the package level functions
+// and returns the one previously in use. Passing nil restores the kernel
+// backend. It is not safe to call SetBackend concurrently with the other
+// functions in this package.
+func SetBackend(b Backend) Backend {
+	if b == nil {
+		b = kernelBackend{}
+	}
+
+	prev := backend
+	backend = b
+
+	return prev
+}
@@ -0,0 +1,211 @@
+//go:build freebsd
+
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package jail
+
+import (
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// jail contains the data that will be passed into
+// the jail(2) syscall.
+type jail struct {
+	Version  uint32
+	Path     uintptr
+	Name     uintptr
+	Hostname uintptr
+	IP4s     uint32
+	IP6s     uint32
+	IP4      uintptr
+	IP6      uintptr
+}
+
+// Clone creates a new version of the previously created jail.
+func (j *jail) Clone() (int, error) {
+	nj := &jail{
+		Version:  j.Version,
+		Path:     j.Path,
+		Name:     j.Name,
+		Hostname: j.Hostname,
+	}
+
+	r1, _, e1 := unix.Syscall(sysJail, uintptr(unsafe.Pointer(nj)), 0, 0)
+	if e1 != 0 {
+		return 0, e1
+	}
+
+	return int(r1), nil
+}
+
+// kernelBackend is the Backend that performs the jail
+// system calls against the running kernel.
+type kernelBackend struct{}
+
+// Jail calls jail(2) with the given options.
+func (kernelBackend) Jail(o *Opts) (int32, error) {
+	jn, err := unix.BytePtrFromString(o.Name)
+	if err != nil {
+		return 0, err
+	}
+
+	jp, err := unix.BytePtrFromString(o.Path)
+	if err != nil {
+		return 0, err
+	}
+
+	hn, err := unix.BytePtrFromString(o.hostname())
+	if err != nil {
+		return 0, err
+	}
+
+	ip4, ip6, err := o.addrs()
+	if err != nil {
+		return 0, err
+	}
+
+	j := &jail{
+		Version:  o.Version,
+		Path:     uintptr(unsafe.Pointer(jp)),
+		Hostname: uintptr(unsafe.Pointer(hn)),
+		Name:     uintptr(unsafe.Pointer(jn)),
+		IP4s:     uint32(len(ip4)),
+		IP6s:     uint32(len(ip6)),
+	}
+
+	// The addresses are laid out as arrays of struct in_addr and
+	// struct in6_addr, both of which are in network byte order.
+	a4, err := encodeAddrs("ip4.addr", ip4)
+	if err != nil {
+		return 0, err
+	}
+	if len(a4) > 0 {
+		j.IP4 = uintptr(unsafe.Pointer(&a4[0]))
+	}
+	a6, err := encodeAddrs("ip6.addr", ip6)
+	if err != nil {
+		return 0, err
+	}
+	if len(a6) > 0 {
+		j.IP6 = uintptr(unsafe.Pointer(&a6[0]))
+	}
+
+	r1, _, e1 := unix.Syscall(sysJail, uintptr(unsafe.Pointer(j)), 0, 0)
+	runtime.KeepAlive(jn)
+	runtime.KeepAlive(jp)
+	runtime.KeepAlive(hn)
+	runtime.KeepAlive(a4)
+	runtime.KeepAlive(a6)
+	if e1 != 0 {
+		return 0, e1
+	}
+
+	return int32(r1), nil
+}
+
+// Set calls jail_set(2) with the given params and flags.
+func (kernelBackend) Set(params Params, flags uintptr) (int32, error) {
+	iov, err := params.buildIovec()
+	if err != nil {
+		return 0, err
+	}
+
+	jid, err := jailGetSet(sysJailSet, iov, flags)
+	if err != nil {
+		if _, ok := params["errmsg"]; ok {
+			params["errmsg"] = iov.errmsg()
+		}
+		return 0, err
+	}
+
+	return jid, nil
+}
+
+// Get calls jail_get(2) with the given params and flags and
+// writes the values returned back in to params.
+func (kernelBackend) Get(params Params, flags uintptr) (int32, error) {
+	iov, err := params.buildGetIovec()
+	if err != nil {
+		return 0, err
+	}
+
+	jid, err := jailGetSet(sysJailGet, iov, flags)
+	if err != nil {
+		if _, ok := params["errmsg"]; ok {
+			params["errmsg"] = iov.errmsg()
+		}
+		return 0, err
+	}
+
+	if err := params.decodeIovec(iov); err != nil {
+		return 0, err
+	}
+
+	return jid, nil
+}
+
+// jailGetSet performs the given syscall with the iovecs and flags provided.
+// The lengths reported back by the kernel are applied to the iovecs.
+func jailGetSet(call uintptr, iov iovecs, flags uintptr) (int32, error) {
+	uiov := iov.unix()
+	if len(uiov) == 0 {
+		uiov = make([]unix.Iovec, 1)
+	}
+	r1, _, e1 := unix.Syscall(call, uintptr(unsafe.Pointer(&uiov[0])), uintptr(2*len(iov)), flags)
+	runtime.KeepAlive(iov)
+	if e1 != 0 {
+		return 0, e1
+	}
+
+	iov.update(uiov)
+
+	return int32(r1), nil
+}
+
+// Attach calls jail_attach(2) for the given JID.
+func (kernelBackend) Attach(jid int32) error {
+	return jailAttachRemove(sysJailAttach, jid)
+}
+
+// Remove calls jail_remove(2) for the given JID.
+func (kernelBackend) Remove(jid int32) error {
+	return jailAttachRemove(sysJailRemove, jid)
+}
+
+// jailAttachRemove performs the given syscall with the JID provided.
+func jailAttachRemove(call uintptr, jid int32) error {
+	_, _, e1 := unix.Syscall(call, uintptr(jid), 0, 0)
+	if e1 != 0 {
+		return e1
+	}
+
+	return nil
+}
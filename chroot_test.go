@@ -0,0 +1,100 @@
+package jail
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func Test_resolveIn(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "passwd"), []byte("root:*:0:0::/:/bin/sh\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"etc", "usr/local/bin", "var/etc"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"etc/group", "usr/local/bin/python3.11"} {
+		if err := os.WriteFile(filepath.Join(root, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"usr/local/bin/python3": "python3.11",
+		"usr/local/bin/python":  "/usr/local/bin/python3",
+		"var/etc/group":         "../../../../etc/group",
+		"etc/passwd":            filepath.Join(outside, "passwd"),
+		"etc/master.passwd":     "/",
+		"etc/loop":              "loop",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr error
+	}{
+		{name: "file", path: "/etc/group", want: "/etc/group"},
+		{name: "root", path: "/", want: "/"},
+		{name: "relative link", path: "/usr/local/bin/python3", want: "/usr/local/bin/python3.11"},
+		{name: "absolute link", path: "/usr/local/bin/python", want: "/usr/local/bin/python3.11"},
+		{name: "dot dot above root", path: "/var/etc/group", want: "/etc/group"},
+		{name: "link to root", path: "/etc/master.passwd/etc/group", want: "/etc/group"},
+		{name: "link out of the jail", path: "/etc/passwd", wantErr: fs.ErrNotExist},
+		{name: "loop", path: "/etc/loop", wantErr: syscall.ELOOP},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveIn(root, tt.path)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("resolveIn() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveIn() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_openIn(t *testing.T) {
+	root := newJailRoot(t)
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "group"), []byte("wheel:*:0:app\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	group := filepath.Join(root, "etc", "group")
+	if err := os.Remove(group); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "group"), group); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := openIn(root, "/etc/group"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("openIn() error = %v, want %v", err, fs.ErrNotExist)
+	}
+	f, err := openIn(root, "/etc/passwd")
+	if err != nil {
+		t.Fatalf("openIn() error = %v", err)
+	}
+	f.Close()
+
+	groups, err := lookupGroups(root, "app", 1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 {
+		t.Errorf("lookupGroups() = %v, the host's group file should not be read", groups)
+	}
+}
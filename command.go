@@ -0,0 +1,449 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package jail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// defaultPath is the PATH searched in a jail when the command's
+// environment does not set one, that of the default login class.
+const defaultPath = "/sbin:/bin:/usr/sbin:/usr/bin:/usr/local/sbin:/usr/local/bin"
+
+// Cmd is a command run in a jail, the equivalent of jexec(8). It is
+// used as an exec.Cmd is: Stdin, Stdout, Stderr, Dir and Env of the
+// embedded exec.Cmd apply, with Dir taken relative to the jail's
+// root, and the exit status is reported by an *exec.ExitError.
+//
+// The child process is attached to the jail between fork and exec so
+// the calling process is left where it is.
+type Cmd struct {
+	*exec.Cmd
+
+	// JID is the jail the command is run in.
+	JID int32
+
+	// User is the user the command is run as, looked up in the
+	// jail's password and group databases. The command is run as
+	// the calling user if empty.
+	User string
+
+	// LoginClass is the class of the jail's login.conf(5) the
+	// command is run with. The environment and umask of the class
+	// are set as setusercontext(3) does, its variables taking
+	// precedence over those of Env. The umask of the calling
+	// process is that of the class while the command is started.
+	// Resource limits are not applied, rctl sets those for a jail.
+	LoginClass string
+
+	// Clean runs the command in a clean environment as jexec -l
+	// does. Only HOME, SHELL, USER and PATH, set for the user, and
+	// TERM are kept along with the variables of Env, and the
+	// command is run in the user's home directory unless Dir is
+	// set. The login class of the user, or LoginClass if set, is
+	// applied, the variables of Env taking precedence over it.
+	Clean bool
+
+	umask *int
+}
+
+// umaskMu serializes the changes of the process wide umask made to
+// start commands with the umask of their login class.
+var umaskMu sync.Mutex
+
+// Command returns the Cmd to run the named program with the given
+// arguments in the jail. A name without a slash is looked for in the
+// directories of the PATH, from Env or otherwise the default login
+// class, under the jail's root.
+func Command(jid int32, name string, args ...string) *Cmd {
+	return command(exec.Command(name, args...), jid, name)
+}
+
+// CommandContext is like Command but the process is killed when the
+// context is done before the command completes.
+func CommandContext(ctx context.Context, jid int32, name string, args ...string) *Cmd {
+	return command(exec.CommandContext(ctx, name, args...), jid, name)
+}
+
+// command undoes the host PATH lookup made by exec.Command, the
+// program being looked up in the jail on Start.
+func command(c *exec.Cmd, jid int32, name string) *Cmd {
+	c.Path, c.Err = name, nil
+
+	return &Cmd{Cmd: c, JID: jid}
+}
+
+// Start starts the command in the jail.
+func (c *Cmd) Start() error {
+	if c.JID <= 0 {
+		return fmt.Errorf("%w: jid %d", ErrNotFound, c.JID)
+	}
+
+	params := Params{"jid": c.JID, "path": ""}
+	if err := Get(params, 0); err != nil {
+		return err
+	}
+	root, _ := params["path"].(string)
+
+	cred, err := c.prepare(root)
+	if err != nil {
+		return err
+	}
+	if err := c.attach(cred); err != nil {
+		return err
+	}
+	if c.umask != nil {
+		umaskMu.Lock()
+		defer umaskMu.Unlock()
+		defer syscall.Umask(syscall.Umask(*c.umask))
+	}
+
+	return c.Cmd.Start()
+}
+
+// Run starts the command and waits for it to complete.
+func (c *Cmd) Run() error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+
+	return c.Wait()
+}
+
+// Output runs the command and returns its standard output. The
+// standard error is captured in the *exec.ExitError returned if
+// Stderr is nil.
+func (c *Cmd) Output() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+
+	var stdout, stderr bytes.Buffer
+	c.Stdout = &stdout
+	captured := c.Stderr == nil
+	if captured {
+		c.Stderr = &stderr
+	}
+
+	err := c.Run()
+	var ee *exec.ExitError
+	if captured && errors.As(err, &ee) {
+		ee.Stderr = stderr.Bytes()
+	}
+
+	return stdout.Bytes(), err
+}
+
+// CombinedOutput runs the command and returns its standard output
+// and standard error.
+func (c *Cmd) CombinedOutput() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+	if c.Stderr != nil {
+		return nil, errors.New("exec: Stderr already set")
+	}
+
+	var b bytes.Buffer
+	c.Stdout, c.Stderr = &b, &b
+	err := c.Run()
+
+	return b.Bytes(), err
+}
+
+// prepare resolves the program, user and environment of the command
+// against the jail rooted at root, as seen from the host. The files of
+// the jail are read through openIn and statIn so they are resolved as
+// they would be in the jail.
+func (c *Cmd) prepare(root string) (*syscall.Credential, error) {
+	var (
+		cred *syscall.Credential
+		pw   *passwd
+		err  error
+	)
+	switch {
+	case c.User != "":
+		if pw, err = lookupUser(root, c.User); err != nil {
+			return nil, err
+		}
+	case c.Clean:
+		pw, err = lookupUID(root, os.Getuid())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.User != "" {
+		groups, err := lookupGroups(root, pw.name, pw.gid)
+		if err != nil {
+			return nil, err
+		}
+		cred = &syscall.Credential{Uid: pw.uid, Gid: pw.gid, Groups: groups}
+	}
+
+	lc := &loginClass{}
+	if class := c.LoginClass; class != "" || c.Clean {
+		if pw == nil {
+			if pw, err = lookupUID(root, os.Getuid()); err != nil {
+				return nil, err
+			}
+		}
+		if class == "" {
+			class = lookupClass(root, pw)
+		}
+		lc, err = readLoginClass(root, class, pw)
+		switch {
+		case err == nil:
+			c.umask = lc.umask
+		case c.LoginClass == "" && errors.Is(err, os.ErrNotExist):
+			lc = &loginClass{}
+		default:
+			return nil, err
+		}
+	}
+
+	if c.Clean {
+		env := []string{
+			"HOME=" + pw.home,
+			"SHELL=" + pw.shell,
+			"USER=" + pw.name,
+			"PATH=" + defaultPath,
+		}
+		if term, ok := os.LookupEnv("TERM"); ok {
+			env = append(env, "TERM="+term)
+		}
+		c.Env = setEnv(setEnv(env, lc.env), c.Env)
+		if c.Dir == "" {
+			c.Dir = pw.home
+		}
+	} else if c.LoginClass != "" {
+		env := c.Env
+		if env == nil {
+			env = os.Environ()
+		}
+		c.Env = setEnv(env, lc.env)
+	}
+
+	path := c.Path
+	if !strings.Contains(path, "/") {
+		search := defaultPath
+		for _, kv := range c.Env {
+			if v, ok := strings.CutPrefix(kv, "PATH="); ok {
+				search = v
+			}
+		}
+		if path, err = lookPath(root, search, c.Path); err != nil {
+			return nil, err
+		}
+	}
+	c.Path = path
+
+	return cred, nil
+}
+
+// setEnv returns the environment with the variables of vars set,
+// replacing those of the same name.
+func setEnv(env, vars []string) []string {
+	env = slices.Clone(env)
+	for _, kv := range vars {
+		name, _, _ := strings.Cut(kv, "=")
+		i := slices.IndexFunc(env, func(e string) bool {
+			return strings.HasPrefix(e, name+"=")
+		})
+		if i < 0 {
+			env = append(env, kv)
+			continue
+		}
+		env[i] = kv
+	}
+
+	return env
+}
+
+// lookPath searches the directories of the PATH in the jail for the
+// named program, returning its path within the jail.
+func lookPath(root, search, name string) (string, error) {
+	for _, dir := range filepath.SplitList(search) {
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		fi, err := statIn(root, path)
+		if err == nil && fi.Mode().IsRegular() && fi.Mode().Perm()&0o111 != 0 {
+			return path, nil
+		}
+	}
+
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+// passwd is an entry of a jail's password database.
+type passwd struct {
+	name  string
+	uid   uint32
+	gid   uint32
+	home  string
+	shell string
+}
+
+// lookupUser returns the entry for the named user, or a numeric
+// user ID, from the jail's /etc/passwd.
+func lookupUser(root, name string) (*passwd, error) {
+	return findPasswd(root, func(pw *passwd) bool {
+		return pw.name == name || strconv.FormatUint(uint64(pw.uid), 10) == name
+	}, name)
+}
+
+// lookupUID returns the entry for the user ID from the jail's
+// /etc/passwd.
+func lookupUID(root string, uid int) (*passwd, error) {
+	return findPasswd(root, func(pw *passwd) bool {
+		return int(pw.uid) == uid
+	}, strconv.Itoa(uid))
+}
+
+// findPasswd returns the first entry of the jail's /etc/passwd
+// matched by fn.
+func findPasswd(root string, fn func(*passwd) bool, name string) (*passwd, error) {
+	var found *passwd
+	err := readDB(root, "/etc/passwd", func(fields []string) bool {
+		if len(fields) < 7 {
+			return true
+		}
+		uid, err1 := strconv.ParseUint(fields[2], 10, 32)
+		gid, err2 := strconv.ParseUint(fields[3], 10, 32)
+		if err1 != nil || err2 != nil {
+			return true
+		}
+		pw := &passwd{name: fields[0], uid: uint32(uid), gid: uint32(gid), home: fields[5], shell: fields[6]}
+		if fn(pw) {
+			found = pw
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("unknown user %s in jail", name)
+	}
+
+	return found, nil
+}
+
+// lookupClass returns the login class of the user from the jail's
+// /etc/master.passwd, or the root class for the superuser without one
+// as login_getpwclass(3) does. The default class is returned when the
+// file cannot be read.
+func lookupClass(root string, pw *passwd) string {
+	class := ""
+	readDB(root, "/etc/master.passwd", func(fields []string) bool {
+		if len(fields) < 10 || fields[0] != pw.name {
+			return true
+		}
+		class = fields[4]
+		return false
+	})
+	switch {
+	case class != "":
+		return class
+	case pw.uid == 0:
+		return "root"
+	}
+
+	return defaultClass
+}
+
+// lookupGroups returns the primary group along with the groups the
+// user is a member of in the jail's /etc/group.
+func lookupGroups(root, user string, gid uint32) ([]uint32, error) {
+	groups := []uint32{gid}
+	err := readDB(root, "/etc/group", func(fields []string) bool {
+		if len(fields) < 4 {
+			return true
+		}
+		id, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil || uint32(id) == gid {
+			return true
+		}
+		for _, m := range strings.Split(fields[3], ",") {
+			if m == user {
+				groups = append(groups, uint32(id))
+				break
+			}
+		}
+		return true
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// readDB calls fn with the colon separated fields of each entry of
+// the file in the jail rooted at root until it returns false.
+func readDB(root, name string, fn func([]string) bool) error {
+	f, err := openIn(root, name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return scanDB(f, fn)
+}
+
+// scanDB calls fn with the colon separated fields of each line read
+// from r, skipping comments and blank lines, until it returns false.
+func scanDB(r io.Reader, fn func([]string) bool) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if !fn(strings.Split(line, ":")) {
+			return nil
+		}
+	}
+
+	return s.Err()
+}
@@ -0,0 +1,225 @@
+package jail
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// newJailRoot creates the files of a jail's root used to prepare
+// commands.
+func newJailRoot(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	files := map[string]string{
+		"etc/passwd": `# comment
+root:*:0:0:Charlie &:/root:/bin/csh
+www:*:80:80:World Wide Web Owner:/nonexistent:/usr/sbin/nologin
+app:*:1001:1001:App:/home/app:/bin/sh
+`,
+		"etc/group": `wheel:*:0:root
+www:*:80:
+app:*:1001:
+staff:*:20:root,app
+video:*:44:app
+`,
+		"etc/master.passwd": `root:*:0:0::0:0:Charlie &:/root:/bin/csh
+www:*:80:80::0:0:World Wide Web Owner:/nonexistent:/usr/sbin/nologin
+app:*:1001:1001:staff:0:0:App:/home/app:/bin/sh
+`,
+		"etc/login.conf": `# login.conf
+default:\
+	:path=/sbin /bin /usr/sbin /usr/bin /usr/local/bin ~/bin:\
+	:setenv=BLOCKSIZE=K,PAGER=less:\
+	:umask=022:
+
+root:\
+	:path=/sbin /bin:\
+	:tc=default:
+
+staff:\
+	:lang=en_US.UTF-8:\
+	:umask=027:\
+	:tc=default:
+
+daemon|system daemons:\
+	:setenv=HOME=~,OWNER=$,LIST=a\\,b:\
+	:path@:\
+	:tc=default:
+`,
+		"bin/sh":             "",
+		"usr/local/bin/tool": "",
+		"usr/bin/data":       "",
+	}
+	for name, data := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		mode := os.FileMode(0o755)
+		if strings.HasPrefix(name, "etc/") || name == "usr/bin/data" {
+			mode = 0o644
+		}
+		if err := os.WriteFile(path, []byte(data), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func TestCmd_prepare(t *testing.T) {
+	root := newJailRoot(t)
+	t.Setenv("TERM", "xterm")
+
+	tests := []struct {
+		name      string
+		cmd       *Cmd
+		wantPath  string
+		wantArgs  []string
+		wantEnv   []string
+		wantDir   string
+		wantCred  *syscall.Credential
+		wantUmask *int
+		wantErr   bool
+	}{
+		{
+			name:     "absolute path",
+			cmd:      Command(1, "/bin/sh", "-c", "echo hi"),
+			wantPath: "/bin/sh",
+			wantArgs: []string{"/bin/sh", "-c", "echo hi"},
+		},
+		{
+			name:     "looked up in the jail",
+			cmd:      Command(1, "tool", "-v"),
+			wantPath: "/usr/local/bin/tool",
+			wantArgs: []string{"tool", "-v"},
+		},
+		{
+			name:    "not executable",
+			cmd:     Command(1, "data"),
+			wantErr: true,
+		},
+		{
+			name:    "not found",
+			cmd:     &Cmd{Cmd: &exec.Cmd{Path: "tool", Args: []string{"tool"}, Env: []string{"PATH=/bin"}}, JID: 1},
+			wantErr: true,
+		},
+		{
+			name:     "user",
+			cmd:      &Cmd{Cmd: exec.Command("/bin/sh"), JID: 1, User: "app"},
+			wantPath: "/bin/sh",
+			wantArgs: []string{"/bin/sh"},
+			wantCred: &syscall.Credential{Uid: 1001, Gid: 1001, Groups: []uint32{1001, 20, 44}},
+		},
+		{
+			name:     "numeric user",
+			cmd:      &Cmd{Cmd: exec.Command("/bin/sh"), JID: 1, User: "80"},
+			wantPath: "/bin/sh",
+			wantArgs: []string{"/bin/sh"},
+			wantCred: &syscall.Credential{Uid: 80, Gid: 80, Groups: []uint32{80}},
+		},
+		{
+			name:    "unknown user",
+			cmd:     &Cmd{Cmd: exec.Command("/bin/sh"), JID: 1, User: "nobody"},
+			wantErr: true,
+		},
+		{
+			name: "clean",
+			cmd: func() *Cmd {
+				c := Command(1, "tool")
+				c.User, c.Clean, c.Env = "app", true, []string{"LANG=C.UTF-8"}
+				return c
+			}(),
+			wantPath: "/usr/local/bin/tool",
+			wantArgs: []string{"tool"},
+			wantEnv: []string{
+				"HOME=/home/app",
+				"SHELL=/bin/sh",
+				"USER=app",
+				"PATH=/sbin:/bin:/usr/sbin:/usr/bin:/usr/local/bin:/home/app/bin",
+				"TERM=xterm",
+				"LANG=C.UTF-8",
+				"BLOCKSIZE=K",
+				"PAGER=less",
+			},
+			wantDir:   "/home/app",
+			wantCred:  &syscall.Credential{Uid: 1001, Gid: 1001, Groups: []uint32{1001, 20, 44}},
+			wantUmask: intPtr(0o27),
+		},
+		{
+			name: "login class",
+			cmd: func() *Cmd {
+				c := Command(1, "/bin/sh")
+				c.User, c.LoginClass, c.Env = "www", "daemon", []string{"PATH=/bin", "PAGER=more"}
+				return c
+			}(),
+			wantPath:  "/bin/sh",
+			wantArgs:  []string{"/bin/sh"},
+			wantEnv:   []string{"PATH=/bin", "PAGER=more", "HOME=/nonexistent", "OWNER=www", "LIST=a,b"},
+			wantCred:  &syscall.Credential{Uid: 80, Gid: 80, Groups: []uint32{80}},
+			wantUmask: intPtr(0o22),
+		},
+		{
+			name: "unknown login class",
+			cmd: func() *Cmd {
+				c := Command(1, "/bin/sh")
+				c.User, c.LoginClass, c.Env = "www", "nope", []string{}
+				return c
+			}(),
+			wantPath:  "/bin/sh",
+			wantArgs:  []string{"/bin/sh"},
+			wantEnv:   []string{"PATH=/sbin:/bin:/usr/sbin:/usr/bin:/usr/local/bin:/nonexistent/bin", "BLOCKSIZE=K", "PAGER=less"},
+			wantCred:  &syscall.Credential{Uid: 80, Gid: 80, Groups: []uint32{80}},
+			wantUmask: intPtr(0o22),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cred, err := tt.cmd.prepare(root)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Cmd.prepare() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tt.cmd.Path != tt.wantPath || !reflect.DeepEqual(tt.cmd.Args, tt.wantArgs) {
+				t.Errorf("Cmd.prepare() = %s %q, want %s %q", tt.cmd.Path, tt.cmd.Args, tt.wantPath, tt.wantArgs)
+			}
+			if !reflect.DeepEqual(tt.cmd.Env, tt.wantEnv) {
+				t.Errorf("Cmd.prepare() env = %q, want %q", tt.cmd.Env, tt.wantEnv)
+			}
+			if tt.cmd.Dir != tt.wantDir {
+				t.Errorf("Cmd.prepare() dir = %q, want %q", tt.cmd.Dir, tt.wantDir)
+			}
+			if !reflect.DeepEqual(cred, tt.wantCred) {
+				t.Errorf("Cmd.prepare() cred = %+v, want %+v", cred, tt.wantCred)
+			}
+			if !reflect.DeepEqual(tt.cmd.umask, tt.wantUmask) {
+				t.Errorf("Cmd.prepare() umask = %v, want %v", tt.cmd.umask, tt.wantUmask)
+			}
+		})
+	}
+}
+
+func TestCommandContext(t *testing.T) {
+	c := CommandContext(context.Background(), 1, "tool", "-v")
+	if c.Path != "tool" || c.Err != nil || c.JID != 1 {
+		t.Errorf("CommandContext() = %+v, want tool to be looked up in the jail", c)
+	}
+
+	if err := Command(0, "/bin/sh").Run(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Cmd.Run() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func intPtr(n int) *int {
+	return &n
+}
@@ -27,131 +27,210 @@
 
 package jail
 
-// errors in this file have been copied from https://github.com/freebsd/freebsd/blob/master/sys/sys/errno.h
+import (
+	"errors"
+	"strconv"
+	"strings"
 
-const (
-	eperm        = 1
-	enoent       = 2
-	efault       = 14
-	eexist       = 17
-	einval       = 22
-	eagain       = 35
-	enametoolong = 63
+	"golang.org/x/sys/unix"
 )
 
-// The jail() system call will fail with one of the below errors
-const (
-	// ErrJailPermDenied [EPERM] This process is not allowed to create a jail,
-	// either because it is not the super-user, or because it would exceed the
-	// jail's children.max limit.
-	ErrJailPermDenied = eperm
-
-	// ErrJailFaultOutsideOfAllocatedSpace [EFAULT] jail points to an address
-	// outside the allocated address space of the process.
-	ErrJailFaultOutsideOfAllocatedSpace = efault
-
-	// ErrJailInvalidVersion [EINVAL] The version number of the argument is not
-	// correct.
-	ErrJailInvalidVersion = einval
-
-	// ErrjailNoFreeJIDFound [EAGAIN] No free JID could be found.
-	ErrjailNoFreeJIDFound = eagain
-
-	// ErrJailNoSuchFileDirectory [ENOENT] No such file or directory.  A component of a specified pathname
-	// did not exist, or the pathname was   an empty string.
-	ErrJailNoSuchFileDirectory = enoent
-)
+// errors in this file have been copied from https://github.com/freebsd/freebsd/blob/master/sys/sys/errno.h
 
-// The jail_set() system call will fail with one of the below errors
 const (
-	// ErrJailSetPermDenied [EPERM] This process is not allowed to create a jail,
-	// either because it is not the super-user, or because it would exceed the
-	// jail's children.max limit.
-	ErrJailSetPermDenied = eperm
-
-	// ErrJailSetPermRestricted [EPERM] A jail parameter was set to a less restrictive
-	// value then the current environment.
-	ErrJailSetPermRestricted = eperm
-
-	// ErrJailSetFaultOutsideOfAllocatedSpace [EFAULT] Iov, or one of the addresses
-	// contained within it, points to an address outside the allocated address space
-	// of the process.
-	ErrJailSetFaultOutsideOfAllocatedSpace = efault
-
-	// ErrJailSetParamNotExist [ENOENT] The jail referred to by a jid or name parameter
-	// does not exist, and the JAIL_CREATE flag is not set.
-	ErrJailSetParamNotExist = enoent
-
-	// ErrJailSetNotAccessibleProcInDiffJail [ENOENT] The jail referred to by a jid
-	// is not accessible by the process, because the process is	in a different jail.
-	ErrJailSetNotAccessibleProcInDiffJail = enoent
-
-	// ErrJailSetUpdateFlagNotSet [EEXIST] The jail referred to by a jid or name
-	// parameter exists, and the JAIL_UPDATE flag is not set.
-	ErrJailSetUpdateFlagNotSet = eexist
-
-	// Einval [EINVAL] A supplied parameter is the wrong size.
-	ErrJailSetParamWrongSize = einval
-
-	// ErrJailSetParamOutOfRange [EINVAL] A supplied parameter is out of range.
-	ErrJailSetParamOutOfRange = einval
-
-	// ErrJailSetStringNotNullTerminated [EINVAL] A supplied string parameter is
-	// not null-terminated.
-	ErrJailSetStringNotNullTerminated = einval
-
-	// ErrJailSetUnknownParam [EINVAL] A supplied parameter name does not match
-	// any known parameters.
-	ErrJailSetUnknownParam = einval
-
-	// ErrJailSetCreateOrUpdateNotSet [EINVAL] One of the JAIL_CREATE or JAIL_UPDATE
-	// flags is not set.
-	ErrJailSetCreateOrUpdateNotSet = einval
-
-	// ErrJailSetNameTooLong [ENAMETOOLONG] A supplied string parameter is longer
-	// than allowed.
-	ErrJailSetNameTooLong = enametoolong
-
-	// ErrJailSetNoIDsLeft [EAGAIN] There are no jail IDs left.
-	ErrJailSetNoIDsLeft = eagain
+	eperm        = unix.Errno(1)
+	enoent       = unix.Errno(2)
+	efault       = unix.Errno(14)
+	eexist       = unix.Errno(17)
+	einval       = unix.Errno(22)
+	eagain       = unix.Errno(35)
+	enametoolong = unix.Errno(63)
 )
 
-// The jail_get() system call will fail with one of the below errors
-const (
-	// ErrJailGetFaultOutsideOfAllocatedSpace [EFAULT] Iov, or	one of the addresses
-	// contained within it, points to an address outside the allocated address space
-	// of the process.
-	ErrJailGetFaultOutsideOfAllocatedSpace = efault
-
-	// ErrJailGetNotExist [ENOENT] The jail referred to by jid or name parameter
-	// does not exist.
-	ErrJailGetNotExist = enoent
-
-	// ErrJailGetNotAccessibleProcInDiffJail [ENOENT] The jail referred to by a
-	// jid is not accessible by the process, because the process is in a different
-	// jail.
-	ErrJailGetNotAccessibleProcInDiffJail = enoent
-
-	// [ENOENT] The lastjid parameter is greater than the highest current jail ID.
-	ErrJailGetParamHigherThanCurJID = enoent
-
-	// ErrJailGetParamWrongSize [EINVAL] A supplied parameter is the wrong size.
-	ErrJailGetParamWrongSize = einval
-
-	// ErrJailGetUnknownParam [EINVAL] A supplied parameter name does not match
+var (
+	// ErrPermission [EPERM] The process is not allowed to create, modify,
+	// attach to or remove a jail, either because it is not the super-user,
+	// because it would exceed the jail's children.max limit or because a
+	// parameter was set to a less restrictive value than the current
+	// environment.
+	ErrPermission = errors.New("not allowed or restricted")
+
+	// ErrFault [EFAULT] The jail or iovec given, or one of the addresses
+	// contained within it, points to an address outside the allocated
+	// address space of the process.
+	ErrFault = errors.New("fault outside of allocated space")
+
+	// ErrInvalidVersion [EINVAL] The version number given to jail(2) is
+	// not correct.
+	ErrInvalidVersion = errors.New("invalid version")
+
+	// ErrNoJIDs [EAGAIN] There are no free jail IDs left.
+	ErrNoJIDs = errors.New("no JID's left")
+
+	// ErrPathNotFound [ENOENT] A component of the path given to jail(2)
+	// did not exist, or the path was an empty string.
+	ErrPathNotFound = errors.New("no such file or directory")
+
+	// ErrNotFound [ENOENT, EINVAL] The jail referred to either does not
+	// exist or is not accessible by the process because the process is in
+	// a different jail. This is also returned by jail_get(2) when the
+	// lastjid parameter is greater than the highest current jail ID and
+	// by jail_attach(2) and jail_remove(2) when the JID does not exist.
+	ErrNotFound = errors.New("jail referred to either does not exist or is inaccessible")
+
+	// ErrExists [EEXIST] The jail referred to by a jid or name parameter
+	// exists and the UpdateFlag is not set.
+	ErrExists = errors.New("jail already exists")
+
+	// ErrUnknownParam [EINVAL] A supplied parameter name does not match
 	// any known parameters.
-	ErrJailGetUnknownParam = einval
-)
+	ErrUnknownParam = errors.New("unknown parameter")
 
-// The jail_attach() and jail_remove() system calls will fail with either of the
-// below errors
-const (
-	// ErrJailAttachUnprivilegedUser [EPERM] A user other than the super-user
-	// attempted to attach to or remove a jail.
-	ErrJailAttachUnprivilegedUser = eperm
+	// ErrInvalidValue [EINVAL] A supplied parameter is the wrong size, is
+	// out of range or is a string that is not NUL terminated, or neither
+	// the CreateFlag nor the UpdateFlag is set.
+	ErrInvalidValue = errors.New("invalid param provided")
 
-	// ErrjailAttachJIDNotExist [EINVAL] The jail specified by jid does not exist.
-	ErrjailAttachJIDNotExist = einval
+	// ErrNameTooLong [ENAMETOOLONG] A supplied string parameter is longer
+	// than allowed.
+	ErrNameTooLong = errors.New("name too long")
 )
 
+// errnoErrors maps the errno values each system call fails with to the
+// error describing them. EINVAL from jail_set(2) and jail_get(2) is
+// resolved to ErrUnknownParam or ErrInvalidValue by the kernel message.
+var errnoErrors = map[string]map[unix.Errno]error{
+	"jail": {
+		eperm:  ErrPermission,
+		efault: ErrFault,
+		einval: ErrInvalidVersion,
+		eagain: ErrNoJIDs,
+		enoent: ErrPathNotFound,
+	},
+	"jail_set": {
+		eperm:        ErrPermission,
+		efault:       ErrFault,
+		enoent:       ErrNotFound,
+		eexist:       ErrExists,
+		einval:       ErrInvalidValue,
+		enametoolong: ErrNameTooLong,
+		eagain:       ErrNoJIDs,
+	},
+	"jail_get": {
+		efault: ErrFault,
+		enoent: ErrNotFound,
+		einval: ErrInvalidValue,
+	},
+	"jail_attach": {
+		eperm:  ErrPermission,
+		einval: ErrNotFound,
+	},
+	"jail_remove": {
+		eperm:  ErrPermission,
+		einval: ErrNotFound,
+	},
+}
+
+// ErrMsgLen is the size of the buffer the kernel's error
+// message is retrieved in to.
 const ErrMsgLen = 1024
+
+// syscallNames maps the jail system calls to their names.
+var syscallNames = map[int]string{
+	sysJail:       "jail",
+	sysJailAttach: "jail_attach",
+	sysJailGet:    "jail_get",
+	sysJailSet:    "jail_set",
+	sysJailRemove: "jail_remove",
+}
+
+// JailError records a failed jail system call along with the jail
+// it was made for and the message the kernel gave for the failure.
+// It wraps both the errno and the sentinel error, such as ErrNotFound,
+// describing it so either can be matched with errors.Is.
+type JailError struct {
+	// Op is the operation being performed, such as create or get.
+	Op string
+
+	// Syscall is the name of the system call that failed.
+	Syscall string
+
+	// Errno is the error number returned by the system call.
+	Errno unix.Errno
+
+	// Err is the sentinel error the errno maps to for the system
+	// call, or nil if it is not one the call is documented to return.
+	Err error
+
+	// Name and JID identify the jail the call was made for
+	// when they are known.
+	Name string
+	JID  int32
+
+	// Msg is the message the kernel placed in the errmsg
+	// parameter, if any.
+	Msg string
+}
+
+// newJailError converts the error returned from the given syscall in to
+// a *JailError. Errors other than a unix.Errno are returned unchanged.
+func newJailError(op string, call int, err error, name string, jid int32, msg string) error {
+	if err == nil {
+		return nil
+	}
+
+	var e1 unix.Errno
+	if !errors.As(err, &e1) {
+		return err
+	}
+
+	syscall := syscallNames[call]
+	sentinel := errnoErrors[syscall][e1]
+	if e1 == einval && strings.HasPrefix(msg, "unknown parameter") &&
+		(syscall == "jail_set" || syscall == "jail_get") {
+		sentinel = ErrUnknownParam
+	}
+
+	return &JailError{
+		Op:      op,
+		Syscall: syscall,
+		Errno:   e1,
+		Err:     sentinel,
+		Name:    name,
+		JID:     jid,
+		Msg:     msg,
+	}
+}
+
+// Error returns the operation, jail and the kernel's message, or a
+// description of the errno when the kernel did not provide one.
+func (e *JailError) Error() string {
+	var jail string
+	switch {
+	case e.Name != "":
+		jail = " " + e.Name
+	case e.JID != 0:
+		jail = " " + strconv.Itoa(int(e.JID))
+	}
+
+	msg := e.Msg
+	if msg == "" {
+		if e.Err != nil {
+			msg = e.Err.Error()
+		} else {
+			msg = "errno " + strconv.Itoa(int(e.Errno))
+		}
+	}
+
+	return e.Op + jail + ": " + e.Syscall + ": " + msg
+}
+
+// Unwrap returns the errno and the sentinel error so errors.Is
+// can be used to match either.
+func (e *JailError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Errno}
+	}
+
+	return []error{e.Errno, e.Err}
+}
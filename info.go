@@ -0,0 +1,218 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package jail
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+)
+
+// JailInfo holds the parameters of a jail in typed fields. It is
+// converted from and to Params by NewJailInfo and JailInfo.Params
+// without loss: parameters without a field of their own are kept in
+// Extra and fields taken from Params are given back even when zero.
+type JailInfo struct {
+	JID           int32
+	Name          string
+	Path          string
+	Hostname      string
+	IP4           []netip.Addr
+	IP6           []netip.Addr
+	Securelevel   int32
+	ChildrenMax   int32
+	EnforceStatfs int32
+	Persist       bool
+	VNet          JailSys
+	OSRelease     string
+	Parent        int32
+	Meta          string
+	Env           string
+
+	// Allow holds the allow.* parameters by the rest of their
+	// name, e.g. Allow["mount.zfs"] for allow.mount.zfs.
+	Allow map[string]bool
+
+	// Extra holds the parameters without a field of their own.
+	Extra Params
+
+	// has records the fields that were taken from Params.
+	has map[string]bool
+}
+
+// infoFields maps the parameters with a field in JailInfo to that
+// field.
+var infoFields = []struct {
+	name  string
+	field func(*JailInfo) interface{}
+}{
+	{"jid", func(j *JailInfo) interface{} { return &j.JID }},
+	{"name", func(j *JailInfo) interface{} { return &j.Name }},
+	{"path", func(j *JailInfo) interface{} { return &j.Path }},
+	{"host.hostname", func(j *JailInfo) interface{} { return &j.Hostname }},
+	{"ip4.addr", func(j *JailInfo) interface{} { return &j.IP4 }},
+	{"ip6.addr", func(j *JailInfo) interface{} { return &j.IP6 }},
+	{"securelevel", func(j *JailInfo) interface{} { return &j.Securelevel }},
+	{"children.max", func(j *JailInfo) interface{} { return &j.ChildrenMax }},
+	{"enforce_statfs", func(j *JailInfo) interface{} { return &j.EnforceStatfs }},
+	{"persist", func(j *JailInfo) interface{} { return &j.Persist }},
+	{"vnet", func(j *JailInfo) interface{} { return &j.VNet }},
+	{"osrelease", func(j *JailInfo) interface{} { return &j.OSRelease }},
+	{"parent", func(j *JailInfo) interface{} { return &j.Parent }},
+	{"meta", func(j *JailInfo) interface{} { return &j.Meta }},
+	{"env", func(j *JailInfo) interface{} { return &j.Env }},
+}
+
+// infoField returns the field of the JailInfo held by the named
+// parameter, or nil if it has none.
+func (j *JailInfo) infoField(name string) interface{} {
+	for _, f := range infoFields {
+		if f.name == name {
+			return f.field(j)
+		}
+	}
+
+	return nil
+}
+
+// allowName returns the key of the allow.* parameter in the Allow
+// map. Parameters given with a "no" prefix are kept in Extra.
+func allowName(name string, jp JailParam) (string, bool) {
+	if !strings.HasPrefix(name, "allow.") || jp.Flags&JailParamNoBool != 0 {
+		return "", false
+	}
+	if _, ok := jp.Zero().(bool); !ok {
+		return "", false
+	}
+
+	return strings.TrimPrefix(name, "allow."), true
+}
+
+// NewJailInfo converts the params to a JailInfo. Values are converted
+// to the type of their field with JailParam.Convert. Parameters with
+// a nil value, as given to Get, are kept in Extra.
+func NewJailInfo(params Params) (*JailInfo, error) {
+	j := &JailInfo{has: make(map[string]bool)}
+
+	for k, v := range params {
+		jp, ok := LookupParam(k)
+		if !ok || v == nil {
+			j.addExtra(k, v)
+			continue
+		}
+		field := j.infoField(k)
+		allow, isAllow := allowName(k, jp)
+		if field == nil && !isAllow {
+			j.addExtra(k, v)
+			continue
+		}
+
+		cv, err := jp.Convert(v)
+		if err != nil {
+			return nil, err
+		}
+		if isAllow {
+			if j.Allow == nil {
+				j.Allow = make(map[string]bool)
+			}
+			j.Allow[allow] = cv.(bool)
+			continue
+		}
+		reflect.ValueOf(field).Elem().Set(reflect.ValueOf(cv))
+		j.has[k] = true
+	}
+
+	return j, nil
+}
+
+// addExtra keeps the parameter in Extra.
+func (j *JailInfo) addExtra(k string, v interface{}) {
+	if j.Extra == nil {
+		j.Extra = NewParams()
+	}
+	j.Extra[k] = v
+}
+
+// Params converts the JailInfo to Params. Fields are included when
+// they are not their zero value or were taken from Params by
+// NewJailInfo.
+func (j *JailInfo) Params() Params {
+	params := make(Params, len(infoFields)+len(j.Allow)+len(j.Extra))
+	for k, v := range j.Extra {
+		params[k] = v
+	}
+	for _, f := range infoFields {
+		rv := reflect.ValueOf(f.field(j)).Elem()
+		if rv.IsZero() && !j.has[f.name] {
+			continue
+		}
+		params[f.name] = rv.Interface()
+	}
+	for k, v := range j.Allow {
+		params["allow."+k] = v
+	}
+
+	return params
+}
+
+// GetInfo returns the parameters of the jail with the given JID that
+// JailInfo has fields for.
+func GetInfo(jid int32) (*JailInfo, error) {
+	params := NewParams()
+	for _, f := range infoFields {
+		if _, ok := LookupParam(f.name); ok {
+			params[f.name] = nil
+		}
+	}
+	for _, name := range ParamNames() {
+		jp, _ := LookupParam(name)
+		if _, ok := allowName(name, jp); ok {
+			params[name] = nil
+		}
+	}
+	params["jid"] = jid
+
+	if err := Get(params, 0); err != nil {
+		return nil, err
+	}
+
+	return NewJailInfo(params)
+}
+
+// Set creates or updates the jail with the parameters of the JailInfo
+// as Set does. Read-only parameters, such as parent, are left out.
+func (j *JailInfo) Set(flags uintptr) error {
+	params := j.Params()
+	for k := range params {
+		if jp, ok := LookupParam(k); ok && jp.ReadOnly() {
+			delete(params, k)
+		}
+	}
+
+	return Set(params, flags)
+}
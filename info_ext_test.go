@@ -0,0 +1,53 @@
+package jail_test
+
+import (
+	"errors"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/briandowns/jail"
+	"github.com/briandowns/jail/jailtest"
+)
+
+func TestGetInfo(t *testing.T) {
+	b := jailtest.New()
+	defer jail.SetBackend(jail.SetBackend(b))
+
+	in := &jail.JailInfo{
+		Name:        "web",
+		Path:        "/jails/web",
+		Hostname:    "web.example.org",
+		IP4:         []netip.Addr{netip.MustParseAddr("192.0.2.10")},
+		Securelevel: 2,
+		Persist:     true,
+		Allow:       map[string]bool{"mount": true, "raw_sockets": false},
+	}
+	if err := in.Set(jail.CreateFlag); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := jail.GetInfo(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.JID != 1 || got.Name != in.Name || got.Path != in.Path || got.Hostname != in.Hostname ||
+		!reflect.DeepEqual(got.IP4, in.IP4) || got.Securelevel != 2 || !got.Persist {
+		t.Errorf("GetInfo() = %+v, want %+v", got, in)
+	}
+	if !got.Allow["mount"] || got.Allow["raw_sockets"] || got.Allow["mount.zfs"] {
+		t.Errorf("GetInfo().Allow = %v", got.Allow)
+	}
+
+	got.Securelevel = 3
+	if err := got.Set(jail.UpdateFlag); err != nil {
+		t.Fatalf("JailInfo.Set() error = %v", err)
+	}
+	if got, err = jail.GetInfo(1); err != nil || got.Securelevel != 3 {
+		t.Errorf("GetInfo() = %+v, %v, want securelevel 3", got, err)
+	}
+
+	if _, err := jail.GetInfo(2); !errors.Is(err, jail.ErrNotFound) {
+		t.Errorf("GetInfo() error = %v, want %v", err, jail.ErrNotFound)
+	}
+}
@@ -0,0 +1,124 @@
+package jail
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestNewJailInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  Params
+		want    *JailInfo
+		wantErr bool
+	}{
+		{
+			name: "typed fields",
+			params: Params{
+				"jid":               int32(3),
+				"name":              "web",
+				"path":              "/jails/web",
+				"host.hostname":     "web.example.org",
+				"ip4.addr":          "192.0.2.10",
+				"ip6.addr":          []netip.Addr{netip.MustParseAddr("2001:db8::10")},
+				"securelevel":       2,
+				"children.max":      int32(0),
+				"enforce_statfs":    uint8(1),
+				"persist":           true,
+				"vnet":              "new",
+				"osrelease":         "14.3-RELEASE",
+				"parent":            int32(0),
+				"meta":              "tier=web",
+				"env":               "",
+				"allow.mount":       true,
+				"allow.nomount.zfs": true,
+				"devfs_ruleset":     int32(4),
+				"lastjid":           nil,
+			},
+			want: &JailInfo{
+				JID:           3,
+				Name:          "web",
+				Path:          "/jails/web",
+				Hostname:      "web.example.org",
+				IP4:           []netip.Addr{netip.MustParseAddr("192.0.2.10")},
+				IP6:           []netip.Addr{netip.MustParseAddr("2001:db8::10")},
+				Securelevel:   2,
+				EnforceStatfs: 1,
+				Persist:       true,
+				VNet:          JailSysNew,
+				OSRelease:     "14.3-RELEASE",
+				Meta:          "tier=web",
+				Allow:         map[string]bool{"mount": true},
+				Extra:         Params{"allow.nomount.zfs": true, "devfs_ruleset": int32(4), "lastjid": nil},
+			},
+		},
+		{
+			name:    "wrong type",
+			params:  Params{"path": 1},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewJailInfo(tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewJailInfo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got.has = nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewJailInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJailInfo_Params(t *testing.T) {
+	tests := []struct {
+		name string
+		info *JailInfo
+		want Params
+	}{
+		{
+			name: "zero fields left out",
+			info: &JailInfo{Name: "web", Securelevel: -1, Allow: map[string]bool{"mount": false}},
+			want: Params{"name": "web", "securelevel": int32(-1), "allow.mount": false},
+		},
+		{
+			name: "extra",
+			info: &JailInfo{Name: "web", Extra: Params{"devfs_ruleset": int32(4)}},
+			want: Params{"name": "web", "devfs_ruleset": int32(4)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.info.Params(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("JailInfo.Params() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJailInfo_roundTrip(t *testing.T) {
+	params := Params{
+		"jid":           int32(1),
+		"name":          "web",
+		"securelevel":   int32(0),
+		"persist":       false,
+		"ip4.addr":      []netip.Addr{netip.MustParseAddr("192.0.2.10")},
+		"vnet":          JailSysDisable,
+		"allow.mount":   false,
+		"devfs_ruleset": int32(0),
+	}
+
+	info, err := NewJailInfo(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Params(); !reflect.DeepEqual(got, params) {
+		t.Errorf("JailInfo.Params() = %v, want %v", got, params)
+	}
+}
@@ -0,0 +1,419 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package jail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"reflect"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// iovec is a single parameter in the form handed to jail_set(2) and
+// jail_get(2). Each becomes two entries in the iovec array passed to the
+// kernel, the first holding the NUL terminated name and the second the
+// value. A nil value is passed as an empty iovec.
+type iovec struct {
+	name  []byte
+	value []byte
+}
+
+// iovecs is the list of parameters for a single call.
+type iovecs []iovec
+
+// maxStringLen is the size of the buffer used to retrieve
+// string parameters without a known maximum length.
+const maxStringLen = 1024
+
+// maxAFIPs is the number of addresses per family a jail may hold
+// as per the default value of security.jail.jail_max_af_ips.
+const maxAFIPs = 255
+
+// lookupParams are the parameters jail_get(2) reads to find
+// the jail to report on.
+var lookupParams = map[string]bool{
+	"jid":     true,
+	"lastjid": true,
+	"name":    true,
+}
+
+// buildIovec takes the containing map value and builds out the
+// name/value pairs for each parameter. The pairs are ordered by
+// name so the result is deterministic. Boolean parameters are sent
+// with an empty value under their plain name when true and their
+// "no" prefixed name when false, e.g. allow.mount or allow.nomount.
+// An errmsg parameter is given a buffer of ErrMsgLen bytes for the
+// kernel to fill in.
+func (p Params) buildIovec() (iovecs, error) {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	iov := make(iovecs, 0, len(p))
+	for _, k := range keys {
+		if k == "errmsg" {
+			iov = append(iov, iovec{name: []byte("errmsg\x00"), value: make([]byte, ErrMsgLen)})
+			continue
+		}
+
+		v, n := p[k], k
+		if jp, ok := LookupParam(k); ok && v != nil {
+			var err error
+			if v, err = jp.Convert(v); err != nil {
+				return nil, err
+			}
+			if b, ok := v.(bool); ok {
+				n, v = jp.boolName(b), true
+			}
+		}
+
+		name, err := encodeString(n)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := encodeValue(k, v)
+		if err != nil {
+			return nil, err
+		}
+
+		iov = append(iov, iovec{name: name, value: value})
+	}
+
+	return iov, nil
+}
+
+// buildGetIovec builds out the name/value pairs for a jail_get(2) call.
+// Each value is a buffer large enough to hold the parameter as returned
+// by the kernel, with the values of the parameters used to look up the
+// jail copied in to the front of theirs. An errmsg buffer is always
+// included so the kernel's error message can be retrieved.
+func (p Params) buildGetIovec() (iovecs, error) {
+	keys := make([]string, 0, len(p)+1)
+	for k := range p {
+		keys = append(keys, k)
+	}
+	if _, ok := p["errmsg"]; !ok {
+		keys = append(keys, "errmsg")
+	}
+	sort.Strings(keys)
+
+	iov := make(iovecs, 0, len(keys))
+	for _, k := range keys {
+		name, err := encodeString(k)
+		if err != nil {
+			return nil, err
+		}
+
+		like, size := p.outType(k)
+		if like == nil {
+			return nil, errors.New("unknown value type for key: " + k)
+		}
+
+		var in []byte
+		if lookupParams[k] {
+			if in, err = encodeValue(k, p[k]); err != nil {
+				return nil, err
+			}
+		}
+		value := make([]byte, max(size, len(in)))
+		copy(value, in)
+
+		iov = append(iov, iovec{name: name, value: value})
+	}
+
+	return iov, nil
+}
+
+// outType returns a value of the Go type the named parameter is
+// decoded in to along with the size of buffer needed to hold it.
+// Known parameters use the type and size from their description,
+// others take the type of the value they were given.
+func (p Params) outType(k string) (interface{}, int) {
+	if jp, ok := LookupParam(k); ok {
+		return jp.Zero(), jp.ValueLen
+	}
+
+	like := p[k]
+	switch like.(type) {
+	case nil:
+		return nil, 0
+	case []byte:
+		return like, maxStringLen
+	case net.IP, netip.Addr, []net.IP, []netip.Addr:
+		if strings.HasPrefix(k, "ip6") {
+			return like, maxAFIPs * net.IPv6len
+		}
+		return like, maxAFIPs * net.IPv4len
+	}
+
+	switch reflect.ValueOf(like).Kind() {
+	case reflect.String:
+		return like, maxStringLen
+	case reflect.Int64, reflect.Uint64:
+		return like, 8
+	}
+
+	return like, 4
+}
+
+// decodeIovec writes the values returned by the kernel in the pairs
+// back in to p, converting each to the parameter's Go type.
+func (p Params) decodeIovec(iov iovecs) error {
+	for _, v := range iov {
+		k := string(bytes.TrimSuffix(v.name, []byte{0}))
+		if _, ok := p[k]; !ok {
+			continue
+		}
+
+		like, _ := p.outType(k)
+		val, err := decodeValue(k, v.value, like)
+		if err != nil {
+			return err
+		}
+		p[k] = val
+	}
+
+	return nil
+}
+
+// errmsg returns the message the kernel wrote in to the
+// errmsg buffer, if any.
+func (iov iovecs) errmsg() string {
+	for _, v := range iov {
+		if string(v.name) == "errmsg\x00" {
+			if i := bytes.IndexByte(v.value, 0); i != -1 {
+				return string(v.value[:i])
+			}
+			return string(v.value)
+		}
+	}
+
+	return ""
+}
+
+// update trims each value to the length the kernel reported
+// for it in the given iovec array after a jail_get(2) call.
+func (iov iovecs) update(uiov []unix.Iovec) {
+	for i := range iov {
+		if n := int(uiov[2*i+1].Len); n <= cap(iov[i].value) {
+			iov[i].value = iov[i].value[:n]
+		}
+	}
+}
+
+// unix lays the pairs out as the alternating name and value
+// unix.Iovec array expected by the kernel. The returned slice
+// references the pairs' buffers so they have to be kept alive
+// until the system call has returned.
+func (iov iovecs) unix() []unix.Iovec {
+	uiov := make([]unix.Iovec, 0, 2*len(iov))
+	for _, v := range iov {
+		uiov = append(uiov, newIovec(v.name), newIovec(v.value))
+	}
+
+	return uiov
+}
+
+// newIovec returns a unix.Iovec describing the given buffer.
+func newIovec(b []byte) unix.Iovec {
+	var iov unix.Iovec
+	if len(b) > 0 {
+		iov.Base = &b[0]
+		iov.SetLen(len(b))
+	}
+
+	return iov
+}
+
+// encodeString converts the given string to a NUL terminated
+// byte slice.
+func encodeString(s string) ([]byte, error) {
+	if strings.IndexByte(s, 0) != -1 {
+		return nil, errors.New("string contains NUL byte: " + s)
+	}
+
+	return append([]byte(s), 0), nil
+}
+
+// encodeValue converts the value of the named parameter in to the
+// bytes the kernel expects. Integers are laid out in host byte order
+// with int, uint and smaller types sent as 32 bit C ints, strings are
+// NUL terminated, booleans are sent as empty values and IP addresses
+// as arrays of in_addr or in6_addr in network byte order.
+func encodeValue(k string, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	case net.IP, []net.IP, netip.Addr, []netip.Addr:
+		return encodeAddrs(k, v)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return encodeString(rv.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		n := rv.Int()
+		if int64(int32(n)) != n {
+			return nil, fmt.Errorf("value out of range for key: %s", k)
+		}
+		return binary.NativeEndian.AppendUint32(nil, uint32(n)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		n := rv.Uint()
+		if uint64(uint32(n)) != n {
+			return nil, fmt.Errorf("value out of range for key: %s", k)
+		}
+		return binary.NativeEndian.AppendUint32(nil, uint32(n)), nil
+	case reflect.Int64:
+		return binary.NativeEndian.AppendUint64(nil, uint64(rv.Int())), nil
+	case reflect.Uint64:
+		return binary.NativeEndian.AppendUint64(nil, rv.Uint()), nil
+	case reflect.Bool:
+		if !rv.Bool() {
+			return nil, errors.New("false boolean value passed in for key: " + k)
+		}
+		return nil, nil
+	}
+
+	return nil, errors.New("invalid value passed in for key: " + k)
+}
+
+// encodeAddrs converts the given addresses in to an array of in_addr
+// or in6_addr depending on whether the parameter is an ip4 or ip6 one.
+func encodeAddrs(k string, v interface{}) ([]byte, error) {
+	addrs, err := ToAddrs(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address passed in for key: %s: %w", k, err)
+	}
+
+	if strings.HasPrefix(k, "ip6") {
+		return AppendIn6Addr(make([]byte, 0, len(addrs)*In6AddrLen), addrs...)
+	}
+
+	return AppendInAddr(make([]byte, 0, len(addrs)*InAddrLen), addrs...)
+}
+
+// decodeValue converts the bytes returned by the kernel for the named
+// parameter in to a value of the same type as like.
+func decodeValue(k string, b []byte, like interface{}) (interface{}, error) {
+	switch like.(type) {
+	case []byte:
+		return append([]byte(nil), b...), nil
+	case net.IP, []net.IP, netip.Addr, []netip.Addr:
+		return decodeAddrs(k, b, like)
+	}
+
+	rv := reflect.ValueOf(like)
+	out := reflect.New(rv.Type()).Elem()
+	switch rv.Kind() {
+	case reflect.String:
+		if i := bytes.IndexByte(b, 0); i != -1 {
+			b = b[:i]
+		}
+		out.SetString(string(b))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		if len(b) != 4 {
+			return nil, fmt.Errorf("value of wrong size for key: %s", k)
+		}
+		out.SetInt(int64(int32(binary.NativeEndian.Uint32(b))))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		if len(b) != 4 {
+			return nil, fmt.Errorf("value of wrong size for key: %s", k)
+		}
+		out.SetUint(uint64(binary.NativeEndian.Uint32(b)))
+	case reflect.Int64:
+		if len(b) != 8 {
+			return nil, fmt.Errorf("value of wrong size for key: %s", k)
+		}
+		out.SetInt(int64(binary.NativeEndian.Uint64(b)))
+	case reflect.Uint64:
+		if len(b) != 8 {
+			return nil, fmt.Errorf("value of wrong size for key: %s", k)
+		}
+		out.SetUint(binary.NativeEndian.Uint64(b))
+	case reflect.Bool:
+		switch len(b) {
+		case 0:
+			out.SetBool(true)
+		case 4:
+			out.SetBool(binary.NativeEndian.Uint32(b) != 0)
+		default:
+			return nil, fmt.Errorf("value of wrong size for key: %s", k)
+		}
+	default:
+		return nil, errors.New("invalid value passed in for key: " + k)
+	}
+
+	return out.Interface(), nil
+}
+
+// decodeAddrs converts an array of in_addr or in6_addr in to
+// addresses of the same type as like.
+func decodeAddrs(k string, b []byte, like interface{}) (interface{}, error) {
+	parse := ParseInAddr
+	if strings.HasPrefix(k, "ip6") {
+		parse = ParseIn6Addr
+	}
+	addrs, err := parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("value of wrong size for key: %s", k)
+	}
+
+	switch like.(type) {
+	case netip.Addr:
+		if len(addrs) == 0 {
+			return netip.Addr{}, nil
+		}
+		return addrs[0], nil
+	case net.IP:
+		if len(addrs) == 0 {
+			return net.IP(nil), nil
+		}
+		return net.IP(addrs[0].AsSlice()), nil
+	case []net.IP:
+		ips := make([]net.IP, len(addrs))
+		for i, a := range addrs {
+			ips[i] = net.IP(a.AsSlice())
+		}
+		return ips, nil
+	}
+
+	return addrs, nil
+}
@@ -0,0 +1,336 @@
+package jail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+func Test_encodeValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   interface{}
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:  "string",
+			key:   "name",
+			value: "web",
+			want:  []byte("web\x00"),
+		},
+		{
+			name:  "empty string",
+			key:   "path",
+			value: "",
+			want:  []byte{0},
+		},
+		{
+			name:    "string with NUL",
+			key:     "name",
+			value:   "w\x00b",
+			wantErr: true,
+		},
+		{
+			name:  "int",
+			key:   "jid",
+			value: 7,
+			want:  binary.NativeEndian.AppendUint32(nil, 7),
+		},
+		{
+			name:  "negative int32",
+			key:   "securelevel",
+			value: int32(-1),
+			want:  binary.NativeEndian.AppendUint32(nil, 0xffffffff),
+		},
+		{
+			name:    "int out of range",
+			key:     "jid",
+			value:   1 << 40,
+			wantErr: true,
+		},
+		{
+			name:  "uint32",
+			key:   "devfs_ruleset",
+			value: uint32(4),
+			want:  binary.NativeEndian.AppendUint32(nil, 4),
+		},
+		{
+			name:  "int64",
+			key:   "host.hostid",
+			value: int64(1) << 40,
+			want:  binary.NativeEndian.AppendUint64(nil, 1<<40),
+		},
+		{
+			name:  "bool",
+			key:   "persist",
+			value: true,
+			want:  nil,
+		},
+		{
+			name:  "ipv4",
+			key:   "ip4.addr",
+			value: []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("127.0.1.1")},
+			want:  []byte{192, 0, 2, 1, 127, 0, 1, 1},
+		},
+		{
+			name:  "ipv4 net.IP",
+			key:   "ip4.addr",
+			value: net.ParseIP("10.0.0.1"),
+			want:  []byte{10, 0, 0, 1},
+		},
+		{
+			name:  "ipv6",
+			key:   "ip6.addr",
+			value: netip.MustParseAddr("2001:db8::1"),
+			want:  []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+		},
+		{
+			name:    "ipv6 in ip4.addr",
+			key:     "ip4.addr",
+			value:   netip.MustParseAddr("2001:db8::1"),
+			wantErr: true,
+		},
+		{
+			name:    "ipv4 in ip6.addr",
+			key:     "ip6.addr",
+			value:   []net.IP{net.ParseIP("10.0.0.1")},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported",
+			key:     "name",
+			value:   1.5,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeValue(tt.key, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("encodeValue() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("encodeValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_decodeValue_roundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value interface{}
+	}{
+		{name: "string", key: "name", value: "web"},
+		{name: "int", key: "jid", value: 12},
+		{name: "int32", key: "securelevel", value: int32(-1)},
+		{name: "uint32", key: "devfs_ruleset", value: uint32(5)},
+		{name: "int64", key: "host.hostid", value: int64(-5)},
+		{name: "uint64", key: "host.hostid", value: uint64(1) << 63},
+		{name: "bool", key: "persist", value: true},
+		{name: "jailsys", key: "vnet", value: JailSysNew},
+		{name: "raw", key: "meta", value: []byte{1, 2, 3}},
+		{
+			name:  "ipv4",
+			key:   "ip4.addr",
+			value: []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("127.0.1.1")},
+		},
+		{
+			name:  "ipv6",
+			key:   "ip6.addr",
+			value: []netip.Addr{netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("::1")},
+		},
+		{name: "single ipv6", key: "ip6.addr", value: netip.MustParseAddr("fe80::1")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := encodeValue(tt.key, tt.value)
+			if err != nil {
+				t.Fatalf("encodeValue() error = %v", err)
+			}
+			got, err := decodeValue(tt.key, b, tt.value)
+			if err != nil {
+				t.Fatalf("decodeValue() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.value) {
+				t.Errorf("decodeValue() = %#v, want %#v", got, tt.value)
+			}
+		})
+	}
+}
+
+func Test_decodeValue_wrongSize(t *testing.T) {
+	if _, err := decodeValue("jid", []byte{1, 2}, int32(0)); err == nil {
+		t.Errorf("decodeValue() of short int should fail")
+	}
+	if _, err := decodeValue("ip4.addr", []byte{1, 2, 3}, []netip.Addr(nil)); err == nil {
+		t.Errorf("decodeValue() of short address should fail")
+	}
+}
+
+func TestParams_buildIovec(t *testing.T) {
+	p := Params{
+		"persist":     true,
+		"allow.mount": false,
+		"name":        "web",
+		"jid":         int32(3),
+	}
+
+	iov, err := p.buildIovec()
+	if err != nil {
+		t.Fatalf("Params.buildIovec() error = %v", err)
+	}
+
+	want := iovecs{
+		{name: []byte("allow.nomount\x00"), value: nil},
+		{name: []byte("jid\x00"), value: binary.NativeEndian.AppendUint32(nil, 3)},
+		{name: []byte("name\x00"), value: []byte("web\x00")},
+		{name: []byte("persist\x00"), value: nil},
+	}
+	if !reflect.DeepEqual(iov, want) {
+		t.Fatalf("Params.buildIovec() = %q, want %q", iov, want)
+	}
+
+	uiov := iov.unix()
+	if len(uiov) != 2*len(want) {
+		t.Fatalf("iovecs.unix() has %d entries, want %d", len(uiov), 2*len(want))
+	}
+	for i, v := range want {
+		for j, b := range [][]byte{v.name, v.value} {
+			u := uiov[2*i+j]
+			if int(u.Len) != len(b) {
+				t.Errorf("iovec %d length = %d, want %d", 2*i+j, u.Len, len(b))
+			}
+			if len(b) == 0 {
+				if u.Base != nil {
+					t.Errorf("iovec %d base should be nil for an empty value", 2*i+j)
+				}
+				continue
+			}
+			if got := unsafe.Slice(u.Base, u.Len); !bytes.Equal(got, b) {
+				t.Errorf("iovec %d = %q, want %q", 2*i+j, got, b)
+			}
+		}
+	}
+}
+
+func TestParams_buildGetIovec(t *testing.T) {
+	p := Params{
+		"name":     "web",
+		"jid":      0,
+		"path":     nil,
+		"ip4.addr": []netip.Addr(nil),
+		"persist":  false,
+	}
+
+	iov, err := p.buildGetIovec()
+	if err != nil {
+		t.Fatalf("Params.buildGetIovec() error = %v", err)
+	}
+
+	want := map[string]int{
+		"errmsg":   ErrMsgLen,
+		"ip4.addr": maxAFIPs * net.IPv4len,
+		"jid":      4,
+		"name":     256,
+		"path":     1024,
+		"persist":  4,
+	}
+	if len(iov) != len(want) {
+		t.Fatalf("Params.buildGetIovec() has %d pairs, want %d", len(iov), len(want))
+	}
+	for _, v := range iov {
+		k := string(bytes.TrimSuffix(v.name, []byte{0}))
+		if len(v.value) != want[k] {
+			t.Errorf("%s buffer is %d bytes, want %d", k, len(v.value), want[k])
+		}
+		if k == "name" && !bytes.HasPrefix(v.value, []byte("web\x00")) {
+			t.Errorf("name buffer does not hold the name being looked up")
+		}
+	}
+
+	if _, err := (Params{"foo": nil}).buildGetIovec(); err == nil {
+		t.Errorf("Params.buildGetIovec() of unknown param without a value should fail")
+	}
+}
+
+func TestParams_decodeIovec(t *testing.T) {
+	p := Params{
+		"name":     "web",
+		"jid":      0,
+		"path":     nil,
+		"ip4.addr": []netip.Addr(nil),
+		"persist":  false,
+		"meta":     "",
+	}
+
+	iov, err := p.buildGetIovec()
+	if err != nil {
+		t.Fatalf("Params.buildGetIovec() error = %v", err)
+	}
+
+	// fill the buffers the way the kernel would.
+	uiov := iov.unix()
+	for i, v := range iov {
+		var out []byte
+		switch string(v.name) {
+		case "jid\x00":
+			out = binary.NativeEndian.AppendUint32(nil, 9)
+		case "path\x00":
+			out = []byte("/jails/web\x00")
+		case "ip4.addr\x00":
+			out = []byte{192, 0, 2, 1, 127, 0, 1, 1}
+		case "persist\x00":
+			out = binary.NativeEndian.AppendUint32(nil, 1)
+		case "meta\x00":
+			out = []byte("tag=1\x00")
+		default:
+			continue
+		}
+		copy(v.value, out)
+		uiov[2*i+1].SetLen(len(out))
+	}
+	iov.update(uiov)
+
+	if err := p.decodeIovec(iov); err != nil {
+		t.Fatalf("Params.decodeIovec() error = %v", err)
+	}
+
+	want := Params{
+		"name":     "web",
+		"jid":      int32(9),
+		"path":     "/jails/web",
+		"ip4.addr": []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("127.0.1.1")},
+		"persist":  true,
+		"meta":     "tag=1",
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("Params.decodeIovec() = %v, want %v", p, want)
+	}
+	if _, ok := p["errmsg"]; ok {
+		t.Errorf("errmsg should not be added to params")
+	}
+}
+
+func Test_iovecs_errmsg(t *testing.T) {
+	iov, err := Params{"name": "web"}.buildGetIovec()
+	if err != nil {
+		t.Fatalf("Params.buildGetIovec() error = %v", err)
+	}
+	if got := iov.errmsg(); got != "" {
+		t.Errorf("iovecs.errmsg() = %q, want empty", got)
+	}
+
+	copy(iov[0].value, "jail \"web\" not found\x00")
+	if got := iov.errmsg(); got != `jail "web" not found` {
+		t.Errorf("iovecs.errmsg() = %q", got)
+	}
+}
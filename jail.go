@@ -27,20 +27,19 @@
 
 // build +FreeBSD
 
-// Package jail provides the ability to lock a process
-// or Goroutine into a FreeBSD jail.
+// Package jail provides the ability to create, manage and lock a
+// process into a FreeBSD jail. Attaching to a jail applies to the
+// whole process, every goroutine included; use Command or RunInJail
+// to run code in a jail from a process that stays on the host.
 package jail
 
 import (
-	"encoding/binary"
 	"errors"
 	"fmt"
-	"net"
+	"maps"
+	"net/netip"
 	"os"
 	"reflect"
-	"unsafe"
-
-	"golang.org/x/sys/unix"
 )
 
 const EtcdConfigFile = "/etc/jail.conf"
@@ -85,19 +84,6 @@ const jailAPIVersion uint32 = 2
 // for the system.
 const MaxChildJails int64 = 999999
 
-// jail contains the data that will be passed into
-// the jail(2) syscall.
-type jail struct {
-	Version  uint32
-	Path     uintptr
-	Name     uintptr
-	Hostname uintptr
-	IP4s     uint32
-	IP6s     uint32
-	IP4      uintptr
-	IP6      uintptr
-}
-
 // Opts holds the options to be passed in to
 // create the new jail.
 type Opts struct {
@@ -105,37 +91,27 @@ type Opts struct {
 	Path     string
 	Name     string
 	Hostname string
-	IP4      string
-	Chdir    bool
-}
 
-const (
-	JailRawValue    = 0x01
-	JailBool        = 0x02
-	JailParamNoBool = 0x04
-	JailParamSys    = 0x80
-)
+	// IP4 is a single IPv4 address given as a string.
+	//
+	// Deprecated: use IP4Addrs.
+	IP4 string
 
-// JailParam
-type JailParam struct {
-	Name       string
-	Value      interface{}
-	ValueLen   int
-	ElemLen    int
-	CtlType    int
-	StructType int
-	Flags      int
-}
+	// IP4Addrs and IP6Addrs hold the addresses the jail is
+	// restricted to, the first of each being its primary address.
+	IP4Addrs []netip.Addr
+	IP6Addrs []netip.Addr
 
-// typedef uint32_t in_addr_t
-type inAddrT uint32
+	// VNet gives the jail its own network stack. Its interfaces are
+	// provisioned with the vnet package rather than through IP4Addrs
+	// and IP6Addrs.
+	VNet bool
 
-// inAddr
-type inAddr struct {
-	sAddr inAddrT
+	Chdir bool
 }
 
-// validate makes sure the required fields are present.
+// validate makes sure the required fields are present and
+// that the options make for a valid set of parameters.
 func (o *Opts) validate() error {
 	if o.Path == "" {
 		return errors.New("missing path")
@@ -144,61 +120,83 @@ func (o *Opts) validate() error {
 		return errors.New("missing name")
 	}
 
-	return nil
+	params, err := o.Params()
+	if err != nil {
+		return err
+	}
+
+	return params.Validate()
 }
 
-// Jail takes the given parameters, validates, and creates a new jail.
-func Jail(o *Opts) (int32, error) {
-	if err := o.validate(); err != nil {
-		return 0, err
+// addrs returns the IPv4 and IPv6 addresses of the jail, with
+// the deprecated IP4 field, if set, first.
+func (o *Opts) addrs() ([]netip.Addr, []netip.Addr, error) {
+	ip4 := o.IP4Addrs
+	if o.IP4 != "" {
+		a, err := ParseAddr(o.IP4)
+		if err != nil {
+			return nil, nil, err
+		}
+		ip4 = append([]netip.Addr{a}, o.IP4Addrs...)
 	}
-
-	jn, err := unix.BytePtrFromString(o.Name)
-	if err != nil {
-		return 0, err
+	for _, a := range ip4 {
+		if !a.Is4() {
+			return nil, nil, fmt.Errorf("%w: non IPv4 address %s", ErrInvalidValue, a)
+		}
+	}
+	for _, a := range o.IP6Addrs {
+		if !a.Is6() || a.Is4In6() {
+			return nil, nil, fmt.Errorf("%w: non IPv6 address %s", ErrInvalidValue, a)
+		}
 	}
 
-	jp, err := unix.BytePtrFromString(o.Path)
-	if err != nil {
-		return 0, err
+	return ip4, o.IP6Addrs, nil
+}
+
+// hostname returns the hostname of the jail, which defaults
+// to its name.
+func (o *Opts) hostname() string {
+	if o.Hostname != "" {
+		return o.Hostname
 	}
 
-	hn, err := unix.BytePtrFromString(o.Name)
+	return o.Name
+}
+
+// Params returns the jail parameters equivalent to the options,
+// as created by jail(2), for use with Set.
+func (o *Opts) Params() (Params, error) {
+	ip4, ip6, err := o.addrs()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	j := &jail{
-		Version:  o.Version,
-		Path:     uintptr(unsafe.Pointer(jp)),
-		Hostname: uintptr(unsafe.Pointer(hn)),
-		Name:     uintptr(unsafe.Pointer(jn)),
+	params := NewParams()
+	params["path"] = o.Path
+	params["name"] = o.Name
+	params["host.hostname"] = o.hostname()
+	if len(ip4) > 0 {
+		params["ip4.addr"] = ip4
 	}
-	if o.IP4 != "" {
-		uint32ip := ip2int(net.ParseIP(o.IP4))
-		ia := &inAddr{
-			sAddr: inAddrT(uint32ip),
-		}
-		j.IP4s = 1
-		j.IP6s = uint32(0)
-		j.IP4 = uintptr(unsafe.Pointer(ia))
+	if len(ip6) > 0 {
+		params["ip6.addr"] = ip6
+	}
+	if o.VNet {
+		params["vnet"] = JailSysNew
 	}
 
-	r1, _, e1 := unix.Syscall(sysJail, uintptr(unsafe.Pointer(j)), 0, 0)
-	if e1 != 0 {
-		switch int(e1) {
-		case ErrJailPermDenied:
-			return 0, fmt.Errorf("unprivileged user: %d", e1)
-		case ErrJailFaultOutsideOfAllocatedSpace:
-			return 0, fmt.Errorf("fault outside of allocation space: %d", e1)
-		case ErrJailInvalidVersion:
-			return 0, fmt.Errorf("invalid version: %d", e1)
-		case ErrjailNoFreeJIDFound:
-			return 0, fmt.Errorf("no free JID found: %d", e1)
-		case ErrJailNoSuchFileDirectory:
-			return 0, fmt.Errorf("No such file or directory: %s\n", o.Path)
-		}
-		return 0, fmt.Errorf("%d", e1)
+	return params, nil
+}
+
+// Jail takes the given parameters, validates, and creates a new jail.
+func Jail(o *Opts) (int32, error) {
+	if err := o.validate(); err != nil {
+		return 0, err
+	}
+
+	jid, err := o.create()
+	if err != nil {
+		return 0, err
 	}
 
 	if o.Chdir {
@@ -207,70 +205,64 @@ func Jail(o *Opts) (int32, error) {
 		}
 	}
 
-	return int32(r1), nil
+	return jid, nil
 }
 
-// Clone creates a new version of the previously created jail.
-func (j *jail) Clone() (int, error) {
-	nj := &jail{
-		Version:  j.Version,
-		Path:     j.Path,
-		Name:     j.Name,
-		Hostname: j.Hostname,
+// create creates the jail and attaches the process to it. jail(2)
+// cannot create a jail with its own network stack so jail_set(2) is
+// used in its place for VNET jails.
+func (o *Opts) create() (int32, error) {
+	if !o.VNet {
+		jid, err := backend.Jail(o)
+		return jid, newJailError("create", sysJail, err, o.Name, 0, "")
 	}
 
-	r1, _, e1 := unix.Syscall(sysJail, uintptr(unsafe.Pointer(nj)), 0, 0)
-	if e1 != 0 {
-		return 0, fmt.Errorf("%d", e1)
+	params, err := o.Params()
+	if err != nil {
+		return 0, err
 	}
+	params["errmsg"] = ""
+	jid, err := backend.Set(params, CreateFlag|AttachFlag)
 
-	return int(r1), nil
+	return jid, getSet("create", sysJailSet, params, err)
 }
 
-// ID returns the JID of the corresponding jail.
+// ID returns the JID of the corresponding jail. ErrNotFound is
+// returned if there is no such jail.
 func ID(name string) (int32, error) {
 	params := NewParams()
 	params.Add("name", name)
+	params.Add("jid", int32(0))
 
 	if err := Get(params, 0); err != nil {
 		return -1, err
 	}
 
-	return params["jid"].(int32), nil
+	jid, ok := params["jid"].(int32)
+	if !ok || jid == 0 {
+		return -1, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	return jid, nil
 }
 
-// Name returns the name of the corresponding jail.
+// Name returns the name of the corresponding jail. ErrNotFound
+// is returned if there is no such jail.
 func Name(id int32) (string, error) {
 	params := NewParams()
 	params.Add("jid", id)
+	params.Add("name", "")
 
 	if err := Get(params, 0); err != nil {
 		return "", err
 	}
 
-	return params["name"].(string), nil
-}
-
-// validParams contains a list of the valid parameters that
-// are allowed to be used when calling the Set or Get functions.
-// TODO(briandowns) add more as they are identified.
-var validParams = []string{
-	"jid",
-	"name",
-	"dying",
-	"persist",
-	"nopersist",
-}
-
-// isValidParam verifies that the given parameter is valid.
-func isValidParam(param string) bool {
-	for _, p := range validParams {
-		if param == p {
-			return true
-		}
+	name, ok := params["name"].(string)
+	if !ok || name == "" {
+		return "", fmt.Errorf("%w: %d", ErrNotFound, id)
 	}
 
-	return false
+	return name, nil
 }
 
 // Params contains the individual settings passed in to either get
@@ -283,164 +275,118 @@ func NewParams() Params {
 	return make(map[string]interface{})
 }
 
-// Add adds the given key and value to the params map.
+// Add adds the given key and value to the params map. The key must
+// be a known parameter and the value is converted to the parameter's
+// Go type. A nil value can be given for parameters to be retrieved
+// with Get.
 func (p Params) Add(k string, v interface{}) error {
 	if p == nil {
 		return errors.New("cannot assign values to nil map")
 	}
 
-	if !isValidParam(k) {
-		return errors.New("invalid parameter: " + k)
+	jp, ok := LookupParam(k)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownParam, k)
 	}
 
-	if _, ok := p[k]; !ok {
-		p[k] = v
-		return nil
+	if _, ok := p[k]; ok {
+		return fmt.Errorf("key of %q already set with value of %v", k, p[k])
 	}
 
-	return fmt.Errorf("key of %q already set with value of %v", k, p[k])
-}
-
-// Validate is used to make sure that the params assigned
-// are indeed correct and usable. This has been exposed for
-// a caller to do validation as well as the package interally.
-func (p Params) Validate() error {
-	return nil
-}
-
-// buildIovec takes the containing map value and builds
-// out a slice of syscall.Iovec.
-func (p Params) buildIovec() ([]unix.Iovec, error) {
-	iovec := make([]unix.Iovec, len(p))
-	var itr int
-
-	for k, v := range p {
-		ib, err := unix.BytePtrFromString(k)
+	if v != nil {
+		cv, err := jp.Convert(v)
 		if err != nil {
-			return nil, err
-		}
-
-		rv := reflect.ValueOf(v)
-		var size uint64
-
-		switch rv.Kind() {
-		case reflect.String:
-			s := string(rv.String())
-			size = uint64(unsafe.Sizeof(s))
-		case reflect.Int, reflect.Int8, reflect.Int16,
-			reflect.Int32, reflect.Int64, reflect.Bool:
-			size = uint64(unsafe.Sizeof(rv.UnsafeAddr()))
-		default:
-			return nil, errors.New("invalid value passed in for key: " + k)
-		}
-
-		iovec[itr] = unix.Iovec{
-			Base: ib,
-			Len:  size,
+			return err
 		}
-
-		itr++
+		v = cv
 	}
+	p[k] = v
 
-	return iovec, nil
+	return nil
 }
 
 // Set creates	a new jail, or modifies	an existing
 // one, and optionally locks the current process in it.
+// The params are checked with Validate before any system call
+// is made. A failure of the call is reported as a *JailError.
 func Set(params Params, flags uintptr) error {
-	iov, err := params.buildIovec()
-	if err != nil {
+	if err := params.Validate(); err != nil {
 		return err
 	}
 
-	return getSet(sysJailSet, iov[0], flags)
+	p := make(Params, len(params)+1)
+	maps.Copy(p, params)
+	p["errmsg"] = ""
+
+	_, err := backend.Set(p, flags)
+
+	return getSet(setOp(flags), sysJailSet, p, err)
 }
 
 // Get retrieves a matching jail based on the provided params.
+// A failure is reported as a *JailError.
 func Get(params Params, flags uintptr) error {
-	iov, err := params.buildIovec()
-	if err != nil {
-		return err
+	if _, ok := params["errmsg"]; !ok {
+		params["errmsg"] = ""
+		defer delete(params, "errmsg")
 	}
 
-	return getSet(sysJailGet, iov[0], flags)
+	_, err := backend.Get(params, flags)
+
+	return getSet("get", sysJailGet, params, err)
 }
 
-// getSet performas the given syscall with the params and flags provided.
-func getSet(call int, iov unix.Iovec, flags uintptr) error {
-	_, _, e1 := unix.Syscall(uintptr(call), uintptr(unsafe.Pointer(&iov)), 0, flags)
-	if e1 != 0 {
-		switch call {
-		case sysJailGet:
-			switch int(e1) {
-			case ErrJailGetFaultOutsideOfAllocatedSpace:
-				return fmt.Errorf("fault outside of allocated space: %d", e1)
-			case enoent:
-				return fmt.Errorf("jail referred to either does not exist or is inaccessible: %d", e1)
-			case einval:
-				return fmt.Errorf("invalid param provided: %d", e1)
-			}
-		case sysJailSet:
-			switch int(e1) {
-			case eperm:
-				return fmt.Errorf("not allowed or restricted: %d", e1)
-			case ErrJailSetFaultOutsideOfAllocatedSpace:
-				return fmt.Errorf("fault outside of allocated space: %d", e1)
-			case ErrJailSetParamNotExist, ErrJailSetParamWrongSize:
-				return fmt.Errorf("invalid param provided: %d", e1)
-			case ErrJailSetUpdateFlagNotSet:
-				return fmt.Errorf("set update flag not set: %d", e1)
-			case ErrJailSetNameTooLong:
-				return fmt.Errorf("set name too long: %d", e1)
-			case ErrJailSetNoIDsLeft:
-				return fmt.Errorf("no JID's left: %d", e1)
-			}
-		}
+// setOp describes the operation performed by jail_set(2)
+// with the given flags.
+func setOp(flags uintptr) string {
+	switch flags & (CreateFlag | UpdateFlag) {
+	case CreateFlag:
+		return "create"
+	case UpdateFlag:
+		return "update"
+	case CreateFlag | UpdateFlag:
+		return "create or update"
 	}
 
-	return nil
+	return "set"
 }
 
-// Attach receives a jail ID and attempts to attach the current
-// process to that jail.
-func Attach(jailID int32) error {
-	return attachRemove(sysJailAttach, jailID)
-}
+// getSet converts the error returned from the given syscall in to
+// a *JailError carrying the jail the params refer to and the message
+// the kernel left in the errmsg parameter.
+func getSet(op string, call int, params Params, err error) error {
+	if err == nil {
+		return nil
+	}
 
-// Remove receives a jail ID and attempts to remove the associated jail.
-func Remove(jailID int32) error {
-	return attachRemove(sysJailRemove, jailID)
-}
+	name, _ := params["name"].(string)
+	msg, _ := params["errmsg"].(string)
 
-// attachRemove
-func attachRemove(call, jailID int32) error {
-	jid := uintptr(unsafe.Pointer(&jailID))
-	_, _, e1 := unix.Syscall(uintptr(call), jid, 0, 0)
-	if e1 != 0 {
-		switch int(e1) {
-		case ErrJailAttachUnprivilegedUser:
-			return fmt.Errorf("unprivileged user")
-		case ErrjailAttachJIDNotExist:
-			return fmt.Errorf("JID does not exist")
+	var jid int32
+	if v, ok := params["jid"]; ok {
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			jid = int32(rv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			jid = int32(rv.Uint())
 		}
 	}
 
-	return nil
+	return newJailError(op, call, err, name, jid, msg)
 }
 
-// ip2int converts the given IP address to an uint32.
-func ip2int(ip net.IP) uint32 {
-	if len(ip) == 16 {
-		return binary.LittleEndian.Uint32(ip[12:16])
-	}
-
-	return binary.LittleEndian.Uint32(ip)
+// Attach receives a jail ID and attempts to attach the current
+// process to that jail. Every thread of the process, and so every
+// goroutine, is moved in to the jail and there is no way back out;
+// see RunInJail to run a function in a jail. A failure is reported
+// as a *JailError.
+func Attach(jailID int32) error {
+	return newJailError("attach", sysJailAttach, backend.Attach(jailID), "", jailID, "")
 }
 
-// uint32ip converts an uint32 representation of a string into an IP.
-func uint32ip(nn uint32) string {
-	ip := make(net.IP, 4)
-	binary.BigEndian.PutUint32(ip, nn)
-
-	return ip.String()
+// Remove receives a jail ID and attempts to remove the associated
+// jail. A failure is reported as a *JailError.
+func Remove(jailID int32) error {
+	return newJailError("remove", sysJailRemove, backend.Remove(jailID), "", jailID, "")
 }
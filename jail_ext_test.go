@@ -0,0 +1,260 @@
+package jail_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/briandowns/jail"
+	"github.com/briandowns/jail/jailtest"
+	"golang.org/x/sys/unix"
+)
+
+// newBackend installs a jailtest backend holding a persistent jail
+// named web for the duration of the test.
+func newBackend(t *testing.T) *jailtest.Backend {
+	t.Helper()
+
+	b := jailtest.New()
+	prev := jail.SetBackend(b)
+	t.Cleanup(func() { jail.SetBackend(prev) })
+
+	if _, err := b.Set(jail.Params{"name": "web", "path": "/jails/web", "persist": true}, jail.CreateFlag); err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestJail(t *testing.T) {
+	type args struct {
+		o *jail.Opts
+	}
+	tests := []struct {
+		name         string
+		args         args
+		unprivileged bool
+		want         int32
+		wantErr      bool
+		wantIs       error
+	}{
+		{
+			name: "create",
+			args: args{o: &jail.Opts{Version: 2, Path: "/jails/db", Name: "db"}},
+			want: 2,
+		},
+		{
+			name:    "exists",
+			args:    args{o: &jail.Opts{Version: 2, Path: "/jails/web", Name: "web"}},
+			wantErr: true,
+			wantIs:  unix.EEXIST,
+		},
+		{
+			name:    "invalid",
+			args:    args{o: &jail.Opts{Version: 2, Name: "db"}},
+			wantErr: true,
+		},
+		{
+			name:         "not permitted",
+			args:         args{o: &jail.Opts{Version: 2, Path: "/jails/db", Name: "db"}},
+			unprivileged: true,
+			wantErr:      true,
+			wantIs:       jail.ErrPermission,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackend(t)
+			b.Unprivileged = tt.unprivileged
+
+			got, err := jail.Jail(tt.args.o)
+			if (err != nil) != tt.wantErr || tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Fatalf("Jail() error = %v, wantErr %v", err, tt.wantIs)
+			}
+			if got != tt.want {
+				t.Errorf("Jail() = %v, want %v", got, tt.want)
+			}
+			if err == nil && b.Attached() != got {
+				t.Errorf("Attached() = %d, want %d", b.Attached(), got)
+			}
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	type args struct {
+		params jail.Params
+		flags  uintptr
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    jail.Params
+		wantErr error
+	}{
+		{
+			name: "create",
+			args: args{params: jail.Params{"name": "db", "path": "/jails/db", "persist": true}, flags: jail.CreateFlag},
+			want: jail.Params{"name": "db", "path": "/jails/db"},
+		},
+		{
+			name: "update",
+			args: args{params: jail.Params{"name": "web", "host.hostname": "web.example.org"}, flags: jail.UpdateFlag},
+			want: jail.Params{"name": "web", "host.hostname": "web.example.org"},
+		},
+		{
+			name: "create or update",
+			args: args{params: jail.Params{"name": "web", "host.hostname": "www"}, flags: jail.CreateFlag | jail.UpdateFlag},
+			want: jail.Params{"name": "web", "host.hostname": "www"},
+		},
+		{
+			name:    "exists",
+			args:    args{params: jail.Params{"name": "web", "path": "/jails/web"}, flags: jail.CreateFlag},
+			wantErr: jail.ErrExists,
+		},
+		{
+			name:    "update not found",
+			args:    args{params: jail.Params{"name": "db", "persist": true}, flags: jail.UpdateFlag},
+			wantErr: jail.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newBackend(t)
+
+			err := jail.Set(tt.args.params, tt.args.flags)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := jail.Params{}
+			for k := range tt.want {
+				got[k] = ""
+			}
+			got["name"] = tt.want["name"]
+			if err := jail.Get(got, 0); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Get() after Set() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	type args struct {
+		params jail.Params
+		flags  uintptr
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    jail.Params
+		wantErr error
+	}{
+		{
+			name: "by name",
+			args: args{params: jail.Params{"name": "web", "jid": int32(0), "path": ""}},
+			want: jail.Params{"name": "web", "jid": int32(1), "path": "/jails/web"},
+		},
+		{
+			name: "by jid",
+			args: args{params: jail.Params{"jid": int32(1), "name": ""}},
+			want: jail.Params{"jid": int32(1), "name": "web"},
+		},
+		{
+			name:    "not found",
+			args:    args{params: jail.Params{"name": "db", "jid": int32(0)}},
+			wantErr: jail.ErrNotFound,
+		},
+		{
+			name:    "unknown parameter",
+			args:    args{params: jail.Params{"name": "web", "foo": ""}},
+			wantErr: jail.ErrUnknownParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newBackend(t)
+
+			err := jail.Get(tt.args.params, tt.args.flags)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(tt.args.params, tt.want) {
+				t.Errorf("Get() = %v, want %v", tt.args.params, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttach(t *testing.T) {
+	type args struct {
+		jailID int32
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr error
+	}{
+		{
+			name: "attach",
+			args: args{jailID: 1},
+		},
+		{
+			name:    "not found",
+			args:    args{jailID: 2},
+			wantErr: jail.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackend(t)
+
+			err := jail.Attach(tt.args.jailID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Attach() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && b.Attached() != tt.args.jailID {
+				t.Errorf("Attached() = %d, want %d", b.Attached(), tt.args.jailID)
+			}
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	type args struct {
+		jailID int32
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr error
+	}{
+		{
+			name: "remove",
+			args: args{jailID: 1},
+		},
+		{
+			name:    "not found",
+			args:    args{jailID: 2},
+			wantErr: jail.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackend(t)
+
+			err := jail.Remove(tt.args.jailID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Remove() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && len(b.Jails()) != 0 {
+				t.Errorf("Jails() = %+v, want none", b.Jails())
+			}
+		})
+	}
+}
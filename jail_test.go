@@ -1,6 +1,8 @@
 package jail
 
 import (
+	"errors"
+	"net/netip"
 	"reflect"
 	"testing"
 
@@ -14,6 +16,9 @@ func TestOpts_validate(t *testing.T) {
 		Name     string
 		Hostname string
 		IP4      string
+		IP4Addrs []netip.Addr
+		IP6Addrs []netip.Addr
+		VNet     bool
 		Chdir    bool
 	}
 	tests := []struct {
@@ -21,7 +26,66 @@ func TestOpts_validate(t *testing.T) {
 		fields  fields
 		wantErr bool
 	}{
-		// TODO: Add test cases.
+		{
+			name:   "minimal",
+			fields: fields{Version: 2, Path: "/jails/web", Name: "web"},
+		},
+		{
+			name:    "missing path",
+			fields:  fields{Version: 2, Name: "web"},
+			wantErr: true,
+		},
+		{
+			name:    "missing name",
+			fields:  fields{Version: 2, Path: "/jails/web"},
+			wantErr: true,
+		},
+		{
+			name: "dual stack",
+			fields: fields{
+				Version:  2,
+				Path:     "/jails/web",
+				Name:     "web",
+				IP4:      "192.0.2.1",
+				IP4Addrs: []netip.Addr{netip.MustParseAddr("127.0.1.1")},
+				IP6Addrs: []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+			},
+		},
+		{
+			name:    "bad IP4",
+			fields:  fields{Version: 2, Path: "/jails/web", Name: "web", IP4: "192.0.2"},
+			wantErr: true,
+		},
+		{
+			name: "IPv6 in IP4Addrs",
+			fields: fields{
+				Version:  2,
+				Path:     "/jails/web",
+				Name:     "web",
+				IP4Addrs: []netip.Addr{netip.MustParseAddr("::1")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate address",
+			fields: fields{
+				Version:  2,
+				Path:     "/jails/web",
+				Name:     "web",
+				IP4:      "192.0.2.1",
+				IP4Addrs: []netip.Addr{netip.MustParseAddr("192.0.2.1")},
+			},
+			wantErr: true,
+		},
+		{
+			name:   "vnet",
+			fields: fields{Version: 2, Path: "/jails/web", Name: "web", VNet: true},
+		},
+		{
+			name:    "vnet with address",
+			fields:  fields{Version: 2, Path: "/jails/web", Name: "web", IP4: "192.0.2.1", VNet: true},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -31,6 +95,9 @@ func TestOpts_validate(t *testing.T) {
 				Name:     tt.fields.Name,
 				Hostname: tt.fields.Hostname,
 				IP4:      tt.fields.IP4,
+				IP4Addrs: tt.fields.IP4Addrs,
+				IP6Addrs: tt.fields.IP6Addrs,
+				VNet:     tt.fields.VNet,
 				Chdir:    tt.fields.Chdir,
 			}
 			if err := o.validate(); (err != nil) != tt.wantErr {
@@ -40,29 +107,30 @@ func TestOpts_validate(t *testing.T) {
 	}
 }
 
-func TestJail(t *testing.T) {
-	type args struct {
-		o *Opts
+func TestOpts_Params(t *testing.T) {
+	o := &Opts{
+		Version:  2,
+		Path:     "/jails/web",
+		Name:     "web",
+		IP4:      "192.0.2.1",
+		IP4Addrs: []netip.Addr{netip.MustParseAddr("127.0.1.1")},
+		IP6Addrs: []netip.Addr{netip.MustParseAddr("2001:db8::1")},
 	}
-	tests := []struct {
-		name    string
-		args    args
-		want    int32
-		wantErr bool
-	}{
-		// TODO: Add test cases.
+
+	got, err := o.Params()
+	if err != nil {
+		t.Fatalf("Opts.Params() error = %v", err)
 	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := Jail(tt.args.o)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("Jail() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if got != tt.want {
-				t.Errorf("Jail() = %v, want %v", got, tt.want)
-			}
-		})
+
+	want := Params{
+		"path":          "/jails/web",
+		"name":          "web",
+		"host.hostname": "web",
+		"ip4.addr":      []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("127.0.1.1")},
+		"ip6.addr":      []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Opts.Params() = %v, want %v", got, want)
 	}
 }
 
@@ -71,7 +139,10 @@ func TestNewParams(t *testing.T) {
 		name string
 		want Params
 	}{
-		// TODO: Add test cases.
+		{
+			name: "empty",
+			want: Params{},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -82,126 +153,147 @@ func TestNewParams(t *testing.T) {
 	}
 }
 
-func TestAttach(t *testing.T) {
+func Test_getSet(t *testing.T) {
 	type args struct {
-		jailID int32
+		op     string
+		call   int
+		params Params
+		err    error
 	}
 	tests := []struct {
-		name    string
-		args    args
-		wantErr bool
+		name string
+		args args
+		want error
 	}{
-		// TODO: Add test cases.
+		{
+			name: "no error",
+			args: args{op: "create", call: sysJailSet},
+			want: nil,
+		},
+		{
+			name: "get not found",
+			args: args{
+				op:     "get",
+				call:   sysJailGet,
+				params: Params{"name": "web", "errmsg": `jail "web" not found`},
+				err:    enoent,
+			},
+			want: &JailError{
+				Op:      "get",
+				Syscall: "jail_get",
+				Errno:   enoent,
+				Err:     ErrNotFound,
+				Name:    "web",
+				Msg:     `jail "web" not found`,
+			},
+		},
+		{
+			name: "set unknown param",
+			args: args{
+				op:     "update",
+				call:   sysJailSet,
+				params: Params{"jid": 3, "foo": "", "errmsg": "unknown parameter: foo"},
+				err:    einval,
+			},
+			want: &JailError{
+				Op:      "update",
+				Syscall: "jail_set",
+				Errno:   einval,
+				Err:     ErrUnknownParam,
+				JID:     3,
+				Msg:     "unknown parameter: foo",
+			},
+		},
+		{
+			name: "unsupported",
+			args: args{op: "create", call: sysJailSet, err: errors.ErrUnsupported},
+			want: errors.ErrUnsupported,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := Attach(tt.args.jailID); (err != nil) != tt.wantErr {
-				t.Errorf("Attach() error = %v, wantErr %v", err, tt.wantErr)
+			got := getSet(tt.args.op, tt.args.call, tt.args.params, tt.args.err)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getSet() = %#v, want %#v", got, tt.want)
 			}
 		})
 	}
 }
 
-func TestRemove(t *testing.T) {
-	type args struct {
-		jailID int32
-	}
+func TestJailError_Error(t *testing.T) {
 	tests := []struct {
-		name    string
-		args    args
-		wantErr bool
+		name string
+		err  *JailError
+		want string
 	}{
-		// TODO: Add test cases.
+		{
+			name: "kernel message",
+			err:  &JailError{Op: "create", Syscall: "jail_set", Errno: einval, Err: ErrUnknownParam, Name: "web", Msg: "unknown parameter: foo"},
+			want: "create web: jail_set: unknown parameter: foo",
+		},
+		{
+			name: "jid",
+			err:  &JailError{Op: "remove", Syscall: "jail_remove", Errno: einval, Err: ErrNotFound, JID: 4},
+			want: "remove 4: jail_remove: jail referred to either does not exist or is inaccessible",
+		},
+		{
+			name: "unknown errno",
+			err:  &JailError{Op: "get", Syscall: "jail_get", Errno: unix.Errno(5)},
+			want: "get: jail_get: errno 5",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := Remove(tt.args.jailID); (err != nil) != tt.wantErr {
-				t.Errorf("Remove() error = %v, wantErr %v", err, tt.wantErr)
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("JailError.Error() = %q, want %q", got, tt.want)
 			}
 		})
 	}
 }
 
-func TestSet(t *testing.T) {
-	type args struct {
-		params Params
-		flags  uintptr
-	}
+func Test_newJailError(t *testing.T) {
 	tests := []struct {
-		name    string
-		args    args
-		wantErr bool
+		name  string
+		call  int
+		errno unix.Errno
+		msg   string
+		want  error
 	}{
-		// TODO: Add test cases.
+		{name: "jail perm", call: sysJail, errno: eperm, want: ErrPermission},
+		{name: "jail version", call: sysJail, errno: einval, want: ErrInvalidVersion},
+		{name: "jail no jids", call: sysJail, errno: eagain, want: ErrNoJIDs},
+		{name: "jail path", call: sysJail, errno: enoent, want: ErrPathNotFound},
+		{name: "set perm", call: sysJailSet, errno: eperm, want: ErrPermission},
+		{name: "set fault", call: sysJailSet, errno: efault, want: ErrFault},
+		{name: "set not found", call: sysJailSet, errno: enoent, want: ErrNotFound},
+		{name: "set exists", call: sysJailSet, errno: eexist, want: ErrExists},
+		{name: "set invalid", call: sysJailSet, errno: einval, msg: "jid out of range", want: ErrInvalidValue},
+		{name: "set unknown", call: sysJailSet, errno: einval, msg: "unknown parameter: foo", want: ErrUnknownParam},
+		{name: "set too long", call: sysJailSet, errno: enametoolong, want: ErrNameTooLong},
+		{name: "set no jids", call: sysJailSet, errno: eagain, want: ErrNoJIDs},
+		{name: "get not found", call: sysJailGet, errno: enoent, want: ErrNotFound},
+		{name: "get unknown", call: sysJailGet, errno: einval, msg: "unknown parameter: foo", want: ErrUnknownParam},
+		{name: "attach perm", call: sysJailAttach, errno: eperm, want: ErrPermission},
+		{name: "attach not found", call: sysJailAttach, errno: einval, want: ErrNotFound},
+		{name: "remove not found", call: sysJailRemove, errno: einval, want: ErrNotFound},
+		{name: "undocumented", call: sysJailRemove, errno: unix.Errno(5), want: unix.Errno(5)},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := Set(tt.args.params, tt.args.flags); (err != nil) != tt.wantErr {
-				t.Errorf("Set() error = %v, wantErr %v", err, tt.wantErr)
+			err := newJailError("op", tt.call, tt.errno, "", 0, tt.msg)
+			if err == nil {
+				t.Fatalf("newJailError() = nil for errno %d", tt.errno)
 			}
-		})
-	}
-}
-
-func TestGet(t *testing.T) {
-	type args struct {
-		params Params
-		flags  uintptr
-	}
-	tests := []struct {
-		name    string
-		args    args
-		wantErr bool
-	}{
-		// TODO: Add test cases.
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if err := Get(tt.args.params, tt.args.flags); (err != nil) != tt.wantErr {
-				t.Errorf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("newJailError() = %v, want %v", err, tt.want)
 			}
-		})
-	}
-}
-
-func Test_getSet(t *testing.T) {
-	type args struct {
-		call  int
-		iov   unix.Iovec
-		flags uintptr
-	}
-	tests := []struct {
-		name    string
-		args    args
-		wantErr bool
-	}{
-		// TODO: Add test cases.
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if err := getSet(tt.args.call, tt.args.iov, tt.args.flags); (err != nil) != tt.wantErr {
-				t.Errorf("getSet() error = %v, wantErr %v", err, tt.wantErr)
+			if !errors.Is(err, tt.errno) {
+				t.Errorf("newJailError() = %v does not match errno %d", err, tt.errno)
 			}
 		})
 	}
-}
 
-func Test_uint32ip(t *testing.T) {
-	type args struct {
-		nn uint32
-	}
-	tests := []struct {
-		name string
-		args args
-		want string
-	}{
-		// TODO: Add test cases.
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := uint32ip(tt.args.nn); got != tt.want {
-				t.Errorf("uint32ip() = %v, want %v", got, tt.want)
-			}
-		})
+	if err := newJailError("op", sysJailSet, nil, "", 0, ""); err != nil {
+		t.Errorf("newJailError() = %v, want nil", err)
 	}
 }
@@ -0,0 +1,366 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package jailconf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pos is a position in a configuration file. Line and Col are
+// 1 based, Col counting bytes, and Offset is the 0 based byte
+// offset in to the file.
+type Pos struct {
+	Filename string
+	Offset   int
+	Line     int
+	Col      int
+}
+
+// IsValid reports whether the position is known. Nodes built
+// in code rather than parsed have no position.
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns the position as file:line:col.
+func (p Pos) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Col)
+	}
+	if s == "" {
+		s = "-"
+	}
+
+	return s
+}
+
+// Node is an element of the syntax tree. Pos is the position of
+// the first byte of the node and End that of the byte following it.
+type Node interface {
+	Pos() Pos
+	End() Pos
+}
+
+// Stmt is a statement in a file or jail block: a *Param, a *Block,
+// an *Include or a free standing *CommentGroup.
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Comment is a single #, // or /* */ comment. Text holds the
+// comment as written, including the comment markers.
+type Comment struct {
+	Slash Pos
+	Text  string
+}
+
+// Pos returns the position of the comment.
+func (c *Comment) Pos() Pos { return c.Slash }
+
+// End returns the position following the comment.
+func (c *Comment) End() Pos { return advance(c.Slash, c.Text) }
+
+// CommentGroup is a sequence of comments with no blank lines
+// or other tokens between them.
+type CommentGroup struct {
+	List []*Comment
+}
+
+// Pos returns the position of the first comment.
+func (g *CommentGroup) Pos() Pos { return g.List[0].Pos() }
+
+// End returns the position following the last comment.
+func (g *CommentGroup) End() Pos { return g.List[len(g.List)-1].End() }
+
+func (*CommentGroup) stmtNode() {}
+
+// AssignOp is the operator of a parameter.
+type AssignOp int
+
+// Parameter operators.
+const (
+	// NoOp is a parameter given without a value, e.g. persist;
+	NoOp AssignOp = iota
+
+	// Assign is =, replacing any previous value.
+	Assign
+
+	// Append is +=, appending to a list parameter.
+	Append
+)
+
+// String returns the operator as written.
+func (o AssignOp) String() string {
+	switch o {
+	case Assign:
+		return "="
+	case Append:
+		return "+="
+	}
+
+	return ""
+}
+
+// Part is a piece of a value, either literal text or a reference
+// to a variable.
+type Part struct {
+	// Text is the literal text with escapes processed.
+	Text string
+
+	// Var is the name of the variable referenced, e.g. name for
+	// either $name or ${name}. Text is empty when Var is set.
+	Var string
+}
+
+// Value is a single string value as written in the file. Unquoted
+// and double quoted values may contain variable references, single
+// quoted ones are taken literally.
+type Value struct {
+	ValuePos Pos
+	ValueEnd Pos
+
+	// Quote is the quote character used, '"' or '\'', or 0 for
+	// an unquoted value.
+	Quote byte
+
+	// Raw is the value as written, including any quotes.
+	Raw string
+
+	Parts []Part
+}
+
+// Pos returns the position of the value.
+func (v *Value) Pos() Pos { return v.ValuePos }
+
+// End returns the position following the value.
+func (v *Value) End() Pos { return v.ValueEnd }
+
+// HasVars reports whether the value references any variables.
+func (v *Value) HasVars() bool {
+	for _, p := range v.Parts {
+		if p.Var != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// String returns the value with escapes processed and variables
+// written as ${name}.
+func (v *Value) String() string {
+	var sb strings.Builder
+	for _, p := range v.Parts {
+		if p.Var != "" {
+			sb.WriteString("${" + p.Var + "}")
+			continue
+		}
+		sb.WriteString(p.Text)
+	}
+
+	return sb.String()
+}
+
+// Param is a parameter assignment, e.g. ip4.addr += 192.0.2.1;
+// Parameters whose name starts with $ define variables.
+type Param struct {
+	Doc *CommentGroup
+
+	NamePos Pos
+	Name    string
+
+	OpPos Pos
+	Op    AssignOp
+
+	// Values holds the comma separated values. It is empty
+	// for a parameter given without a value.
+	Values []*Value
+
+	Semi Pos
+
+	// Comment is a comment following the parameter on the
+	// same line.
+	Comment *CommentGroup
+}
+
+// Pos returns the position of the parameter's name.
+func (p *Param) Pos() Pos { return p.NamePos }
+
+// End returns the position following the semicolon.
+func (p *Param) End() Pos { return advance(p.Semi, ";") }
+
+func (*Param) stmtNode() {}
+
+// IsVar reports whether the parameter defines a variable.
+func (p *Param) IsVar() bool {
+	return strings.HasPrefix(p.Name, "$")
+}
+
+// Block is a jail definition, the wildcard block * applying to
+// every jail.
+type Block struct {
+	Doc *CommentGroup
+
+	NamePos Pos
+	Name    string
+
+	Lbrace Pos
+	Body   []Stmt
+	Rbrace Pos
+
+	// Comment is a comment following the closing brace on the
+	// same line.
+	Comment *CommentGroup
+}
+
+// Pos returns the position of the jail name.
+func (b *Block) Pos() Pos { return b.NamePos }
+
+// End returns the position following the closing brace.
+func (b *Block) End() Pos { return advance(b.Rbrace, "}") }
+
+func (*Block) stmtNode() {}
+
+// Params returns the parameters of the block in order.
+func (b *Block) Params() []*Param {
+	return params(b.Body)
+}
+
+// IsWildcard reports whether the block applies to all jails.
+func (b *Block) IsWildcard() bool {
+	return b.Name == "*"
+}
+
+// Include is an .include directive. The files matched by the
+// pattern are read in place of the directive.
+type Include struct {
+	Doc *CommentGroup
+
+	IncludePos Pos
+	Pattern    *Value
+	Semi       Pos
+
+	Comment *CommentGroup
+
+	// Files holds the parsed files matched by Pattern when
+	// the directive has been followed by ParseFile.
+	Files []*File
+}
+
+// Pos returns the position of the directive.
+func (i *Include) Pos() Pos { return i.IncludePos }
+
+// End returns the position following the semicolon.
+func (i *Include) End() Pos { return advance(i.Semi, ";") }
+
+func (*Include) stmtNode() {}
+
+// File is a parsed configuration file.
+type File struct {
+	Name string
+	Body []Stmt
+}
+
+// Pos returns the start of the file.
+func (f *File) Pos() Pos { return Pos{Filename: f.Name, Line: 1, Col: 1} }
+
+// End returns the end of the last statement of the file.
+func (f *File) End() Pos {
+	if len(f.Body) == 0 {
+		return f.Pos()
+	}
+
+	return f.Body[len(f.Body)-1].End()
+}
+
+// Params returns the global parameters of the file in order,
+// excluding those of included files.
+func (f *File) Params() []*Param {
+	return params(f.Body)
+}
+
+// Blocks returns the jail blocks of the file in order,
+// excluding those of included files.
+func (f *File) Blocks() []*Block {
+	var bs []*Block
+	for _, s := range f.Body {
+		if b, ok := s.(*Block); ok {
+			bs = append(bs, b)
+		}
+	}
+
+	return bs
+}
+
+// Block returns the block for the named jail or nil if
+// there is none.
+func (f *File) Block(name string) *Block {
+	for _, b := range f.Blocks() {
+		if b.Name == name {
+			return b
+		}
+	}
+
+	return nil
+}
+
+// params returns the parameters in the statements.
+func params(body []Stmt) []*Param {
+	var ps []*Param
+	for _, s := range body {
+		if p, ok := s.(*Param); ok {
+			ps = append(ps, p)
+		}
+	}
+
+	return ps
+}
+
+// advance returns the position following s, written at p.
+func advance(p Pos, s string) Pos {
+	if !p.IsValid() {
+		return p
+	}
+	p.Offset += len(s)
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			p.Line++
+			p.Col = 1
+			continue
+		}
+		p.Col++
+	}
+
+	return p
+}
@@ -0,0 +1,508 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package jailconf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/briandowns/jail"
+	"golang.org/x/sys/unix"
+)
+
+// Editor makes changes to a jail.conf file. Only the text of the
+// statements changed is touched, the rest of the file, including its
+// comments and layout, is left as it was.
+type Editor struct {
+	name string
+	src  []byte
+	file *File
+	perm fs.FileMode
+	lock *os.File
+}
+
+// NewEditor returns an editor for the given contents of a file.
+func NewEditor(filename string, src []byte) (*Editor, error) {
+	f, err := Parse(filename, src)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Editor{name: filename, src: src, file: f, perm: 0644}, nil
+}
+
+// OpenEditor reads the named file for editing. A file that does not
+// exist is taken to be empty. When lock is set an exclusive advisory
+// lock is taken on the file with a .lock suffix and held until Close,
+// so that tools editing the same file one at a time do not lose each
+// other's changes.
+func OpenEditor(filename string, lock bool) (*Editor, error) {
+	var lf *os.File
+	if lock {
+		var err error
+		if lf, err = os.OpenFile(filename+".lock", os.O_RDWR|os.O_CREATE, 0600); err != nil {
+			return nil, err
+		}
+		if err := unix.Flock(int(lf.Fd()), unix.LOCK_EX); err != nil {
+			lf.Close()
+			return nil, err
+		}
+	}
+
+	e, err := openEditor(filename)
+	if err != nil {
+		if lf != nil {
+			lf.Close()
+		}
+		return nil, err
+	}
+	e.lock = lf
+
+	return e, nil
+}
+
+// openEditor reads the named file for editing.
+func openEditor(filename string) (*Editor, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	e, err := NewEditor(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := os.Stat(filename); err == nil {
+		e.perm = fi.Mode().Perm()
+	}
+
+	return e, nil
+}
+
+// Close releases the lock taken by OpenEditor, if any. Changes not
+// saved are discarded.
+func (e *Editor) Close() error {
+	if e.lock == nil {
+		return nil
+	}
+	err := e.lock.Close()
+	e.lock = nil
+
+	return err
+}
+
+// Bytes returns the edited contents of the file.
+func (e *Editor) Bytes() []byte {
+	return e.src
+}
+
+// File returns the syntax tree of the edited file.
+func (e *Editor) File() *File {
+	return e.file
+}
+
+// Save writes the edited file. It is written to a temporary file in
+// the same directory which is then renamed over the original so that
+// readers see either the old or the new file in full.
+func (e *Editor) Save() error {
+	dir, base := filepath.Split(e.name)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(e.src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(e.perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), e.name)
+}
+
+// AddJail adds a block for the named jail setting the given
+// parameters to the end of the file. An error wrapping
+// jail.ErrExists is returned if the jail already has a block.
+func (e *Editor) AddJail(name string, params jail.Params) error {
+	if len(e.blocks(name)) > 0 {
+		return fmt.Errorf("%w: %s", jail.ErrExists, name)
+	}
+
+	b, err := NewBlock(name, params)
+	if err != nil {
+		return err
+	}
+
+	var text strings.Builder
+	if len(bytes.TrimSpace(e.src)) > 0 {
+		if !bytes.HasSuffix(e.src, []byte("\n")) {
+			text.WriteByte('\n')
+		}
+		if !bytes.HasSuffix(e.src, []byte("\n\n")) {
+			text.WriteByte('\n')
+		}
+	}
+	text.WriteString(render(b, ""))
+
+	return e.apply(splice{start: len(e.src), end: len(e.src), text: text.String()})
+}
+
+// RemoveJail removes every block of the named jail along with their
+// comments. An error wrapping jail.ErrNotFound is returned if the jail
+// has no block.
+func (e *Editor) RemoveJail(name string) error {
+	bs := e.blocks(name)
+	if len(bs) == 0 {
+		return fmt.Errorf("%w: %s", jail.ErrNotFound, name)
+	}
+
+	edits := make([]splice, len(bs))
+	for i, b := range bs {
+		edits[i] = e.remove(b)
+	}
+
+	return e.apply(edits...)
+}
+
+// SetParam sets the parameter of the named jail, or the global one
+// when name is empty, to the given values. A parameter given without
+// values is written without one, e.g. persist;. The first assignment
+// of the parameter is replaced, keeping its comments, and any others
+// removed, including those under the "no" prefixed name of a boolean.
+// Otherwise the parameter is added after the last in the block.
+func (e *Editor) SetParam(name, param string, values ...string) error {
+	body, err := e.scope(name)
+	if err != nil {
+		return err
+	}
+
+	p := NewParam(param, values...)
+	found := e.find(body, param)
+	if len(found) == 0 {
+		return e.apply(e.insert(name, body, p))
+	}
+
+	first := found[0]
+	edits := []splice{{start: first.NamePos.Offset, end: first.End().Offset, text: strings.TrimSuffix(render(p, ""), "\n")}}
+	for _, other := range found[1:] {
+		edits = append(edits, e.remove(other))
+	}
+
+	return e.apply(edits...)
+}
+
+// AppendParam appends the values to the parameter of the named jail,
+// or the global one when name is empty, by adding param += values;
+// after its last assignment or, if there is none, after the last
+// parameter in the block.
+func (e *Editor) AppendParam(name, param string, values ...string) error {
+	if len(values) == 0 {
+		return fmt.Errorf("%w: no values to append to %s", jail.ErrInvalidValue, param)
+	}
+
+	body, err := e.scope(name)
+	if err != nil {
+		return err
+	}
+
+	p := NewParam(param, values...)
+	p.Op = Append
+	found := e.find(body, param)
+	if len(found) == 0 {
+		return e.apply(e.insert(name, body, p))
+	}
+
+	return e.apply(e.after(found[len(found)-1], p))
+}
+
+// DeleteParam removes every assignment of the parameter of the named
+// jail, or the global one when name is empty, along with their
+// comments. An error wrapping jail.ErrNotFound is returned if the
+// parameter is not set.
+func (e *Editor) DeleteParam(name, param string) error {
+	body, err := e.scope(name)
+	if err != nil {
+		return err
+	}
+
+	found := e.find(body, param)
+	if len(found) == 0 {
+		return fmt.Errorf("%w: %s is not set", jail.ErrNotFound, param)
+	}
+
+	edits := make([]splice, len(found))
+	for i, p := range found {
+		edits[i] = e.remove(p)
+	}
+
+	return e.apply(edits...)
+}
+
+// blocks returns the blocks of the named jail.
+func (e *Editor) blocks(name string) []*Block {
+	var bs []*Block
+	for _, b := range e.file.Blocks() {
+		if b.Name == name {
+			bs = append(bs, b)
+		}
+	}
+
+	return bs
+}
+
+// scope returns the statements of the named jail's blocks, or the
+// global statements when the name is empty.
+func (e *Editor) scope(name string) ([]Stmt, error) {
+	if name == "" {
+		return e.file.Body, nil
+	}
+
+	bs := e.blocks(name)
+	if len(bs) == 0 {
+		return nil, fmt.Errorf("%w: %s", jail.ErrNotFound, name)
+	}
+
+	var body []Stmt
+	for _, b := range bs {
+		body = append(body, b.Body...)
+	}
+
+	return body, nil
+}
+
+// find returns the assignments of the parameter in the statements.
+// Booleans match under either their plain or "no" prefixed name.
+func (e *Editor) find(body []Stmt, param string) []*Param {
+	want, _ := canonicalName(param)
+
+	var found []*Param
+	for _, p := range params(body) {
+		if p.Name == param {
+			found = append(found, p)
+			continue
+		}
+		if name, _ := canonicalName(p.Name); name == want {
+			found = append(found, p)
+		}
+	}
+
+	return found
+}
+
+// splice replaces the source from start to end with text.
+type splice struct {
+	start, end int
+	text       string
+}
+
+// apply makes the changes to the source and parses the result.
+func (e *Editor) apply(edits ...splice) error {
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].start > edits[j].start
+	})
+
+	src := append([]byte(nil), e.src...)
+	for _, s := range edits {
+		src = append(src[:s.start], append([]byte(s.text), src[s.end:]...)...)
+	}
+
+	f, err := Parse(e.name, src)
+	if err != nil {
+		return err
+	}
+	e.src, e.file = src, f
+
+	return nil
+}
+
+// remove returns the change deleting the statement with its comments.
+// Whole lines are removed when the statement is alone on them, along
+// with a blank line when it was between two or ended the file.
+func (e *Editor) remove(s Stmt) splice {
+	start, end := stmtStart(s).Offset, stmtEnd(s).Offset
+
+	ls := start
+	for ls > 0 && (e.src[ls-1] == ' ' || e.src[ls-1] == '\t') {
+		ls--
+	}
+	le := end
+	for le < len(e.src) && (e.src[le] == ' ' || e.src[le] == '\t' || e.src[le] == '\r') {
+		le++
+	}
+	if (ls == 0 || e.src[ls-1] == '\n') && (le == len(e.src) || e.src[le] == '\n') {
+		start, end = ls, le
+		if end < len(e.src) {
+			end++
+		}
+		blankBefore := start >= 2 && e.src[start-2] == '\n'
+		switch {
+		case (blankBefore || start == 0) && end < len(e.src) && e.src[end] == '\n':
+			end++
+		case blankBefore && end == len(e.src):
+			start--
+		}
+	}
+
+	return splice{start: start, end: end}
+}
+
+// insert returns the change adding the parameter to the named jail's
+// last block, or the global parameters when the name is empty, after
+// the last parameter there.
+func (e *Editor) insert(name string, body []Stmt, p *Param) splice {
+	if name != "" {
+		bs := e.blocks(name)
+		if b := bs[len(bs)-1]; e.indentOf(b.Rbrace.Offset) != e.lineBefore(b.Rbrace.Offset) {
+			return e.beforeBrace(b, p)
+		}
+	}
+	if ps := params(body); len(ps) > 0 {
+		return e.after(ps[len(ps)-1], p)
+	}
+
+	if name != "" {
+		bs := e.blocks(name)
+		b := bs[len(bs)-1]
+		indent := "\t"
+		if len(b.Body) > 0 {
+			indent = e.indentOf(stmtStart(b.Body[0]).Offset)
+		}
+		// Place it on its own line straight after the brace.
+		at := b.Lbrace.Offset + 1
+		if i := bytes.IndexByte(e.src[at:], '\n'); i != -1 && len(bytes.TrimSpace(e.src[at:at+i])) == 0 {
+			at += i + 1
+			return splice{start: at, end: at, text: render(p, indent)}
+		}
+		return splice{start: at, end: at, text: "\n" + render(p, indent)}
+	}
+
+	// Globals without any parameters go ahead of the first block.
+	for _, s := range e.file.Body {
+		if _, ok := s.(*Block); ok {
+			at := stmtStart(s).Offset
+			return splice{start: at, end: at, text: render(p, "") + "\n"}
+		}
+	}
+	text := render(p, "")
+	if len(e.src) > 0 && !bytes.HasSuffix(e.src, []byte("\n")) {
+		text = "\n" + text
+	}
+
+	return splice{start: len(e.src), end: len(e.src), text: text}
+}
+
+// after returns the change adding the parameter on the line after
+// the given statement, with the same indentation.
+func (e *Editor) after(s Stmt, p *Param) splice {
+	at := stmtEnd(s).Offset
+	text := "\n" + render(p, e.indentOf(stmtStart(s).Offset))
+
+	return splice{start: at, end: at, text: strings.TrimSuffix(text, "\n")}
+}
+
+// beforeBrace returns the change adding the parameter to a block
+// whose closing brace shares its line with other text, e.g.
+// web { persist; }. The parameter is put on a line of its own,
+// indented as the block's other lines are or else by a tab, and the
+// brace on the line after it.
+func (e *Editor) beforeBrace(b *Block, p *Param) splice {
+	indent := "\t"
+	for _, s := range b.Body {
+		start := stmtStart(s).Offset
+		if i := e.indentOf(start); i != "" && i == e.lineBefore(start) {
+			indent = i
+			break
+		}
+	}
+
+	end := b.Rbrace.Offset
+	at := end
+	for at > 0 && (e.src[at-1] == ' ' || e.src[at-1] == '\t') {
+		at--
+	}
+
+	return splice{start: at, end: end, text: "\n" + render(p, indent) + e.indentOf(stmtStart(b).Offset)}
+}
+
+// lineBefore returns the text of the line holding the given offset
+// up to it.
+func (e *Editor) lineBefore(off int) string {
+	return string(e.src[bytes.LastIndexByte(e.src[:off], '\n')+1 : off])
+}
+
+// indentOf returns the white space at the start of the line holding
+// the given offset.
+func (e *Editor) indentOf(off int) string {
+	ls := bytes.LastIndexByte(e.src[:off], '\n') + 1
+	end := ls
+	for end < off && (e.src[end] == ' ' || e.src[end] == '\t') {
+		end++
+	}
+
+	return string(e.src[ls:end])
+}
+
+// render returns the statement in canonical form with each line
+// indented by indent.
+func render(s Stmt, indent string) string {
+	var buf bytes.Buffer
+	Fprint(&buf, s)
+
+	if indent == "" {
+		return buf.String()
+	}
+	lines := strings.SplitAfter(buf.String(), "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = indent + l
+		}
+	}
+
+	return strings.Join(lines, "")
+}
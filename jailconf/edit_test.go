@@ -0,0 +1,405 @@
+package jailconf
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/briandowns/jail"
+)
+
+const editConf = `# Global settings.
+exec.clean;
+mount.devfs;
+
+# Web server.
+web {
+    path = /jails/web;   # keep me
+    nopersist;
+    ip4.addr = 192.0.2.10;
+}
+
+db {
+	path = /jails/db;
+}
+`
+
+func TestEditor(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		edit    func(e *Editor) error
+		want    string
+		wantErr error
+	}{
+		{
+			name: "set existing",
+			src:  editConf,
+			edit: func(e *Editor) error { return e.SetParam("web", "path", "/data/web") },
+			want: `# Global settings.
+exec.clean;
+mount.devfs;
+
+# Web server.
+web {
+    path = /data/web;   # keep me
+    nopersist;
+    ip4.addr = 192.0.2.10;
+}
+
+db {
+	path = /jails/db;
+}
+`,
+		},
+		{
+			name: "set no prefixed boolean",
+			src:  editConf,
+			edit: func(e *Editor) error { return e.SetParam("web", "persist") },
+			want: `# Global settings.
+exec.clean;
+mount.devfs;
+
+# Web server.
+web {
+    path = /jails/web;   # keep me
+    persist;
+    ip4.addr = 192.0.2.10;
+}
+
+db {
+	path = /jails/db;
+}
+`,
+		},
+		{
+			name: "set new",
+			src:  editConf,
+			edit: func(e *Editor) error { return e.SetParam("web", "host.hostname", "web.example.org") },
+			want: `# Global settings.
+exec.clean;
+mount.devfs;
+
+# Web server.
+web {
+    path = /jails/web;   # keep me
+    nopersist;
+    ip4.addr = 192.0.2.10;
+    host.hostname = web.example.org;
+}
+
+db {
+	path = /jails/db;
+}
+`,
+		},
+		{
+			name: "set global",
+			src:  editConf,
+			edit: func(e *Editor) error { return e.SetParam("", "exec.start", "/bin/sh /etc/rc") },
+			want: `# Global settings.
+exec.clean;
+mount.devfs;
+exec.start = "/bin/sh /etc/rc";
+
+# Web server.
+web {
+    path = /jails/web;   # keep me
+    nopersist;
+    ip4.addr = 192.0.2.10;
+}
+
+db {
+	path = /jails/db;
+}
+`,
+		},
+		{
+			name: "set first global",
+			src:  "# Web.\nweb {\n}\n",
+			edit: func(e *Editor) error { return e.SetParam("", "persist") },
+			want: "persist;\n\n# Web.\nweb {\n}\n",
+		},
+		{
+			name: "set in empty block",
+			src:  "web {\n}\ndb {}\n",
+			edit: func(e *Editor) error {
+				if err := e.SetParam("web", "persist"); err != nil {
+					return err
+				}
+				return e.SetParam("db", "persist")
+			},
+			want: "web {\n\tpersist;\n}\ndb {\n\tpersist;\n}\n",
+		},
+		{
+			name: "set in single line block",
+			src:  "web { persist; }\ndb { path = /jails/db; /* db */ }\n",
+			edit: func(e *Editor) error {
+				if err := e.SetParam("web", "host.hostname", "h"); err != nil {
+					return err
+				}
+				return e.SetParam("db", "persist")
+			},
+			want: "web { persist;\n\thost.hostname = h;\n}\ndb { path = /jails/db; /* db */\n\tpersist;\n}\n",
+		},
+		{
+			name: "set in block closed after a parameter",
+			src:  "web {\n    path = /jails/web; }\n",
+			edit: func(e *Editor) error { return e.SetParam("web", "persist") },
+			want: "web {\n    path = /jails/web;\n    persist;\n}\n",
+		},
+		{
+			name: "set replaces appends",
+			src:  "web {\n\tip4.addr = 192.0.2.1;\n\tip4.addr += 192.0.2.2;\n\tpersist;\n}\n",
+			edit: func(e *Editor) error { return e.SetParam("web", "ip4.addr", "192.0.2.3", "192.0.2.4") },
+			want: "web {\n\tip4.addr = 192.0.2.3, 192.0.2.4;\n\tpersist;\n}\n",
+		},
+		{
+			name: "append existing",
+			src:  editConf,
+			edit: func(e *Editor) error { return e.AppendParam("web", "ip4.addr", "192.0.2.11") },
+			want: `# Global settings.
+exec.clean;
+mount.devfs;
+
+# Web server.
+web {
+    path = /jails/web;   # keep me
+    nopersist;
+    ip4.addr = 192.0.2.10;
+    ip4.addr += 192.0.2.11;
+}
+
+db {
+	path = /jails/db;
+}
+`,
+		},
+		{
+			name: "append new",
+			src:  editConf,
+			edit: func(e *Editor) error { return e.AppendParam("db", "exec.start", "/bin/sh /etc/rc") },
+			want: `# Global settings.
+exec.clean;
+mount.devfs;
+
+# Web server.
+web {
+    path = /jails/web;   # keep me
+    nopersist;
+    ip4.addr = 192.0.2.10;
+}
+
+db {
+	path = /jails/db;
+	exec.start += "/bin/sh /etc/rc";
+}
+`,
+		},
+		{
+			name: "delete",
+			src:  editConf,
+			edit: func(e *Editor) error { return e.DeleteParam("web", "path") },
+			want: `# Global settings.
+exec.clean;
+mount.devfs;
+
+# Web server.
+web {
+    nopersist;
+    ip4.addr = 192.0.2.10;
+}
+
+db {
+	path = /jails/db;
+}
+`,
+		},
+		{
+			name:    "delete missing",
+			src:     editConf,
+			edit:    func(e *Editor) error { return e.DeleteParam("db", "persist") },
+			wantErr: jail.ErrNotFound,
+		},
+		{
+			name: "remove jail",
+			src:  editConf,
+			edit: func(e *Editor) error { return e.RemoveJail("web") },
+			want: `# Global settings.
+exec.clean;
+mount.devfs;
+
+db {
+	path = /jails/db;
+}
+`,
+		},
+		{
+			name: "remove last jail",
+			src:  editConf,
+			edit: func(e *Editor) error { return e.RemoveJail("db") },
+			want: `# Global settings.
+exec.clean;
+mount.devfs;
+
+# Web server.
+web {
+    path = /jails/web;   # keep me
+    nopersist;
+    ip4.addr = 192.0.2.10;
+}
+`,
+		},
+		{
+			name:    "remove missing jail",
+			src:     editConf,
+			edit:    func(e *Editor) error { return e.RemoveJail("mail") },
+			wantErr: jail.ErrNotFound,
+		},
+		{
+			name: "add jail",
+			src:  editConf,
+			edit: func(e *Editor) error {
+				return e.AddJail("mail", jail.Params{"path": "/jails/mail", "persist": true})
+			},
+			want: editConf + `
+mail {
+	path = /jails/mail;
+	persist;
+}
+`,
+		},
+		{
+			name: "add jail to empty file",
+			src:  "",
+			edit: func(e *Editor) error {
+				return e.AddJail("mail", jail.Params{"persist": true})
+			},
+			want: "mail {\n\tpersist;\n}\n",
+		},
+		{
+			name: "add existing jail",
+			src:  editConf,
+			edit: func(e *Editor) error {
+				return e.AddJail("db", jail.Params{})
+			},
+			wantErr: jail.ErrExists,
+		},
+		{
+			name:    "set in missing jail",
+			src:     editConf,
+			edit:    func(e *Editor) error { return e.SetParam("mail", "persist") },
+			wantErr: jail.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewEditor("jail.conf", []byte(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = tt.edit(e)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("edit error = %v, want %v", err, tt.wantErr)
+				}
+				if string(e.Bytes()) != tt.src {
+					t.Errorf("failed edit changed the file to\n%s", e.Bytes())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("edit error = %v", err)
+			}
+			if got := string(e.Bytes()); got != tt.want {
+				t.Errorf("edit =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditor_Save(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "jail.conf")
+	if err := os.WriteFile(name, []byte(editConf), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	// Editors holding the lock take turns so no change is lost.
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e, err := OpenEditor(name, true)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer e.Close()
+			if err := e.AppendParam("db", "exec.start", "/bin/true"); err != nil {
+				errs <- err
+				return
+			}
+			errs <- e.Save()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := ParseFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := ResolveJail(f, "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(j.Pseudo["exec.start"]); got != cap(errs) {
+		t.Errorf("exec.start has %d values, want %d", got, cap(errs))
+	}
+
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0640 {
+		t.Errorf("Save() changed the mode to %v", fi.Mode().Perm())
+	}
+	entries, err := os.ReadDir(filepath.Dir(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Save() left files behind: %v", entries)
+	}
+}
+
+func TestOpenEditor_missing(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "jail.conf")
+	e, err := OpenEditor(name, false)
+	if err != nil {
+		t.Fatalf("OpenEditor() error = %v", err)
+	}
+	if err := e.AddJail("web", jail.Params{"persist": true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(src) != "web {\n\tpersist;\n}\n" {
+		t.Errorf("saved file = %q", src)
+	}
+}
@@ -0,0 +1,431 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package jailconf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/briandowns/jail"
+)
+
+// Format parses src and returns it in canonical form, the form
+// Fprint writes. Formatting a file a second time leaves it as is.
+func Format(src []byte) ([]byte, error) {
+	f, err := Parse("", src)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := Fprint(&buf, f); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Fprint writes the node, a *File or a Stmt, to w as jail.conf text
+// in canonical form. Statements are written in order with their
+// comments, one per line, with the contents of jail blocks indented
+// by a tab. Blank lines between statements of a parsed file are kept,
+// runs of them being reduced to one. Values are written unquoted
+// where possible and in double quotes otherwise.
+func Fprint(w io.Writer, node Node) error {
+	p := &printer{}
+	switch n := node.(type) {
+	case *File:
+		p.stmts(n.Body, true)
+	case Stmt:
+		p.stmt(n)
+	default:
+		return fmt.Errorf("jailconf: cannot print %T", node)
+	}
+
+	_, err := w.Write(p.buf.Bytes())
+
+	return err
+}
+
+// printer writes the syntax tree as text.
+type printer struct {
+	buf    bytes.Buffer
+	indent int
+}
+
+// stmts writes a list of statements.
+func (p *printer) stmts(body []Stmt, top bool) {
+	for i, s := range body {
+		if i > 0 && blankLine(body[i-1], s, top) {
+			p.buf.WriteByte('\n')
+		}
+		p.stmt(s)
+	}
+}
+
+// blankLine reports whether a blank line separates two statements.
+// Those of a parsed file keep the blank lines between them while
+// jail blocks built in code are separated from the statements
+// around them.
+func blankLine(prev, next Stmt, top bool) bool {
+	end, start := stmtEnd(prev), stmtStart(next)
+	if end.IsValid() && start.IsValid() {
+		return start.Line > end.Line+1
+	}
+	_, prevBlock := prev.(*Block)
+	_, nextBlock := next.(*Block)
+
+	return top && (prevBlock || nextBlock)
+}
+
+// stmtStart returns the start of a statement including its doc
+// comment.
+func stmtStart(s Stmt) Pos {
+	if doc := docComment(s); doc != nil {
+		return doc.Pos()
+	}
+
+	return s.Pos()
+}
+
+// stmtEnd returns the end of a statement including its line
+// comment.
+func stmtEnd(s Stmt) Pos {
+	if c := lineComment(s); c != nil {
+		return c.End()
+	}
+
+	return s.End()
+}
+
+// docComment returns the doc comment of a statement.
+func docComment(s Stmt) *CommentGroup {
+	switch s := s.(type) {
+	case *Param:
+		return s.Doc
+	case *Block:
+		return s.Doc
+	case *Include:
+		return s.Doc
+	}
+
+	return nil
+}
+
+// lineComment returns the line comment of a statement.
+func lineComment(s Stmt) *CommentGroup {
+	switch s := s.(type) {
+	case *Param:
+		return s.Comment
+	case *Block:
+		return s.Comment
+	case *Include:
+		return s.Comment
+	}
+
+	return nil
+}
+
+// stmt writes a single statement.
+func (p *printer) stmt(s Stmt) {
+	if g, ok := s.(*CommentGroup); ok {
+		p.comments(g)
+		return
+	}
+	if doc := docComment(s); doc != nil {
+		p.comments(doc)
+	}
+
+	p.writeIndent()
+	switch s := s.(type) {
+	case *Param:
+		p.buf.WriteString(quoteName(s.Name))
+		if s.Op != NoOp {
+			p.buf.WriteString(" " + s.Op.String() + " ")
+			for i, v := range s.Values {
+				if i > 0 {
+					p.buf.WriteString(", ")
+				}
+				p.buf.WriteString(quoteValue(v))
+			}
+		}
+		p.buf.WriteByte(';')
+	case *Include:
+		p.buf.WriteString(includeDirective + " " + quoteValue(s.Pattern) + ";")
+	case *Block:
+		p.buf.WriteString(quoteName(s.Name) + " {\n")
+		p.indent++
+		p.stmts(s.Body, false)
+		p.indent--
+		p.writeIndent()
+		p.buf.WriteByte('}')
+	}
+
+	if c := lineComment(s); c != nil {
+		for _, c := range c.List {
+			p.buf.WriteString(" " + strings.TrimRight(c.Text, " \t\r"))
+		}
+	}
+	p.buf.WriteByte('\n')
+}
+
+// comments writes a group of comments, each on its own line.
+func (p *printer) comments(g *CommentGroup) {
+	for _, c := range g.List {
+		p.writeIndent()
+		p.buf.WriteString(strings.TrimRight(c.Text, " \t\r"))
+		p.buf.WriteByte('\n')
+	}
+}
+
+// writeIndent writes the indentation of the current line.
+func (p *printer) writeIndent() {
+	for i := 0; i < p.indent; i++ {
+		p.buf.WriteByte('\t')
+	}
+}
+
+// isPlain reports whether s can be written without quotes.
+func isPlain(s string, allowDollar bool) bool {
+	if s == "" || strings.Contains(s, "//") || strings.Contains(s, "/*") || s == includeDirective {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !isWordByte(c) || c == '\\' || c == '$' && !allowDollar || c < ' ' || c >= 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
+
+// quoteName returns the name of a parameter or jail as written.
+func quoteName(name string) string {
+	if isPlain(name, strings.HasPrefix(name, "$") && strings.Count(name, "$") == 1) {
+		return name
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('"')
+	writeEscaped(&sb, name)
+	sb.WriteByte('"')
+
+	return sb.String()
+}
+
+// quoteValue returns the value in canonical form: unquoted when
+// it only holds plain text and variable references and double
+// quoted otherwise.
+func quoteValue(v *Value) string {
+	plain := true
+	for _, part := range v.Parts {
+		if part.Var == "" && !isPlain(part.Text, false) {
+			plain = false
+		}
+	}
+	if len(v.Parts) == 0 || len(v.Parts) == 1 && v.Parts[0].Var == "" && v.Parts[0].Text == "" {
+		plain = false
+	}
+
+	var sb strings.Builder
+	if !plain {
+		sb.WriteByte('"')
+	}
+	for i, part := range v.Parts {
+		if part.Var == "" {
+			writeEscaped(&sb, part.Text)
+			continue
+		}
+		braces := false
+		for j := 0; j < len(part.Var); j++ {
+			if !isVarByte(part.Var[j]) {
+				braces = true
+			}
+		}
+		if i+1 < len(v.Parts) {
+			next := v.Parts[i+1].Text
+			braces = braces || next != "" && isVarByte(next[0])
+		}
+		if braces {
+			sb.WriteString("${" + part.Var + "}")
+		} else {
+			sb.WriteString("$" + part.Var)
+		}
+	}
+	if !plain {
+		sb.WriteByte('"')
+	}
+
+	return sb.String()
+}
+
+// writeEscaped writes s with the characters that cannot appear
+// as is in a double quoted string escaped.
+func writeEscaped(sb *strings.Builder, s string) {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\', '$':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			if c < ' ' || c == 0x7f {
+				fmt.Fprintf(sb, `\%03o`, c)
+				continue
+			}
+			sb.WriteByte(c)
+		}
+	}
+}
+
+// NewValue returns a value holding the given text literally.
+func NewValue(text string) *Value {
+	return &Value{Parts: []Part{{Text: text}}}
+}
+
+// NewParam returns a parameter with the given name and values. A
+// parameter without values is written without an operator.
+func NewParam(name string, values ...string) *Param {
+	p := &Param{Name: name}
+	if len(values) > 0 {
+		p.Op = Assign
+	}
+	for _, v := range values {
+		p.Values = append(p.Values, NewValue(v))
+	}
+
+	return p
+}
+
+// NewBlock returns a jail block setting the given parameters in
+// sorted order. Boolean parameters are written without a value, those
+// that are false under their "no" prefixed name. The name parameter is
+// left out when it is the same as the block's.
+func NewBlock(name string, params jail.Params) (*Block, error) {
+	b := &Block{Name: name}
+	for _, k := range sortedKeys(params) {
+		if k == "name" && params[k] == name || k == "errmsg" {
+			continue
+		}
+		p, err := paramOf(k, params[k])
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			b.Body = append(b.Body, p)
+		}
+	}
+
+	return b, nil
+}
+
+// Block returns a block holding the jail's parameters and
+// pseudo-parameters in sorted order, as NewBlock does.
+func (j *Jail) Block() (*Block, error) {
+	all := make(map[string]interface{}, len(j.Params)+len(j.Pseudo))
+	for k, v := range j.Params {
+		all[k] = v
+	}
+	for k, v := range j.Pseudo {
+		if isBool := pseudoParams[k]; isBool && len(v) == 1 {
+			b, err := strconv.ParseBool(v[0])
+			if err == nil {
+				all[k] = b
+				continue
+			}
+		}
+		all[k] = v
+	}
+
+	return NewBlock(j.Name, all)
+}
+
+// sortedKeys returns the keys of the map in sorted order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// paramOf returns the parameter setting name to the value, which is
+// of a type used by the jail package or a []string. An empty list
+// of addresses gives no parameter.
+func paramOf(name string, v interface{}) (*Param, error) {
+	switch v := v.(type) {
+	case bool:
+		if !v {
+			if base, ok := trimNo(name); ok {
+				return NewParam(base), nil
+			}
+			i := strings.LastIndexByte(name, '.') + 1
+			name = name[:i] + "no" + name[i:]
+		}
+		return NewParam(name), nil
+	case string:
+		return NewParam(name, v), nil
+	case []string:
+		return NewParam(name, v...), nil
+	case []byte:
+		return NewParam(name, string(v)), nil
+	case jail.JailSys:
+		return NewParam(name, v.String()), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return NewParam(name, fmt.Sprint(v)), nil
+	case netip.Addr, []netip.Addr, net.IP, []net.IP, netip.Prefix, []netip.Prefix:
+		addrs, err := jail.ToAddrs(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if len(addrs) == 0 {
+			return nil, nil
+		}
+		p := &Param{Name: name, Op: Assign}
+		for _, a := range addrs {
+			p.Values = append(p.Values, NewValue(a.String()))
+		}
+		return p, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s has a value of unsupported type %T", jail.ErrInvalidValue, name, v)
+}
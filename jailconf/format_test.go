@@ -0,0 +1,222 @@
+package jailconf
+
+import (
+	"bytes"
+	"flag"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/briandowns/jail"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestFormat_golden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "format", "*.input"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("no golden files")
+	}
+
+	for _, in := range inputs {
+		name := strings.TrimSuffix(filepath.Base(in), ".input")
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Format(src)
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+
+			golden := strings.TrimSuffix(in, ".input") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Format() =\n%s\nwant\n%s", got, want)
+			}
+
+			again, err := Format(got)
+			if err != nil {
+				t.Fatalf("Format() of formatted file error = %v", err)
+			}
+			if !bytes.Equal(again, got) {
+				t.Errorf("Format() is not idempotent, got\n%s", again)
+			}
+
+			assertSameFile(t, src, got)
+		})
+	}
+}
+
+// assertSameFile checks that the two sources parse to the same
+// statements and values.
+func assertSameFile(t *testing.T, a, b []byte) {
+	t.Helper()
+
+	fa, err := Parse("", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fb, err := Parse("", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := summary(fb.Body), summary(fa.Body); !reflect.DeepEqual(got, want) {
+		t.Errorf("formatted file parses to\n%q\nwant\n%q", got, want)
+	}
+}
+
+// summary returns a description of each statement ignoring
+// positions and quoting.
+func summary(body []Stmt) []string {
+	var out []string
+	for _, s := range body {
+		switch s := s.(type) {
+		case *CommentGroup:
+			for _, c := range s.List {
+				out = append(out, c.Text)
+			}
+		case *Param:
+			line := s.Name + s.Op.String()
+			for _, v := range s.Values {
+				line += "|" + v.String()
+			}
+			out = append(out, line)
+		case *Include:
+			out = append(out, includeDirective+s.Pattern.String())
+		case *Block:
+			out = append(out, s.Name+"{")
+			out = append(out, summary(s.Body)...)
+			out = append(out, "}")
+		}
+	}
+
+	return out
+}
+
+func Test_quoteValue(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{name: "plain", src: `"192.0.2.1"`, want: `192.0.2.1`},
+		{name: "space", src: `'/bin/sh /etc/rc'`, want: `"/bin/sh /etc/rc"`},
+		{name: "empty", src: `''`, want: `""`},
+		{name: "variable", src: `"/jails/$name"`, want: `/jails/$name`},
+		{name: "braces kept", src: `"${name}x"`, want: `${name}x`},
+		{name: "braces dropped", src: `${name}/x`, want: `$name/x`},
+		{name: "dotted variable", src: `$ip4.addr`, want: `$ip4.addr`},
+		{name: "dotted braces", src: `${ip4.addr}`, want: `${ip4.addr}`},
+		{name: "literal dollar", src: `'$name'`, want: `"\$name"`},
+		{name: "escapes", src: "\"a\\\"b\\\\\\n\\001\"", want: `"a\"b\\\n\001"`},
+		{name: "comment like", src: `"//x"`, want: `"//x"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, v, _, err := newScanner("", []byte(tt.src)).scan()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := quoteValue(v); got != tt.want {
+				t.Errorf("quoteValue() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewBlock(t *testing.T) {
+	b, err := NewBlock("web", jail.Params{
+		"name":          "web",
+		"path":          "/jails/web",
+		"persist":       true,
+		"allow.mount":   false,
+		"securelevel":   int32(2),
+		"vnet":          jail.JailSysNew,
+		"ip4.addr":      []netip.Addr{netip.MustParseAddr("192.0.2.10"), netip.MustParseAddr("192.0.2.11")},
+		"ip6.addr":      []netip.Addr(nil),
+		"host.hostname": "web server",
+	})
+	if err != nil {
+		t.Fatalf("NewBlock() error = %v", err)
+	}
+
+	f := &File{Body: []Stmt{NewParam("exec.clean"), b, NewParam("persist")}}
+	var buf bytes.Buffer
+	if err := Fprint(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `exec.clean;
+
+web {
+	allow.nomount;
+	host.hostname = "web server";
+	ip4.addr = 192.0.2.10, 192.0.2.11;
+	path = /jails/web;
+	persist;
+	securelevel = 2;
+	vnet = new;
+}
+
+persist;
+`
+	if buf.String() != want {
+		t.Errorf("Fprint() =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	if _, err := NewBlock("web", jail.Params{"path": 1.5}); err == nil {
+		t.Errorf("NewBlock() of unsupported value should fail")
+	}
+}
+
+func TestJail_Block(t *testing.T) {
+	f, err := Parse("jail.conf", []byte(resolveConf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jails, err := Resolve(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &File{}
+	for _, j := range jails {
+		b, err := j.Block()
+		if err != nil {
+			t.Fatalf("Jail.Block() error = %v", err)
+		}
+		out.Body = append(out.Body, b)
+	}
+
+	var buf bytes.Buffer
+	if err := Fprint(&buf, out); err != nil {
+		t.Fatal(err)
+	}
+	f2, err := Parse("", buf.Bytes())
+	if err != nil {
+		t.Fatalf("Parse() of written blocks error = %v\n%s", err, buf.Bytes())
+	}
+	jails2, err := Resolve(f2)
+	if err != nil {
+		t.Fatalf("Resolve() of written blocks error = %v\n%s", err, buf.Bytes())
+	}
+	if !reflect.DeepEqual(jails2[0].Params, jails[0].Params) || !reflect.DeepEqual(jails2[1].Pseudo, jails[1].Pseudo) {
+		t.Errorf("written blocks resolve to %+v, %+v, want %+v, %+v", jails2[0], jails2[1], jails[0], jails[1])
+	}
+}
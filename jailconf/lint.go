@@ -0,0 +1,451 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package jailconf
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/briandowns/jail"
+)
+
+// Severity is how serious a problem found by Lint is.
+type Severity int
+
+// Severities of problems.
+const (
+	// SeverityError is a problem that stops a jail being created
+	// or started.
+	SeverityError Severity = iota
+
+	// SeverityWarning is a likely mistake that jail(8) accepts.
+	SeverityWarning
+)
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+
+	return "error"
+}
+
+// Diagnostic is a problem found by Lint.
+type Diagnostic struct {
+	Pos      Pos
+	Severity Severity
+	Msg      string
+}
+
+// String returns the diagnostic in the form file:line:col: severity: msg.
+func (d Diagnostic) String() string {
+	return d.Pos.String() + ": " + d.Severity.String() + ": " + d.Msg
+}
+
+// LintOptions controls the checks made by Lint.
+type LintOptions struct {
+	// CheckFiles enables the checks that the path of each jail
+	// and the commands run by its exec hooks exist.
+	CheckFiles bool
+
+	// Root is the directory the files are looked for under,
+	// for checking a configuration for another host.
+	Root string
+}
+
+// hostHooks are the exec hooks run on the host, the others being
+// run inside of the jail.
+var hostHooks = map[string]bool{
+	"exec.prepare":   true,
+	"exec.prestart":  true,
+	"exec.created":   true,
+	"exec.poststart": true,
+	"exec.prestop":   true,
+	"exec.poststop":  true,
+	"exec.release":   true,
+}
+
+// jailHooks are the exec hooks run inside of the jail.
+var jailHooks = map[string]bool{
+	"exec.start": true,
+	"command":    true,
+	"exec.stop":  true,
+}
+
+// searchPath is where commands given without a directory are looked
+// for.
+var searchPath = []string{"/bin", "/sbin", "/usr/bin", "/usr/sbin", "/usr/local/bin", "/usr/local/sbin"}
+
+// Lint checks the file, and those it includes when read by ParseFile,
+// for mistakes that would only otherwise be found when the jails are
+// started. Parameters are checked against the catalog of the jail
+// package for unknown names, suggesting the closest known one, and
+// values of the wrong type. Jails defined more than once, addresses
+// assigned to more than one jail and cycles between the jails named
+// by depend are reported too. The problems found are returned in the
+// order of their positions.
+func Lint(f *File, opts *LintOptions) []Diagnostic {
+	if opts == nil {
+		opts = &LintOptions{}
+	}
+
+	l := &linter{opts: opts, seen: make(map[string]bool)}
+	stmts := f.stmts()
+
+	for _, s := range stmts {
+		switch s := s.(type) {
+		case *Param:
+			l.param(s)
+		case *Block:
+			for _, p := range s.Params() {
+				l.param(p)
+			}
+		}
+	}
+	l.duplicates(stmts)
+
+	jails := l.resolve(stmts)
+	l.addresses(stmts, jails)
+	l.depends(jails)
+	if opts.CheckFiles {
+		for _, j := range jails {
+			l.files(stmts, j)
+		}
+	}
+
+	sort.SliceStable(l.diags, func(i, j int) bool {
+		a, b := l.diags[i].Pos, l.diags[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+
+	return l.diags
+}
+
+// linter collects the problems found.
+type linter struct {
+	opts  *LintOptions
+	diags []Diagnostic
+	seen  map[string]bool
+}
+
+// report records a problem, ignoring any already reported at the
+// same position.
+func (l *linter) report(pos Pos, sev Severity, format string, args ...interface{}) {
+	key := pos.String()
+	if l.seen[key] {
+		return
+	}
+	l.seen[key] = true
+	l.diags = append(l.diags, Diagnostic{Pos: pos, Severity: sev, Msg: fmt.Sprintf(format, args...)})
+}
+
+// param checks the name of a parameter and, if it does not use
+// variables, its value.
+func (l *linter) param(p *Param) {
+	if p.IsVar() || IsPseudoParam(p.Name) {
+		return
+	}
+
+	name, negate := canonicalName(p.Name)
+	jp, ok := jail.LookupParam(name)
+	if !ok {
+		if s := suggest(p.Name); s != "" {
+			l.report(p.NamePos, SeverityError, "unknown parameter %s, did you mean %s?", p.Name, s)
+			return
+		}
+		l.report(p.NamePos, SeverityError, "unknown parameter %s", p.Name)
+		return
+	}
+	if jp.ReadOnly() {
+		l.report(p.NamePos, SeverityError, "parameter %s is read only", p.Name)
+		return
+	}
+
+	strs := make([]string, len(p.Values))
+	for i, v := range p.Values {
+		if v.HasVars() {
+			return
+		}
+		strs[i] = v.String()
+	}
+	if _, err := convert(jp, &assignment{name: name, param: p, values: p.Values, negate: negate}, strs); err != nil {
+		l.report(p.NamePos, SeverityError, "%s", strings.TrimPrefix(err.Error(), p.NamePos.String()+": "))
+	}
+}
+
+// duplicates reports jails with more than one block.
+func (l *linter) duplicates(stmts []Stmt) {
+	first := make(map[string]*Block)
+	for _, s := range stmts {
+		b, ok := s.(*Block)
+		if !ok {
+			continue
+		}
+		if f, ok := first[b.Name]; ok {
+			l.report(b.NamePos, SeverityError, "jail %s is already defined at %s", b.Name, f.NamePos)
+			continue
+		}
+		first[b.Name] = b
+	}
+}
+
+// resolve resolves each jail, reporting those that cannot be.
+func (l *linter) resolve(stmts []Stmt) []*Jail {
+	var (
+		jails []*Jail
+		done  = make(map[string]bool)
+	)
+	for _, s := range stmts {
+		b, ok := s.(*Block)
+		if !ok || isPattern(b.Name) || done[b.Name] {
+			continue
+		}
+		done[b.Name] = true
+
+		j, err := resolve(stmts, b.Name, true)
+		errs := []error{err}
+		if je, ok := err.(interface{ Unwrap() []error }); ok {
+			errs = je.Unwrap()
+		}
+		for _, err := range errs {
+			var perr *Error
+			switch {
+			case err == nil:
+			case errors.As(err, &perr):
+				l.report(perr.Pos, SeverityError, "%s", perr.Msg)
+			default:
+				l.report(b.NamePos, SeverityError, "%v", err)
+			}
+		}
+		if j != nil {
+			jails = append(jails, j)
+		}
+	}
+
+	return jails
+}
+
+// addresses reports addresses assigned to more than one jail.
+func (l *linter) addresses(stmts []Stmt, jails []*Jail) {
+	owner := make(map[netip.Addr]string)
+	for _, j := range jails {
+		for _, k := range []string{"ip4.addr", "ip6.addr"} {
+			addrs, _ := j.Params[k].([]netip.Addr)
+			for _, a := range addrs {
+				o, ok := owner[a]
+				if !ok {
+					owner[a] = j.Name
+					continue
+				}
+				if o != j.Name {
+					l.report(paramPos(stmts, j, k), SeverityError, "address %s of jail %s is also assigned to jail %s", a, j.Name, o)
+				}
+			}
+		}
+	}
+}
+
+// depends reports jails depending on themselves through depend and
+// dependencies on jails that are not defined.
+func (l *linter) depends(jails []*Jail) {
+	byName := make(map[string]*Jail, len(jails))
+	for _, j := range jails {
+		byName[j.Name] = j
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(jails))
+	var (
+		stack []string
+		visit func(j *Jail)
+	)
+	visit = func(j *Jail) {
+		state[j.Name] = visiting
+		stack = append(stack, j.Name)
+		for _, d := range j.Pseudo["depend"] {
+			dj, ok := byName[d]
+			switch {
+			case !ok:
+				l.report(j.Pos, SeverityWarning, "jail %s depends on undefined jail %s", j.Name, d)
+			case state[d] == visiting:
+				i := len(stack) - 1
+				for stack[i] != d {
+					i--
+				}
+				cycle := append(append([]string(nil), stack[i:]...), d)
+				l.report(dj.Pos, SeverityError, "dependency cycle: %s", strings.Join(cycle, " -> "))
+			case state[d] == unvisited:
+				visit(dj)
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[j.Name] = visited
+	}
+	for _, j := range jails {
+		if state[j.Name] == unvisited {
+			visit(j)
+		}
+	}
+}
+
+// files reports a missing path directory and exec hooks running
+// commands that do not exist.
+func (l *linter) files(stmts []Stmt, j *Jail) {
+	root := l.opts.Root
+	path, _ := j.Params["path"].(string)
+	if path != "" {
+		fi, err := os.Stat(filepath.Join(root, path))
+		switch {
+		case err != nil:
+			l.report(paramPos(stmts, j, "path"), SeverityError, "path %s of jail %s does not exist", path, j.Name)
+			path = ""
+		case !fi.IsDir():
+			l.report(paramPos(stmts, j, "path"), SeverityError, "path %s of jail %s is not a directory", path, j.Name)
+			path = ""
+		}
+	}
+
+	for _, hook := range sortedHooks(j.Pseudo) {
+		dir := root
+		if jailHooks[hook] {
+			if path == "" {
+				continue
+			}
+			dir = filepath.Join(root, path)
+		}
+		for _, cmd := range j.Pseudo[hook] {
+			fields := strings.Fields(cmd)
+			if len(fields) > 0 && !findCommand(dir, fields[0]) {
+				l.report(paramPos(stmts, j, hook), SeverityError, "%s command %s of jail %s does not exist", hook, fields[0], j.Name)
+			}
+		}
+	}
+}
+
+// sortedHooks returns the names of the exec hooks set in sorted order.
+func sortedHooks(pseudo map[string][]string) []string {
+	var hooks []string
+	for k := range pseudo {
+		if hostHooks[k] || jailHooks[k] {
+			hooks = append(hooks, k)
+		}
+	}
+	sort.Strings(hooks)
+
+	return hooks
+}
+
+// findCommand reports whether the command exists under dir, looking
+// in the usual directories for those given without one.
+func findCommand(dir, cmd string) bool {
+	dirs := []string{""}
+	if !strings.Contains(cmd, "/") {
+		dirs = searchPath
+	}
+	for _, d := range dirs {
+		fi, err := os.Stat(filepath.Join(dir, d, cmd))
+		if err == nil && !fi.IsDir() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// paramPos returns the position of the last assignment of the
+// parameter to the jail, or the jail's position if it is inherited.
+func paramPos(stmts []Stmt, j *Jail, name string) Pos {
+	pos := j.Pos
+	for _, s := range stmts {
+		b, ok := s.(*Block)
+		if !ok || b.Name != j.Name {
+			continue
+		}
+		for _, p := range b.Params() {
+			if p.Name == name {
+				pos = p.NamePos
+			}
+		}
+	}
+
+	return pos
+}
+
+// suggest returns the known parameter closest to the unknown name,
+// or an empty string if none is close.
+func suggest(name string) string {
+	names := jail.ParamNames()
+	for k := range pseudoParams {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	best, bestDist := "", 3
+	for _, n := range names {
+		if d := distance(name, n); d < bestDist {
+			best, bestDist = n, d
+		}
+	}
+
+	return best
+}
+
+// distance returns the Levenshtein distance between two strings.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
@@ -0,0 +1,150 @@
+package jailconf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "clean",
+			src:  "persist;\nweb {\n\tpath = /jails/web;\n\tip4.addr = 192.0.2.1;\n}\n",
+		},
+		{
+			name: "vnet",
+			src:  "web {\n\tpath = /jails/web;\n\tvnet;\n}\ndb {\n\tpath = /jails/db;\n\tnovnet;\n}\n",
+		},
+		{
+			name: "unknown parameter",
+			src:  "web {\n\tallow.raw_socket;\n\tallow.frobnicate;\n}\n",
+			want: []string{
+				"jail.conf:2:2: error: unknown parameter allow.raw_socket, did you mean allow.raw_sockets?",
+				"jail.conf:3:2: error: unknown parameter allow.frobnicate",
+			},
+		},
+		{
+			name: "wrong types",
+			src:  "securelevel = high;\nweb {\n\tpersist = maybe;\n\tip4.addr = 2001:db8::1;\n\tjid = 5;\n\tpath = $root;\n}\n",
+			want: []string{
+				`jail.conf:1:1: error: securelevel: invalid param provided: "high" is not an integer`,
+				`jail.conf:3:2: error: persist: invalid param provided: "maybe" is not a boolean`,
+				"jail.conf:4:2: error: ip4.addr: invalid param provided: 2001:db8::1 is of the wrong family for ip4.addr",
+				"jail.conf:6:9: error: undefined variable $root",
+			},
+		},
+		{
+			name: "read only",
+			src:  "web {\n\tdying;\n}\n",
+			want: []string{"jail.conf:2:2: error: parameter dying is read only"},
+		},
+		{
+			name: "duplicate jail",
+			src:  "web {\n}\n\nweb {\n}\n",
+			want: []string{"jail.conf:4:1: error: jail web is already defined at jail.conf:1:1"},
+		},
+		{
+			name: "shared address",
+			src:  "web {\n\tip4.addr = 192.0.2.1;\n}\ndb {\n\tip4.addr = 192.0.2.2, 192.0.2.1;\n}\n",
+			want: []string{"jail.conf:5:2: error: address 192.0.2.1 of jail db is also assigned to jail web"},
+		},
+		{
+			name: "inherited address",
+			src:  "ip4.addr = 192.0.2.1;\nweb {\n}\ndb {\n}\n",
+			want: []string{"jail.conf:4:1: error: address 192.0.2.1 of jail db is also assigned to jail web"},
+		},
+		{
+			name: "depend cycle",
+			src:  "a {\n\tdepend = b;\n}\nb {\n\tdepend = c;\n}\nc {\n\tdepend = a, d;\n}\n",
+			want: []string{
+				"jail.conf:1:1: error: dependency cycle: a -> b -> c -> a",
+				"jail.conf:7:1: warning: jail c depends on undefined jail d",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse("jail.conf", []byte(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, d := range Lint(f, nil) {
+				got = append(got, d.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLint_files(t *testing.T) {
+	root := t.TempDir()
+	for _, d := range []string{"jails/web/bin", "usr/local/bin"} {
+		if err := os.MkdirAll(filepath.Join(root, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{"jails/web/bin/sh", "usr/local/bin/setup", "jails/db"} {
+		if err := os.WriteFile(filepath.Join(root, f), nil, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	src := `exec.start = "/bin/sh /etc/rc";
+web {
+	path = /jails/web;
+	exec.prestart = "setup web";
+	exec.poststop = /usr/local/bin/teardown;
+}
+db {
+	path = /jails/db;
+}
+mail {
+	path = /jails/mail;
+}
+`
+	f, err := Parse("jail.conf", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, d := range Lint(f, &LintOptions{CheckFiles: true, Root: root}) {
+		got = append(got, d.String())
+	}
+	want := []string{
+		"jail.conf:5:2: error: exec.poststop command /usr/local/bin/teardown of jail web does not exist",
+		"jail.conf:8:2: error: path /jails/db of jail db is not a directory",
+		"jail.conf:11:2: error: path /jails/mail of jail mail does not exist",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint() = %q, want %q", got, want)
+	}
+}
+
+func Test_distance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "abc", want: 3},
+		{a: "allow.raw_socket", b: "allow.raw_sockets", want: 1},
+		{a: "persist", b: "presist", want: 2},
+		{a: "kitten", b: "sitting", want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := distance(tt.a, tt.b); got != tt.want {
+				t.Errorf("distance() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
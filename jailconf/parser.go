@@ -0,0 +1,384 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+// Package jailconf reads and writes jail.conf(5) files, the
+// configuration used by jail(8) to create jails.
+//
+// Parse and ParseFile return the syntax tree of a file. Positions are
+// kept for every node so that errors can be reported against the
+// source, e.g. "jail.conf:42:7: unterminated string". Resolve then
+// applies jail(8)'s inheritance and variable rules to give the
+// parameters of each jail, ready to be passed to jail.Set.
+//
+// Fprint writes a syntax tree back out as text and Format rewrites a
+// file in canonical form, keeping its comments and order, much as
+// gofmt does for Go. NewBlock builds the block for a set of Params.
+// An Editor changes single jails and parameters of a file in place,
+// leaving the rest of its text untouched. Lint reports mistakes that
+// would otherwise only be found when the jails are started.
+package jailconf
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// maxIncludeDepth is how deeply .include directives may be nested
+// before ParseFile gives up, catching files that include themselves.
+const maxIncludeDepth = 32
+
+// includeDirective is the directive reading in other files.
+const includeDirective = ".include"
+
+// parser builds the syntax tree of a file from its tokens.
+type parser struct {
+	s *scanner
+
+	// The current token.
+	tok token
+	pos Pos
+	val *Value
+
+	// comments holds the comments read since the last
+	// statement that have yet to be attached.
+	comments []*Comment
+}
+
+// Parse parses the contents of a jail.conf file. The name is only
+// used for positions. The .include directives are left in the tree
+// without being followed.
+func Parse(filename string, src []byte) (*File, error) {
+	p := &parser{s: newScanner(filename, src)}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	body, err := p.parseBody(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &File{Name: filename, Body: body}, nil
+}
+
+// ParseFile reads and parses the named file. The files matched by
+// its .include directives are parsed in turn and stored in the
+// Files of each Include. Relative patterns are taken relative to
+// the directory of the including file.
+func ParseFile(filename string) (*File, error) {
+	return parseFile(filename, 0)
+}
+
+// parseFile parses the named file, following includes to the
+// given depth.
+func parseFile(filename string, depth int) (*File, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := Parse(filename, src)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range f.Body {
+		inc, ok := s.(*Include)
+		if !ok {
+			continue
+		}
+		if depth >= maxIncludeDepth {
+			return nil, errorf(inc.Pos(), "includes nested too deeply")
+		}
+		if inc.Pattern.HasVars() {
+			return nil, errorf(inc.Pattern.Pos(), "variables cannot be used in "+includeDirective)
+		}
+
+		pattern := inc.Pattern.String()
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(filename), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, errorf(inc.Pattern.Pos(), "invalid pattern "+inc.Pattern.Raw)
+		}
+		for _, m := range matches {
+			sub, err := parseFile(m, depth+1)
+			if err != nil {
+				if _, ok := err.(*Error); ok {
+					return nil, err
+				}
+				return nil, errorf(inc.Pos(), err.Error())
+			}
+			inc.Files = append(inc.Files, sub)
+		}
+	}
+
+	return f, nil
+}
+
+// next reads the next token, collecting any comments on the way.
+func (p *parser) next() error {
+	for {
+		tok, pos, val, lit, err := p.s.scan()
+		if err != nil {
+			return err
+		}
+		if tok == tokComment {
+			p.comments = append(p.comments, &Comment{Slash: pos, Text: lit})
+			continue
+		}
+		p.tok, p.pos, p.val = tok, pos, val
+		return nil
+	}
+}
+
+// unexpected returns an error for the current token.
+func (p *parser) unexpected(want string) error {
+	found := p.tok.String()
+	if p.tok == tokString {
+		found = p.val.Raw
+	}
+
+	return errorf(p.pos, "expected "+want+", found "+found)
+}
+
+// parseBody parses statements up to the end of the file or, for
+// a jail block opened by the brace at lbrace, its closing brace.
+func (p *parser) parseBody(lbrace *Pos) ([]Stmt, error) {
+	var body []Stmt
+	for {
+		switch p.tok {
+		case tokEOF:
+			if lbrace != nil {
+				return nil, errorf(*lbrace, "unterminated jail block")
+			}
+			return p.freeComments(body), nil
+		case tokRbrace:
+			if lbrace == nil {
+				return nil, errorf(p.pos, "unexpected '}'")
+			}
+			return p.freeComments(body), nil
+		case tokSemi:
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		case tokString:
+			var doc *CommentGroup
+			doc, body = p.leadComments(body, p.pos)
+			s, err := p.parseStmt(doc, lbrace != nil)
+			if err != nil {
+				return nil, err
+			}
+			body = append(body, s)
+		default:
+			return nil, p.unexpected("parameter or jail name")
+		}
+	}
+}
+
+// parseStmt parses a parameter, jail block or include directive.
+func (p *parser) parseStmt(doc *CommentGroup, inBlock bool) (Stmt, error) {
+	namePos, nameVal := p.pos, p.val
+	name := nameVal.Raw
+	if nameVal.Quote != 0 {
+		name = nameVal.String()
+	}
+
+	if nameVal.Quote == 0 && name == includeDirective {
+		if inBlock {
+			return nil, errorf(namePos, includeDirective+" is not allowed in a jail block")
+		}
+		return p.parseInclude(doc, namePos)
+	}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	switch p.tok {
+	case tokLbrace:
+		if inBlock {
+			return nil, errorf(p.pos, "jail blocks cannot be nested")
+		}
+		return p.parseBlock(doc, namePos, name)
+	case tokSemi:
+		return p.finishParam(&Param{Doc: doc, NamePos: namePos, Name: name})
+	case tokAssign, tokAppend:
+	default:
+		return nil, p.unexpected("'=', '+=', '{' or ';' after " + name)
+	}
+
+	param := &Param{Doc: doc, NamePos: namePos, Name: name, OpPos: p.pos, Op: Assign}
+	if p.tok == tokAppend {
+		param.Op = Append
+	}
+	for {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.tok != tokString {
+			return nil, p.unexpected("value for " + name)
+		}
+		v := p.val
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		for p.tok == tokString && p.s.touching {
+			v = join(v, p.val)
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		param.Values = append(param.Values, v)
+		if p.tok != tokComma {
+			break
+		}
+	}
+	if p.tok != tokSemi {
+		return nil, p.unexpected("',' or ';'")
+	}
+
+	return p.finishParam(param)
+}
+
+// finishParam completes a parameter at its semicolon.
+func (p *parser) finishParam(param *Param) (Stmt, error) {
+	param.Semi = p.pos
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	param.Comment = p.lineComment(param.Semi.Line)
+
+	return param, nil
+}
+
+// parseBlock parses a jail block from its opening brace.
+func (p *parser) parseBlock(doc *CommentGroup, namePos Pos, name string) (Stmt, error) {
+	b := &Block{Doc: doc, NamePos: namePos, Name: name, Lbrace: p.pos}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	// A comment following the brace belongs to the block body.
+	body, err := p.parseBody(&b.Lbrace)
+	if err != nil {
+		return nil, err
+	}
+	b.Body = body
+	b.Rbrace = p.pos
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	b.Comment = p.lineComment(b.Rbrace.Line)
+
+	return b, nil
+}
+
+// parseInclude parses an include directive from its name.
+func (p *parser) parseInclude(doc *CommentGroup, pos Pos) (Stmt, error) {
+	inc := &Include{Doc: doc, IncludePos: pos}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.tok != tokString {
+		return nil, p.unexpected("file pattern")
+	}
+	inc.Pattern = p.val
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.tok != tokSemi {
+		return nil, p.unexpected("';'")
+	}
+	inc.Semi = p.pos
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	inc.Comment = p.lineComment(inc.Semi.Line)
+
+	return inc, nil
+}
+
+// lineComment returns the comments starting on the given line,
+// those following a statement on the line it ends.
+func (p *parser) lineComment(line int) *CommentGroup {
+	var n int
+	for n < len(p.comments) && p.comments[n].Slash.Line == line {
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+
+	g := &CommentGroup{List: p.comments[:n:n]}
+	p.comments = p.comments[n:]
+
+	return g
+}
+
+// leadComments attaches the pending comments ahead of a statement
+// starting at pos. The group directly above the statement becomes
+// its doc comment, the others are added to the body on their own.
+func (p *parser) leadComments(body []Stmt, pos Pos) (*CommentGroup, []Stmt) {
+	groups := groupComments(p.comments)
+	p.comments = nil
+
+	var doc *CommentGroup
+	if n := len(groups); n > 0 && groups[n-1].End().Line >= pos.Line-1 {
+		doc = groups[n-1]
+		groups = groups[:n-1]
+	}
+	for _, g := range groups {
+		body = append(body, g)
+	}
+
+	return doc, body
+}
+
+// freeComments adds the pending comments to the body on their own.
+func (p *parser) freeComments(body []Stmt) []Stmt {
+	for _, g := range groupComments(p.comments) {
+		body = append(body, g)
+	}
+	p.comments = nil
+
+	return body
+}
+
+// groupComments splits comments in to groups at blank lines.
+func groupComments(list []*Comment) []*CommentGroup {
+	var groups []*CommentGroup
+	for i, c := range list {
+		if i == 0 || c.Slash.Line > list[i-1].End().Line+1 {
+			groups = append(groups, &CommentGroup{})
+		}
+		g := groups[len(groups)-1]
+		g.List = append(g.List, c)
+	}
+
+	return groups
+}
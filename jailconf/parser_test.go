@@ -0,0 +1,191 @@
+package jailconf
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testConf = `# Global settings.
+exec.start = "/bin/sh /etc/rc";
+exec.clean;
+mount.devfs; // every jail gets devfs
+
+/* The wildcard block. */
+* {
+	path = "/jails/$name";
+}
+
+# The web server.
+web {
+	host.hostname = web.example.org;
+	ip4.addr = 192.0.2.10, 192.0.2.11;
+	ip6.addr += "2001:db8::10";
+	$tag = 'prod';
+} # end of web
+
+# Trailing comment.
+`
+
+func TestParse(t *testing.T) {
+	f, err := Parse("jail.conf", []byte(testConf))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if len(f.Body) != 6 {
+		t.Fatalf("Parse() returned %d statements, want 6", len(f.Body))
+	}
+
+	ps := f.Params()
+	if len(ps) != 3 {
+		t.Fatalf("File.Params() = %d params, want 3", len(ps))
+	}
+	if ps[0].Name != "exec.start" || ps[0].Op != Assign || ps[0].Values[0].String() != "/bin/sh /etc/rc" {
+		t.Errorf("first param = %+v", ps[0])
+	}
+	if ps[0].Doc == nil || ps[0].Doc.List[0].Text != "# Global settings." {
+		t.Errorf("first param doc = %+v", ps[0].Doc)
+	}
+	if ps[1].Name != "exec.clean" || ps[1].Op != NoOp || len(ps[1].Values) != 0 {
+		t.Errorf("second param = %+v", ps[1])
+	}
+	if ps[2].Comment == nil || ps[2].Comment.List[0].Text != "// every jail gets devfs" {
+		t.Errorf("third param comment = %+v", ps[2].Comment)
+	}
+
+	bs := f.Blocks()
+	if len(bs) != 2 || !bs[0].IsWildcard() || bs[1].Name != "web" {
+		t.Fatalf("File.Blocks() = %+v", bs)
+	}
+	if bs[0].Doc == nil || bs[0].Doc.List[0].Text != "/* The wildcard block. */" {
+		t.Errorf("wildcard doc = %+v", bs[0].Doc)
+	}
+
+	web := f.Block("web")
+	if web.Comment == nil || web.Comment.List[0].Text != "# end of web" {
+		t.Errorf("web comment = %+v", web.Comment)
+	}
+	wps := web.Params()
+	if len(wps) != 4 {
+		t.Fatalf("Block.Params() = %d params, want 4", len(wps))
+	}
+	if got := wps[1].Values; len(got) != 2 || got[0].String() != "192.0.2.10" || got[1].String() != "192.0.2.11" {
+		t.Errorf("ip4.addr values = %+v", got)
+	}
+	if wps[2].Op != Append || wps[2].Values[0].Quote != '"' {
+		t.Errorf("ip6.addr = %+v", wps[2])
+	}
+	if !wps[3].IsVar() || wps[3].Name != "$tag" || wps[3].Values[0].HasVars() {
+		t.Errorf("variable = %+v", wps[3])
+	}
+
+	pos := wps[1].Values[1].Pos()
+	if pos.String() != "jail.conf:14:25" {
+		t.Errorf("position = %s, want jail.conf:14:25", pos)
+	}
+	if end := web.End(); end.Line != 17 || end.Col != 2 {
+		t.Errorf("Block.End() = %s, want 17:2", end)
+	}
+
+	if g, ok := f.Body[5].(*CommentGroup); !ok || g.List[0].Text != "# Trailing comment." {
+		t.Errorf("last statement = %#v, want free comment", f.Body[5])
+	}
+}
+
+func TestParse_join(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{name: "quoted and variable", src: `path = "/usr/jails/"$name;`, want: []string{"/usr/jails/${name}"}},
+		{name: "variable and quoted", src: `host.hostname = ${name}".example.org";`, want: []string{"${name}.example.org"}},
+		{name: "single and double quoted", src: `exec.start = 'a b'" c";`, want: []string{"a b c"}},
+		{name: "separated by space", src: `a = "x", "y" ;`, want: []string{"x", "y"}},
+		{name: "separated by comment", src: `a = "x"/* c */, "y";`, want: []string{"x", "y"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse("jail.conf", []byte(tt.src))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			var got []string
+			for _, v := range f.Params()[0].Values {
+				got = append(got, v.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() values = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	_, err := Parse("jail.conf", []byte(`a = "x"/* c */"y";`))
+	if err == nil || err.Error() != `jail.conf:1:15: expected ',' or ';', found "y"` {
+		t.Errorf("Parse() error = %v, strings separated by a comment should not be joined", err)
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{name: "unterminated string", src: "web {\n\tpath = \"/jails;\n}\n", want: "jail.conf:2:9: unterminated string"},
+		{name: "missing semicolon", src: "web {\n\tpath = /jails\n}\n", want: "jail.conf:3:1: expected ',' or ';', found '}'"},
+		{name: "missing value", src: "path = ;", want: "jail.conf:1:8: expected value for path, found ';'"},
+		{name: "missing operator", src: "path /jails;", want: "jail.conf:1:6: expected '=', '+=', '{' or ';' after path, found /jails"},
+		{name: "unterminated block", src: "web {\n\tpersist;\n", want: "jail.conf:1:5: unterminated jail block"},
+		{name: "stray brace", src: "persist;\n}\n", want: "jail.conf:2:1: unexpected '}'"},
+		{name: "nested block", src: "web {\n\tdb {\n\t}\n}\n", want: "jail.conf:2:5: jail blocks cannot be nested"},
+		{name: "include in block", src: "web {\n\t.include \"x\";\n}\n", want: "jail.conf:2:2: .include is not allowed in a jail block"},
+		{name: "leading operator", src: "= a;", want: "jail.conf:1:1: expected parameter or jail name, found '='"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse("jail.conf", []byte(tt.src))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Parse() error = %v, want %s", err, tt.want)
+			}
+			if _, ok := err.(*Error); !ok {
+				t.Errorf("Parse() error is %T, want *Error", err)
+			}
+		})
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	f, err := ParseFile(filepath.Join("testdata", "include", "jail.conf"))
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+
+	var inc *Include
+	for _, s := range f.Body {
+		if i, ok := s.(*Include); ok {
+			inc = i
+		}
+	}
+	if inc == nil {
+		t.Fatal("ParseFile() did not return the include")
+	}
+	if len(inc.Files) != 2 {
+		t.Fatalf("include matched %d files, want 2", len(inc.Files))
+	}
+	if inc.Files[0].Block("db") == nil || inc.Files[1].Block("web") == nil {
+		t.Errorf("included files = %+v, %+v", inc.Files[0], inc.Files[1])
+	}
+	if p := inc.Files[1].Block("web").Params()[0].Pos(); !strings.HasSuffix(p.String(), filepath.Join("jail.conf.d", "web.conf")+":2:2") {
+		t.Errorf("included position = %s", p)
+	}
+}
+
+func TestParseFile_loop(t *testing.T) {
+	_, err := ParseFile(filepath.Join("testdata", "loop", "jail.conf"))
+	if err == nil || !strings.Contains(err.Error(), "includes nested too deeply") {
+		t.Errorf("ParseFile() error = %v, want includes nested too deeply", err)
+	}
+}
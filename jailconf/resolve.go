@@ -0,0 +1,502 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package jailconf
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/briandowns/jail"
+)
+
+// pseudoParams are the parameters used by jail(8) itself rather than
+// passed to the kernel, mapped to whether they are booleans.
+var pseudoParams = map[string]bool{
+	"exec.prepare":          false,
+	"exec.prestart":         false,
+	"exec.created":          false,
+	"exec.start":            false,
+	"command":               false,
+	"exec.poststart":        false,
+	"exec.prestop":          false,
+	"exec.stop":             false,
+	"exec.poststop":         false,
+	"exec.release":          false,
+	"exec.clean":            true,
+	"exec.jail_user":        false,
+	"exec.system_jail_user": true,
+	"exec.system_user":      false,
+	"exec.timeout":          false,
+	"exec.consolelog":       false,
+	"exec.fib":              false,
+	"stop.timeout":          false,
+	"interface":             false,
+	"vnet.interface":        false,
+	"ip_hostname":           true,
+	"mount":                 false,
+	"mount.fstab":           false,
+	"mount.devfs":           true,
+	"mount.fdescfs":         true,
+	"mount.procfs":          true,
+	"allow.dying":           true,
+	"depend":                false,
+	"zfs.dataset":           false,
+}
+
+// IsPseudoParam reports whether the named parameter is one used by
+// jail(8) itself, such as exec.start or mount.devfs, rather than a
+// jail parameter passed to the kernel.
+func IsPseudoParam(name string) bool {
+	if _, ok := pseudoParams[name]; ok {
+		return true
+	}
+	base, ok := trimNo(name)
+
+	return ok && pseudoParams[base]
+}
+
+// trimNo removes the "no" prefix from the last component of the
+// name, e.g. allow.nomount becomes allow.mount.
+func trimNo(name string) (string, bool) {
+	i := strings.LastIndexByte(name, '.') + 1
+	if !strings.HasPrefix(name[i:], "no") {
+		return name, false
+	}
+
+	return name[:i] + name[i+2:], true
+}
+
+// Jail is the resolved configuration of a single jail.
+type Jail struct {
+	Name string
+
+	// Pos is the position of the jail's block or, for a jail
+	// only matched by a wildcard block, that block.
+	Pos Pos
+
+	// Params holds the jail parameters converted to the types
+	// used by the jail package, ready to be passed to jail.Set.
+	Params jail.Params
+
+	// Pseudo holds the values of the jail(8) pseudo-parameters,
+	// such as exec.start. Booleans hold "true" or "false".
+	Pseudo map[string][]string
+}
+
+// Resolve applies the rules jail(8) uses to build the parameters of
+// every jail defined in the file. The files read by .include
+// directives, when followed by ParseFile, are taken in their place.
+//
+// Each jail starts with the global parameters, those outside of any
+// block, followed by those of every wildcard block whose name
+// matches the jail's, such as * or web*, and then those of its own
+// block. A parameter given with = replaces the value inherited and
+// one given with += appends to it. The name parameter defaults to
+// the block's name.
+//
+// Variables, either $name or ${name}, in unquoted and double quoted
+// values are replaced by the variable of that name, defined as
+// $name = value;, or otherwise the jail's parameter of that name.
+// The jails are returned in the order they are first defined.
+func Resolve(f *File) ([]*Jail, error) {
+	stmts := f.stmts()
+
+	var (
+		names []string
+		seen  = make(map[string]bool)
+	)
+	for _, s := range stmts {
+		if b, ok := s.(*Block); ok && !isPattern(b.Name) && !seen[b.Name] {
+			seen[b.Name] = true
+			names = append(names, b.Name)
+		}
+	}
+
+	jails := make([]*Jail, 0, len(names))
+	for _, name := range names {
+		j, err := resolve(stmts, name, false)
+		if err != nil {
+			return nil, err
+		}
+		jails = append(jails, j)
+	}
+
+	return jails, nil
+}
+
+// ResolveJail resolves the named jail as Resolve does. A jail without
+// a block of its own is resolved from the wildcard blocks matching its
+// name. An error wrapping jail.ErrNotFound is returned when no block
+// applies to the jail.
+func ResolveJail(f *File, name string) (*Jail, error) {
+	return resolve(f.stmts(), name, false)
+}
+
+// stmts returns the statements of the file, with those of the files
+// read by each .include directive in its place.
+func (f *File) stmts() []Stmt {
+	var stmts []Stmt
+	for _, s := range f.Body {
+		inc, ok := s.(*Include)
+		if !ok {
+			stmts = append(stmts, s)
+			continue
+		}
+		for _, sub := range inc.Files {
+			stmts = append(stmts, sub.stmts()...)
+		}
+	}
+
+	return stmts
+}
+
+// isPattern reports whether the jail name is a wildcard.
+func isPattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// assignment is the value of a parameter or variable before
+// variables are expanded.
+type assignment struct {
+	name   string
+	param  *Param
+	values []*Value
+	negate bool
+}
+
+// scope holds the parameters and variables of the jail being
+// resolved.
+type scope struct {
+	name      string
+	params    map[string]*assignment
+	vars      map[string]*assignment
+	order     []*assignment
+	expanding map[*assignment]bool
+}
+
+// resolve resolves the named jail from the statements of a file.
+// When keepGoing is set parameters that cannot be resolved are left
+// out and the jail returned along with the errors joined.
+func resolve(stmts []Stmt, name string, keepGoing bool) (*Jail, error) {
+	s := &scope{
+		name:      name,
+		params:    make(map[string]*assignment),
+		vars:      make(map[string]*assignment),
+		expanding: make(map[*assignment]bool),
+	}
+	j := &Jail{Name: name, Params: jail.NewParams(), Pseudo: make(map[string][]string)}
+
+	for _, st := range stmts {
+		if p, ok := st.(*Param); ok {
+			s.apply(p)
+		}
+	}
+
+	var wild, own []*Block
+	for _, st := range stmts {
+		b, ok := st.(*Block)
+		if !ok {
+			continue
+		}
+		if b.Name == name {
+			own = append(own, b)
+			continue
+		}
+		if ok, _ := path.Match(b.Name, name); ok && isPattern(b.Name) {
+			wild = append(wild, b)
+		}
+	}
+	if len(wild)+len(own) == 0 {
+		return nil, fmt.Errorf("%w: no block for jail %s", jail.ErrNotFound, name)
+	}
+	for _, b := range append(wild, own...) {
+		j.Pos = b.NamePos
+		for _, p := range b.Params() {
+			s.apply(p)
+		}
+	}
+
+	var errs []error
+	for _, a := range s.order {
+		if err := s.set(j, a); err != nil {
+			if !keepGoing {
+				return nil, err
+			}
+			errs = append(errs, err)
+		}
+	}
+	if _, ok := j.Params["name"]; !ok {
+		j.Params["name"] = name
+	}
+
+	return j, errors.Join(errs...)
+}
+
+// set expands the values of the assignment and adds them to the jail.
+func (s *scope) set(j *Jail, a *assignment) error {
+	strs := make([]string, len(a.values))
+	for i, v := range a.values {
+		str, err := s.expand(v)
+		if err != nil {
+			return err
+		}
+		strs[i] = str
+	}
+
+	if isBool, ok := pseudoParams[a.name]; ok {
+		if isBool {
+			b, err := boolValue(a, strs)
+			if err != nil {
+				return err
+			}
+			strs = []string{strconv.FormatBool(b)}
+		}
+		j.Pseudo[a.name] = strs
+		return nil
+	}
+
+	jp, ok := jail.LookupParam(a.name)
+	if !ok {
+		return &Error{Pos: a.param.NamePos, Msg: "unknown parameter " + a.param.Name, Err: jail.ErrUnknownParam}
+	}
+	v, err := convert(jp, a, strs)
+	if err != nil {
+		return err
+	}
+	j.Params[a.name] = v
+
+	return nil
+}
+
+// apply applies a parameter to the scope.
+func (s *scope) apply(p *Param) {
+	m, name, negate := s.params, p.Name, false
+	if p.IsVar() {
+		m, name = s.vars, p.Name[1:]
+	} else {
+		name, negate = canonicalName(p.Name)
+	}
+
+	a, ok := m[name]
+	if !ok {
+		a = &assignment{name: name}
+		m[name] = a
+		if !p.IsVar() {
+			s.order = append(s.order, a)
+		}
+	}
+	a.param, a.negate = p, negate
+
+	switch p.Op {
+	case NoOp:
+		a.values = nil
+	case Assign:
+		a.values = append([]*Value(nil), p.Values...)
+	case Append:
+		a.values = append(a.values, p.Values...)
+	}
+}
+
+// canonicalName returns the name boolean and jailsys parameters given
+// with a "no" prefix are stored under and whether the value is to be
+// negated.
+func canonicalName(name string) (string, bool) {
+	if jp, ok := jail.LookupParam(name); ok {
+		if jp.Flags&jail.JailParamNoBool == 0 {
+			return name, false
+		}
+		return trimNo(name)
+	}
+	base, ok := trimNo(name)
+	if !ok {
+		return name, false
+	}
+	if pseudoParams[base] {
+		return base, true
+	}
+	if jp, ok := jail.LookupParam(base); ok && jp.Flags&jail.JailParamSys != 0 {
+		return base, true
+	}
+
+	return name, false
+}
+
+// expand returns the value with its variables replaced.
+func (s *scope) expand(v *Value) (string, error) {
+	var sb strings.Builder
+	for _, p := range v.Parts {
+		if p.Var == "" {
+			sb.WriteString(p.Text)
+			continue
+		}
+		str, err := s.lookup(p.Var, v.Pos())
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(str)
+	}
+
+	return sb.String(), nil
+}
+
+// lookup returns the expanded value of the variable or parameter
+// of the given name referenced at pos.
+func (s *scope) lookup(name string, pos Pos) (string, error) {
+	a, ok := s.vars[name]
+	if !ok {
+		a, ok = s.params[name]
+	}
+	if !ok {
+		if name == "name" {
+			return s.name, nil
+		}
+		return "", errorf(pos, "undefined variable $"+name)
+	}
+	if s.expanding[a] {
+		return "", errorf(pos, "variable $"+name+" refers to itself")
+	}
+	if len(a.values) != 1 {
+		return "", errorf(pos, "variable $"+name+" does not have a single value")
+	}
+
+	s.expanding[a] = true
+	defer delete(s.expanding, a)
+
+	return s.expand(a.values[0])
+}
+
+// valueError returns an error for the value of the assignment.
+func valueError(a *assignment, err error) error {
+	return &Error{Pos: a.param.NamePos, Msg: a.param.Name + ": " + err.Error(), Err: err}
+}
+
+// single returns the only value of the assignment.
+func single(a *assignment, strs []string) (string, error) {
+	if len(strs) != 1 {
+		return "", valueError(a, fmt.Errorf("%w: a single value is required", jail.ErrInvalidValue))
+	}
+
+	return strs[0], nil
+}
+
+// boolValue returns the value of a boolean parameter. A boolean
+// given without a value is true.
+func boolValue(a *assignment, strs []string) (bool, error) {
+	b := true
+	if len(strs) > 0 {
+		str, err := single(a, strs)
+		if err != nil {
+			return false, err
+		}
+		if b, err = strconv.ParseBool(str); err != nil {
+			return false, valueError(a, fmt.Errorf("%w: %q is not a boolean", jail.ErrInvalidValue, str))
+		}
+	}
+
+	return b != a.negate, nil
+}
+
+// convert converts the expanded values of a jail parameter to the
+// type used by the jail package.
+func convert(jp jail.JailParam, a *assignment, strs []string) (interface{}, error) {
+	var (
+		v   interface{}
+		err error
+	)
+	switch jp.Zero().(type) {
+	case bool:
+		return boolValue(a, strs)
+	case []netip.Addr:
+		// jail(8) allows an interface and netmask to be given
+		// with each address, e.g. em0|192.0.2.1/24.
+		addrs := make([]string, len(strs))
+		for i, str := range strs {
+			addrs[i] = str[strings.IndexByte(str, '|')+1:]
+		}
+		v, err = jp.Convert(addrs)
+	case []byte:
+		var str string
+		if str, err = single(a, strs); err == nil {
+			v = []byte(str)
+		}
+	case string:
+		var str string
+		if str, err = single(a, strs); err == nil {
+			v = str
+		}
+	case jail.JailSys:
+		// As with libjail, a jailsys parameter given without a
+		// value is new and one with the "no" prefix disable.
+		switch {
+		case a.negate && len(strs) == 0:
+			return jail.JailSysDisable, nil
+		case a.negate:
+			return nil, valueError(a, fmt.Errorf("%w: no value can be given with the no prefix", jail.ErrInvalidValue))
+		case len(strs) == 0:
+			return jail.JailSysNew, nil
+		}
+		var str string
+		if str, err = single(a, strs); err == nil {
+			if n, perr := strconv.Atoi(str); perr == nil {
+				v, err = jp.Convert(n)
+			} else {
+				v, err = jp.Convert(str)
+			}
+		}
+	case uint32, uint64:
+		var str string
+		if str, err = single(a, strs); err == nil {
+			var n uint64
+			if n, err = strconv.ParseUint(str, 0, 64); err != nil {
+				return nil, valueError(a, fmt.Errorf("%w: %q is not an unsigned integer", jail.ErrInvalidValue, str))
+			}
+			v, err = jp.Convert(n)
+		}
+	default:
+		var str string
+		if str, err = single(a, strs); err == nil {
+			var n int64
+			if n, err = strconv.ParseInt(str, 0, 64); err != nil {
+				return nil, valueError(a, fmt.Errorf("%w: %q is not an integer", jail.ErrInvalidValue, str))
+			}
+			v, err = jp.Convert(n)
+		}
+	}
+	if err != nil {
+		if _, ok := err.(*Error); ok {
+			return nil, err
+		}
+		return nil, valueError(a, err)
+	}
+
+	return v, nil
+}
@@ -0,0 +1,284 @@
+package jailconf
+
+import (
+	"errors"
+	"net/netip"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/briandowns/jail"
+)
+
+const resolveConf = `
+$domain = example.org;
+exec.start = "/bin/sh /etc/rc";
+mount.devfs;
+persist;
+host.hostname = "$name.$domain";
+ip4.addr = lo1|127.0.1.1;
+
+* {
+	path = "/jails/${name}";
+	securelevel = 2;
+}
+
+web* {
+	allow.nomount;
+}
+
+web {
+	ip4.addr += em0|192.0.2.10/24;
+	ip6.addr = 2001:db8::10;
+	vnet = disable;
+	mount.nodevfs;
+}
+
+db {
+	$domain = db.example.org;
+	nopersist;
+	path = /data/$name;
+	exec.start += "/usr/local/bin/db";
+	enforce_statfs = 1;
+}
+`
+
+func TestResolve(t *testing.T) {
+	f, err := Parse("jail.conf", []byte(resolveConf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jails, err := Resolve(f)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if len(jails) != 2 || jails[0].Name != "web" || jails[1].Name != "db" {
+		t.Fatalf("Resolve() = %+v, want web and db", jails)
+	}
+
+	web := jails[0]
+	wantWeb := jail.Params{
+		"name":          "web",
+		"persist":       true,
+		"host.hostname": "web.example.org",
+		"ip4.addr":      []netip.Addr{netip.MustParseAddr("127.0.1.1"), netip.MustParseAddr("192.0.2.10")},
+		"ip6.addr":      []netip.Addr{netip.MustParseAddr("2001:db8::10")},
+		"path":          "/jails/web",
+		"securelevel":   int32(2),
+		"allow.mount":   false,
+		"vnet":          jail.JailSysDisable,
+	}
+	if !reflect.DeepEqual(web.Params, wantWeb) {
+		t.Errorf("web Params = %v, want %v", web.Params, wantWeb)
+	}
+	wantPseudo := map[string][]string{
+		"exec.start":  {"/bin/sh /etc/rc"},
+		"mount.devfs": {"false"},
+	}
+	if !reflect.DeepEqual(web.Pseudo, wantPseudo) {
+		t.Errorf("web Pseudo = %v, want %v", web.Pseudo, wantPseudo)
+	}
+	if web.Pos.Line != 18 {
+		t.Errorf("web Pos = %s, want line 18", web.Pos)
+	}
+
+	db := jails[1]
+	wantDB := jail.Params{
+		"name":           "db",
+		"persist":        false,
+		"host.hostname":  "db.db.example.org",
+		"ip4.addr":       []netip.Addr{netip.MustParseAddr("127.0.1.1")},
+		"path":           "/data/db",
+		"securelevel":    int32(2),
+		"enforce_statfs": int32(1),
+	}
+	if !reflect.DeepEqual(db.Params, wantDB) {
+		t.Errorf("db Params = %v, want %v", db.Params, wantDB)
+	}
+	if got := db.Pseudo["exec.start"]; !reflect.DeepEqual(got, []string{"/bin/sh /etc/rc", "/usr/local/bin/db"}) {
+		t.Errorf("db exec.start = %q", got)
+	}
+	if err := db.Params.Validate(); err != nil {
+		t.Errorf("db Params.Validate() error = %v", err)
+	}
+}
+
+func TestResolve_join(t *testing.T) {
+	f, err := Parse("jail.conf", []byte(`
+$domain = example.org;
+web {
+	path = "/usr/jails/"$name;
+	host.hostname = ${name}"."$domain;
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := ResolveJail(f, "web")
+	if err != nil {
+		t.Fatalf("ResolveJail() error = %v", err)
+	}
+	if j.Params["path"] != "/usr/jails/web" || j.Params["host.hostname"] != "web.example.org" {
+		t.Errorf("ResolveJail() = %v", j.Params)
+	}
+}
+
+func TestResolve_jailsys(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "no value", src: "web { vnet; }", want: jail.JailSysNew},
+		{name: "new", src: "web { vnet = new; }", want: jail.JailSysNew},
+		{name: "inherit", src: "web { vnet = inherit; }", want: jail.JailSysInherit},
+		{name: "no prefix", src: "web { novnet; }", want: jail.JailSysDisable},
+		{name: "no prefix overrides", src: "vnet;\nweb { novnet; }", want: jail.JailSysDisable},
+		{name: "no prefix with value", src: "web { novnet = new; }", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse("jail.conf", []byte(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			j, err := ResolveJail(f, "web")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveJail() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && j.Params["vnet"] != tt.want {
+				t.Errorf("ResolveJail() vnet = %v, want %v", j.Params["vnet"], tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveJail(t *testing.T) {
+	f, err := Parse("jail.conf", []byte(resolveConf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := ResolveJail(f, "webdev")
+	if err != nil {
+		t.Fatalf("ResolveJail() error = %v", err)
+	}
+	if j.Params["path"] != "/jails/webdev" || j.Params["allow.mount"] != false {
+		t.Errorf("ResolveJail() = %v", j.Params)
+	}
+
+	f, err = Parse("jail.conf", []byte("web {\n\tpersist;\n}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ResolveJail(f, "db"); !errors.Is(err, jail.ErrNotFound) {
+		t.Errorf("ResolveJail() error = %v, want %v", err, jail.ErrNotFound)
+	}
+}
+
+func TestResolve_include(t *testing.T) {
+	f, err := ParseFile(filepath.Join("testdata", "include", "jail.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jails, err := Resolve(f)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if len(jails) != 2 || jails[0].Params["path"] != "/jails/db" || jails[1].Params["path"] != "/jails/web" {
+		t.Errorf("Resolve() = %+v", jails)
+	}
+}
+
+func TestResolve_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "undefined variable",
+			src:  "web {\n\tpath = /jails/$root;\n}\n",
+			want: "jail.conf:2:9: undefined variable $root",
+		},
+		{
+			name: "self reference",
+			src:  "web {\n\t$a = $b;\n\t$b = x$a;\n\tpath = $a;\n}\n",
+			want: "jail.conf:3:7: variable $a refers to itself",
+		},
+		{
+			name: "list reference",
+			src:  "web {\n\tip4.addr = 192.0.2.1, 192.0.2.2;\n\tpath = /${ip4.addr};\n}\n",
+			want: "jail.conf:3:9: variable $ip4.addr does not have a single value",
+		},
+		{
+			name:    "unknown parameter",
+			src:     "web {\n\tallow.raw_socket;\n}\n",
+			want:    "jail.conf:2:2: unknown parameter allow.raw_socket",
+			wantErr: jail.ErrUnknownParam,
+		},
+		{
+			name:    "bad integer",
+			src:     "web {\n\tsecurelevel = high;\n}\n",
+			want:    `jail.conf:2:2: securelevel: invalid param provided: "high" is not an integer`,
+			wantErr: jail.ErrInvalidValue,
+		},
+		{
+			name:    "bad boolean",
+			src:     "web {\n\tpersist = maybe;\n}\n",
+			wantErr: jail.ErrInvalidValue,
+		},
+		{
+			name:    "bad address",
+			src:     "web {\n\tip6.addr = 192.0.2.1;\n}\n",
+			wantErr: jail.ErrInvalidValue,
+		},
+		{
+			name:    "multiple strings",
+			src:     "web {\n\tpath = /a, /b;\n}\n",
+			wantErr: jail.ErrInvalidValue,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse("jail.conf", []byte(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = Resolve(f)
+			if err == nil {
+				t.Fatal("Resolve() error = nil")
+			}
+			if tt.want != "" && err.Error() != tt.want {
+				t.Errorf("Resolve() error = %v, want %s", err, tt.want)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Resolve() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsPseudoParam(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "exec.start", want: true},
+		{name: "mount.nodevfs", want: true},
+		{name: "exec.nostart", want: false},
+		{name: "persist", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPseudoParam(tt.name); got != tt.want {
+				t.Errorf("IsPseudoParam() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,453 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package jailconf
+
+import (
+	"strconv"
+	"strings"
+)
+
+// token is the kind of a lexical token.
+type token int
+
+const (
+	tokEOF     token = iota
+	tokComment       // #, // or /* */ comment
+	tokString        // quoted or unquoted string
+	tokLbrace        // {
+	tokRbrace        // }
+	tokSemi          // ;
+	tokComma         // ,
+	tokAssign        // =
+	tokAppend        // +=
+)
+
+var tokenNames = [...]string{
+	tokEOF:     "end of file",
+	tokComment: "comment",
+	tokString:  "string",
+	tokLbrace:  "'{'",
+	tokRbrace:  "'}'",
+	tokSemi:    "';'",
+	tokComma:   "','",
+	tokAssign:  "'='",
+	tokAppend:  "'+='",
+}
+
+// String returns a description of the token for error messages.
+func (t token) String() string {
+	return tokenNames[t]
+}
+
+// Error is an error at a position in a file. Err holds the
+// underlying error, if any, such as jail.ErrUnknownParam.
+type Error struct {
+	Pos Pos
+	Msg string
+	Err error
+}
+
+// Error returns the error in the form file:line:col: msg.
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// scanner splits a file in to tokens.
+type scanner struct {
+	src []byte
+	pos Pos
+
+	// touching reports whether the last token scanned directly
+	// follows the one before it, with no white space or comment
+	// in between. end is the offset following that token.
+	touching bool
+	end      int
+}
+
+// newScanner returns a scanner for src.
+func newScanner(filename string, src []byte) *scanner {
+	return &scanner{
+		src: src,
+		pos: Pos{Filename: filename, Line: 1, Col: 1},
+	}
+}
+
+// peek returns the byte n bytes ahead or 0 at the end of the file.
+func (s *scanner) peek(n int) byte {
+	if s.pos.Offset+n < len(s.src) {
+		return s.src[s.pos.Offset+n]
+	}
+
+	return 0
+}
+
+// eof reports whether the whole file has been read.
+func (s *scanner) eof() bool {
+	return s.pos.Offset >= len(s.src)
+}
+
+// next consumes a byte.
+func (s *scanner) next() byte {
+	c := s.src[s.pos.Offset]
+	s.pos.Offset++
+	if c == '\n' {
+		s.pos.Line++
+		s.pos.Col = 1
+	} else {
+		s.pos.Col++
+	}
+
+	return c
+}
+
+// errorf returns an *Error at p.
+func errorf(p Pos, msg string) *Error {
+	return &Error{Pos: p, Msg: msg}
+}
+
+// isSpace reports whether c is white space.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+// isWordByte reports whether c may be part of an unquoted string.
+func isWordByte(c byte) bool {
+	if isSpace(c) {
+		return false
+	}
+	switch c {
+	case 0, ';', ',', '=', '{', '}', '"', '\'', '#':
+		return false
+	}
+
+	return true
+}
+
+// isVarByte reports whether c may be part of the name of a
+// variable referenced without braces.
+func isVarByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// scan returns the next token. For strings the parsed value is
+// returned and for comments the text.
+func (s *scanner) scan() (tok token, pos Pos, val *Value, lit string, err error) {
+	for !s.eof() && isSpace(s.peek(0)) {
+		s.next()
+	}
+
+	start := s.pos
+	if s.eof() {
+		return tokEOF, start, nil, "", nil
+	}
+
+	s.touching = start.Offset > 0 && start.Offset == s.end
+	defer func() {
+		if tok != tokComment {
+			s.end = s.pos.Offset
+		}
+	}()
+
+	switch c := s.peek(0); {
+	case c == '#' || c == '/' && s.peek(1) == '/':
+		for !s.eof() && s.peek(0) != '\n' {
+			s.next()
+		}
+		return tokComment, start, nil, s.text(start), nil
+	case c == '/' && s.peek(1) == '*':
+		s.next()
+		s.next()
+		for !(s.peek(0) == '*' && s.peek(1) == '/') {
+			if s.eof() {
+				return 0, start, nil, "", errorf(start, "unterminated comment")
+			}
+			s.next()
+		}
+		s.next()
+		s.next()
+		return tokComment, start, nil, s.text(start), nil
+	case c == '{':
+		s.next()
+		return tokLbrace, start, nil, "{", nil
+	case c == '}':
+		s.next()
+		return tokRbrace, start, nil, "}", nil
+	case c == ';':
+		s.next()
+		return tokSemi, start, nil, ";", nil
+	case c == ',':
+		s.next()
+		return tokComma, start, nil, ",", nil
+	case c == '=':
+		s.next()
+		return tokAssign, start, nil, "=", nil
+	case c == '+' && s.peek(1) == '=':
+		s.next()
+		s.next()
+		return tokAppend, start, nil, "+=", nil
+	case c == '"' || c == '\'':
+		v, err := s.quoted(c)
+		return tokString, start, v, "", err
+	case isWordByte(c):
+		v, err := s.word()
+		return tokString, start, v, "", err
+	default:
+		s.next()
+		return 0, start, nil, "", errorf(start, "unexpected character "+strconv.QuoteRune(rune(c)))
+	}
+}
+
+// text returns the source from start to the current position.
+func (s *scanner) text(start Pos) string {
+	return string(s.src[start.Offset:s.pos.Offset])
+}
+
+// word scans an unquoted string. It ends at white space, a special
+// character or the start of a += operator.
+func (s *scanner) word() (*Value, error) {
+	start := s.pos
+	var b parts
+	for !s.eof() {
+		c := s.peek(0)
+		if !isWordByte(c) || c == '+' && s.peek(1) == '=' {
+			break
+		}
+		if err := s.char(&b); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Value{ValuePos: start, ValueEnd: s.pos, Raw: s.text(start), Parts: b.done()}, nil
+}
+
+// quoted scans a string in the given quotes. Single quoted strings
+// are taken literally, double quoted ones may contain escapes and
+// variable references.
+func (s *scanner) quoted(q byte) (*Value, error) {
+	start := s.pos
+	s.next()
+
+	var b parts
+	for {
+		if s.eof() {
+			return nil, errorf(start, "unterminated string")
+		}
+		c := s.peek(0)
+		if c == q {
+			s.next()
+			break
+		}
+		if q == '\'' {
+			b.text.WriteByte(s.next())
+			continue
+		}
+		if err := s.char(&b); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Value{ValuePos: start, ValueEnd: s.pos, Quote: q, Raw: s.text(start), Parts: b.done()}, nil
+}
+
+// char scans a single character, escape sequence or variable
+// reference of an unquoted or double quoted string.
+func (s *scanner) char(b *parts) error {
+	switch s.peek(0) {
+	case '\\':
+		return s.escape(b)
+	case '$':
+		return s.variable(b)
+	}
+	b.text.WriteByte(s.next())
+
+	return nil
+}
+
+// escape scans a backslash escape sequence.
+func (s *scanner) escape(b *parts) error {
+	p := s.pos
+	s.next()
+	if s.eof() {
+		return errorf(p, "unterminated escape sequence")
+	}
+
+	c := s.next()
+	switch c {
+	case '\n':
+		// A backslash before a newline continues the string.
+	case 'a':
+		b.text.WriteByte('\a')
+	case 'b':
+		b.text.WriteByte('\b')
+	case 'f':
+		b.text.WriteByte('\f')
+	case 'n':
+		b.text.WriteByte('\n')
+	case 'r':
+		b.text.WriteByte('\r')
+	case 't':
+		b.text.WriteByte('\t')
+	case 'v':
+		b.text.WriteByte('\v')
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		n := int(c - '0')
+		for i := 0; i < 2 && '0' <= s.peek(0) && s.peek(0) <= '7'; i++ {
+			n = n*8 + int(s.next()-'0')
+		}
+		if n > 0xff {
+			return errorf(p, "octal escape value out of range")
+		}
+		b.text.WriteByte(byte(n))
+	case 'x':
+		n, digits := 0, 0
+		for ; digits < 2; digits++ {
+			d, ok := unhex(s.peek(0))
+			if !ok {
+				break
+			}
+			s.next()
+			n = n*16 + d
+		}
+		if digits == 0 {
+			return errorf(p, "invalid hexadecimal escape")
+		}
+		b.text.WriteByte(byte(n))
+	default:
+		b.text.WriteByte(c)
+	}
+
+	return nil
+}
+
+// unhex returns the value of a hexadecimal digit.
+func unhex(c byte) (int, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c - '0'), true
+	case 'a' <= c && c <= 'f':
+		return int(c-'a') + 10, true
+	case 'A' <= c && c <= 'F':
+		return int(c-'A') + 10, true
+	}
+
+	return 0, false
+}
+
+// variable scans a $name or ${name} variable reference. A $ that
+// does not start a reference is taken literally.
+func (s *scanner) variable(b *parts) error {
+	p := s.pos
+	s.next()
+
+	if s.peek(0) == '{' {
+		s.next()
+		n := s.pos.Offset
+		for s.peek(0) != '}' {
+			if s.eof() || s.peek(0) == '\n' {
+				return errorf(p, "unterminated variable reference")
+			}
+			s.next()
+		}
+		name := string(s.src[n:s.pos.Offset])
+		s.next()
+		if name == "" {
+			return errorf(p, "empty variable name")
+		}
+		b.variable(name)
+		return nil
+	}
+
+	n := s.pos.Offset
+	for !s.eof() && isVarByte(s.peek(0)) {
+		s.next()
+	}
+	if n == s.pos.Offset {
+		b.text.WriteByte('$')
+		return nil
+	}
+	b.variable(string(s.src[n:s.pos.Offset]))
+
+	return nil
+}
+
+// parts accumulates the parts of a value.
+type parts struct {
+	list []Part
+	text strings.Builder
+}
+
+// flush adds any pending text as a part.
+func (b *parts) flush() {
+	if b.text.Len() > 0 {
+		b.list = append(b.list, Part{Text: b.text.String()})
+		b.text.Reset()
+	}
+}
+
+// variable adds a variable reference.
+func (b *parts) variable(name string) {
+	b.flush()
+	b.list = append(b.list, Part{Var: name})
+}
+
+// done returns the parts of the value. An empty value has a
+// single empty part.
+func (b *parts) done() []Part {
+	b.flush()
+	if len(b.list) == 0 {
+		return []Part{{}}
+	}
+
+	return b.list
+}
+
+// join returns the value of a followed directly by b, as jail(8)
+// reads strings written with nothing between them, e.g.
+// "/jails/"$name. Quote is that of a.
+func join(a, b *Value) *Value {
+	v := &Value{ValuePos: a.ValuePos, ValueEnd: b.ValueEnd, Quote: a.Quote, Raw: a.Raw + b.Raw}
+
+	var pb parts
+	for _, list := range [][]Part{a.Parts, b.Parts} {
+		for _, p := range list {
+			if p.Var != "" {
+				pb.variable(p.Var)
+				continue
+			}
+			pb.text.WriteString(p.Text)
+		}
+	}
+	v.Parts = pb.done()
+
+	return v
+}
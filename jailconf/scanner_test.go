@@ -0,0 +1,134 @@
+package jailconf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_scanner_scan(t *testing.T) {
+	tests := []struct {
+		name      string
+		src       string
+		wantQuote byte
+		wantRaw   string
+		wantParts []Part
+		wantErr   string
+	}{
+		{
+			name:      "unquoted",
+			src:       "/jails/web;",
+			wantRaw:   "/jails/web",
+			wantParts: []Part{{Text: "/jails/web"}},
+		},
+		{
+			name:      "unquoted stops at +=",
+			src:       "ip4.addr+=",
+			wantRaw:   "ip4.addr",
+			wantParts: []Part{{Text: "ip4.addr"}},
+		},
+		{
+			name:      "unquoted escape",
+			src:       `a\ b`,
+			wantRaw:   `a\ b`,
+			wantParts: []Part{{Text: "a b"}},
+		},
+		{
+			name:      "double quoted",
+			src:       `"/bin/sh /etc/rc"`,
+			wantQuote: '"',
+			wantRaw:   `"/bin/sh /etc/rc"`,
+			wantParts: []Part{{Text: "/bin/sh /etc/rc"}},
+		},
+		{
+			name:      "escapes",
+			src:       `"a\"b\\c\n\t\101\x41\$d"`,
+			wantQuote: '"',
+			wantRaw:   `"a\"b\\c\n\t\101\x41\$d"`,
+			wantParts: []Part{{Text: "a\"b\\c\n\tAA$d"}},
+		},
+		{
+			name:      "line continuation",
+			src:       "\"a\\\nb\"",
+			wantQuote: '"',
+			wantRaw:   "\"a\\\nb\"",
+			wantParts: []Part{{Text: "ab"}},
+		},
+		{
+			name:      "single quoted",
+			src:       `'$name\n'`,
+			wantQuote: '\'',
+			wantRaw:   `'$name\n'`,
+			wantParts: []Part{{Text: `$name\n`}},
+		},
+		{
+			name:      "empty",
+			src:       `""`,
+			wantQuote: '"',
+			wantRaw:   `""`,
+			wantParts: []Part{{}},
+		},
+		{
+			name:      "variables",
+			src:       `"/jails/$name/${host.hostname}x$"`,
+			wantQuote: '"',
+			wantRaw:   `"/jails/$name/${host.hostname}x$"`,
+			wantParts: []Part{{Text: "/jails/"}, {Var: "name"}, {Text: "/"}, {Var: "host.hostname"}, {Text: "x$"}},
+		},
+		{
+			name:      "unquoted variable",
+			src:       "$ip;",
+			wantRaw:   "$ip",
+			wantParts: []Part{{Var: "ip"}},
+		},
+		{name: "unterminated string", src: `"abc`, wantErr: "1:1: unterminated string"},
+		{name: "unterminated variable", src: `"${abc"`, wantErr: "1:2: unterminated variable reference"},
+		{name: "empty variable", src: `${}`, wantErr: "1:1: empty variable name"},
+		{name: "bad hex", src: `"\xg"`, wantErr: "1:2: invalid hexadecimal escape"},
+		{name: "bad octal", src: `"\777"`, wantErr: "1:2: octal escape value out of range"},
+		{name: "unterminated comment", src: "/* abc", wantErr: "1:1: unterminated comment"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tok, _, v, _, err := newScanner("", []byte(tt.src)).scan()
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("scanner.scan() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("scanner.scan() error = %v", err)
+			}
+			if tok != tokString {
+				t.Fatalf("scanner.scan() = %v, want %v", tok, tokString)
+			}
+			if v.Quote != tt.wantQuote || v.Raw != tt.wantRaw || !reflect.DeepEqual(v.Parts, tt.wantParts) {
+				t.Errorf("scanner.scan() = %c %q %#v, want %c %q %#v", v.Quote, v.Raw, v.Parts, tt.wantQuote, tt.wantRaw, tt.wantParts)
+			}
+		})
+	}
+}
+
+func Test_scanner_tokens(t *testing.T) {
+	src := "web { a = b, c; d += e; # x\n// y\n/* z */ }"
+	want := []token{
+		tokString, tokLbrace, tokString, tokAssign, tokString, tokComma, tokString, tokSemi,
+		tokString, tokAppend, tokString, tokSemi, tokComment, tokComment, tokComment, tokRbrace, tokEOF,
+	}
+
+	s := newScanner("", []byte(src))
+	var got []token
+	for {
+		tok, _, _, _, err := s.scan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, tok)
+		if tok == tokEOF {
+			break
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("scanner.scan() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,600 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+// Package jailtest provides an in-memory jail.Backend that simulates the
+// FreeBSD kernel. It allows code built on the jail package to be exercised
+// off of a FreeBSD host:
+//
+//	b := jailtest.New()
+//	defer jail.SetBackend(jail.SetBackend(b))
+//
+// The simulation covers JID allocation, name uniqueness, the create, update
+// and dying flags, persistence and the errno values documented in jail(2).
+package jailtest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/briandowns/jail"
+	"golang.org/x/sys/unix"
+)
+
+// errno values returned by the simulated kernel. These mirror the values
+// in the jail package's errors.go which were copied from FreeBSD's errno.h
+// so they map to the same errors regardless of the host being run on.
+const (
+	eperm        = unix.Errno(1)
+	enoent       = unix.Errno(2)
+	eexist       = unix.Errno(17)
+	einval       = unix.Errno(22)
+	eagain       = unix.Errno(35)
+	enametoolong = unix.Errno(63)
+)
+
+// limits enforced by the kernel on string parameters.
+const (
+	maxHostNameLen = 256
+	maxPathLen     = 1024
+)
+
+// Jail is a snapshot of a jail held by the simulated kernel.
+type Jail struct {
+	JID     int32
+	Name    string
+	Persist bool
+	Dying   bool
+	Procs   int
+	Params  jail.Params
+}
+
+// prison is a jail as tracked by the Backend.
+type prison struct {
+	jid     int32
+	name    string
+	persist bool
+	dying   bool
+	procs   int
+	params  jail.Params
+}
+
+// value returns the current value of the named parameter.
+func (p *prison) value(k string, jp jail.JailParam) interface{} {
+	switch k {
+	case "jid":
+		return p.jid
+	case "name":
+		return p.name
+	case "persist":
+		return p.persist
+	case "dying":
+		return p.dying
+	}
+	if v, ok := p.params[k]; ok {
+		return v
+	}
+
+	return jp.Zero()
+}
+
+// alive reports whether the prison is not dying.
+func (p *prison) alive() bool {
+	return !p.dying
+}
+
+// Backend is an in-memory jail.Backend. The zero value is not usable,
+// create one with New.
+type Backend struct {
+	// MaxJID is the highest JID that will be allocated. It defaults
+	// to jail.MaxChildJails.
+	MaxJID int32
+
+	// Unprivileged makes every call that requires the super-user
+	// fail with EPERM.
+	Unprivileged bool
+
+	mu       sync.Mutex
+	jails    map[int32]*prison
+	lastJID  int32
+	attached int32
+}
+
+// New creates a new Backend with no jails.
+func New() *Backend {
+	return &Backend{
+		MaxJID: int32(jail.MaxChildJails),
+		jails:  make(map[int32]*prison),
+	}
+}
+
+// Jails returns a snapshot of every jail, including dying jails,
+// ordered by JID.
+func (b *Backend) Jails() []Jail {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	jails := make([]Jail, 0, len(b.jails))
+	for _, pr := range b.jails {
+		params := jail.NewParams()
+		for k, v := range pr.params {
+			params[k] = v
+		}
+		jails = append(jails, Jail{
+			JID:     pr.jid,
+			Name:    pr.name,
+			Persist: pr.persist,
+			Dying:   pr.dying,
+			Procs:   pr.procs,
+			Params:  params,
+		})
+	}
+	sort.Slice(jails, func(i, j int) bool {
+		return jails[i].JID < jails[j].JID
+	})
+
+	return jails
+}
+
+// Attached returns the JID the calling process has been attached to,
+// or 0 if it has not been attached to a jail.
+func (b *Backend) Attached() int32 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.attached
+}
+
+// Release simulates every process in the jail exiting. A jail that is
+// neither persistent nor holding processes is then removed.
+func (b *Backend) Release(jid int32) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pr, ok := b.jails[jid]
+	if !ok {
+		return
+	}
+	if b.attached == jid {
+		b.attached = 0
+	}
+	pr.procs = 0
+	b.deref(pr)
+}
+
+// Jail simulates jail(2). The jail is created with the options' path,
+// name, hostname and address and the calling process is attached to it.
+func (b *Backend) Jail(o *jail.Opts) (int32, error) {
+	if o.Version != 2 {
+		return 0, einval
+	}
+
+	params, err := o.Params()
+	if err != nil {
+		return 0, einval
+	}
+
+	return b.Set(params, jail.CreateFlag|jail.AttachFlag)
+}
+
+// Set simulates jail_set(2).
+func (b *Backend) Set(params jail.Params, flags uintptr) (int32, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r := &request{params: params}
+	jid := b.set(r, flags)
+	r.done()
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	return jid, nil
+}
+
+// set performs jail_set(2) against the held jails.
+func (b *Backend) set(r *request, flags uintptr) int32 {
+	if flags&^jail.SetMaskFlag != 0 {
+		return r.fail(einval, "unknown flags")
+	}
+	create := flags&jail.CreateFlag != 0
+	update := flags&jail.UpdateFlag != 0
+	if !create && !update {
+		return r.fail(einval, "neither create nor update specified")
+	}
+	if b.Unprivileged {
+		return r.fail(eperm, "jail creation not permitted")
+	}
+
+	values := make(map[string]interface{}, len(r.params))
+	for k, v := range r.params {
+		if k == "errmsg" {
+			continue
+		}
+		p, ok := jail.LookupParam(k)
+		if !ok {
+			return r.fail(einval, "unknown parameter: %s", k)
+		}
+		if p.ReadOnly() {
+			return r.fail(einval, "parameter is read-only: %s", k)
+		}
+		cv, err := p.Convert(v)
+		if err != nil {
+			return r.fail(einval, "%s: wrong size or type", k)
+		}
+		if p.Flags&jail.JailParamNoBool != 0 {
+			k, cv = boolName(k), !cv.(bool)
+		}
+		if _, ok := values[k]; ok {
+			return r.fail(einval, "conflicting parameters %s and its negation", k)
+		}
+		values[k] = cv
+	}
+
+	var jid int32
+	if v, ok := values["jid"]; ok {
+		jid = v.(int32)
+		if jid < 0 {
+			return r.fail(einval, "negative jid")
+		}
+	}
+
+	persist, hasPersist := values["persist"].(bool)
+
+	name, hasName := values["name"].(string)
+	if hasName {
+		if len(name) >= maxHostNameLen {
+			return r.fail(enametoolong, "name too long")
+		}
+		if n, err := strconv.ParseInt(name, 10, 32); err == nil && int32(n) != jid {
+			return r.fail(einval, "name cannot be numeric (unless it is the jid)")
+		}
+	}
+	if path, ok := values["path"].(string); ok && len(path) >= maxPathLen {
+		return r.fail(enametoolong, "path too long")
+	}
+	for _, k := range []string{"host.hostname", "host.domainname", "host.hostuuid"} {
+		if s, ok := values[k].(string); ok && len(s) >= maxHostNameLen {
+			return r.fail(enametoolong, "%s too long", k)
+		}
+	}
+
+	var pr *prison
+	if jid != 0 {
+		pr = b.jails[jid]
+		if pr != nil {
+			if create && !update {
+				return r.fail(eexist, "jail %d already exists", jid)
+			}
+			if !pr.alive() && flags&jail.DyingFlag == 0 {
+				return r.fail(enoent, "jail %d is dying", jid)
+			}
+		} else if !create {
+			return r.fail(enoent, "jail %d not found", jid)
+		}
+		if pr != nil && hasName && name != pr.name {
+			if other := b.byName(name, flags); other != nil && other != pr {
+				return r.fail(eexist, "jail \"%s\" already exists", name)
+			}
+		}
+	} else if hasName {
+		pr = b.byName(name, flags)
+		if pr != nil && !update {
+			return r.fail(eexist, "jail \"%s\" already exists", name)
+		}
+		if pr == nil && !create {
+			return r.fail(enoent, "jail \"%s\" not found", name)
+		}
+	} else if !create {
+		return r.fail(enoent, "update specified no jail")
+	}
+
+	if pr == nil {
+		if jid == 0 {
+			if jid = b.allocJID(); jid == 0 {
+				return r.fail(eagain, "no available jail IDs")
+			}
+		} else if jid > b.MaxJID {
+			return r.fail(einval, "jid %d out of range", jid)
+		}
+		pr = &prison{
+			jid:    jid,
+			name:   strconv.Itoa(int(jid)),
+			params: jail.NewParams(),
+		}
+		b.jails[jid] = pr
+	}
+
+	if hasName {
+		pr.name = name
+	}
+	for k, v := range values {
+		switch k {
+		case "jid", "name", "persist":
+			continue
+		}
+		pr.params[k] = v
+	}
+	if hasPersist {
+		pr.persist = persist
+		if persist {
+			pr.dying = false
+		}
+	}
+	if flags&jail.AttachFlag != 0 {
+		b.attach(pr)
+	}
+	b.deref(pr)
+
+	return pr.jid
+}
+
+// Get simulates jail_get(2).
+func (b *Backend) Get(params jail.Params, flags uintptr) (int32, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r := &request{params: params}
+	jid := b.get(r, flags)
+	r.done()
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	return jid, nil
+}
+
+// get performs jail_get(2) against the held jails.
+func (b *Backend) get(r *request, flags uintptr) int32 {
+	if flags&^jail.GetMaskFlag != 0 {
+		return r.fail(einval, "unknown flags")
+	}
+	dying := flags&jail.DyingFlag != 0
+
+	for k := range r.params {
+		if k == "errmsg" {
+			continue
+		}
+		if _, ok := jail.LookupParam(k); !ok {
+			return r.fail(einval, "unknown parameter: %s", k)
+		}
+	}
+
+	var pr *prison
+	jid, hasJID, ok := r.int32("jid")
+	if !ok {
+		return r.fail(einval, "jid: wrong size or type")
+	}
+	lastJID, hasLastJID, ok := r.int32("lastjid")
+	if !ok {
+		return r.fail(einval, "lastjid: wrong size or type")
+	}
+	switch {
+	case hasJID && jid != 0:
+		pr = b.jails[jid]
+		if pr == nil {
+			return r.fail(enoent, "jail %d not found", jid)
+		}
+		if !pr.alive() && !dying {
+			return r.fail(enoent, "jail %d is dying", jid)
+		}
+	case hasLastJID:
+		for _, id := range b.sortedJIDs() {
+			if id > lastJID && (b.jails[id].alive() || dying) {
+				pr = b.jails[id]
+				break
+			}
+		}
+		if pr == nil {
+			return r.fail(enoent, "no jail after %d", lastJID)
+		}
+	default:
+		name, hasName := r.params["name"].(string)
+		if !hasName {
+			return r.fail(enoent, "no jail specified")
+		}
+		if n, err := strconv.ParseInt(name, 10, 32); err == nil {
+			pr = b.jails[int32(n)]
+			if pr != nil && !pr.alive() && !dying {
+				pr = nil
+			}
+		} else {
+			pr = b.byName(name, flags)
+		}
+		if pr == nil {
+			return r.fail(enoent, "jail \"%s\" not found", name)
+		}
+	}
+
+	for k := range r.params {
+		if k == "errmsg" || k == "lastjid" {
+			continue
+		}
+		p, _ := jail.LookupParam(k)
+		if p.Flags&jail.JailParamNoBool != 0 {
+			r.params[k] = !pr.value(boolName(k), p).(bool)
+			continue
+		}
+		r.params[k] = pr.value(k, p)
+	}
+
+	return pr.jid
+}
+
+// Attach simulates jail_attach(2) for the calling process.
+func (b *Backend) Attach(jid int32) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.Unprivileged {
+		return eperm
+	}
+	pr, ok := b.jails[jid]
+	if !ok || !pr.alive() {
+		return einval
+	}
+	b.attach(pr)
+
+	return nil
+}
+
+// Remove simulates jail_remove(2). Every process in the jail is killed and
+// the jail is removed once they have exited. The simulated processes exit
+// when Release is called, until then the jail is left dying.
+func (b *Backend) Remove(jid int32) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.Unprivileged {
+		return eperm
+	}
+	pr, ok := b.jails[jid]
+	if !ok || !pr.alive() {
+		return einval
+	}
+	pr.persist = false
+	pr.dying = true
+	b.deref(pr)
+
+	return nil
+}
+
+// attach moves the calling process in to the given prison.
+func (b *Backend) attach(pr *prison) {
+	if cur, ok := b.jails[b.attached]; ok {
+		cur.procs--
+		b.deref(cur)
+	}
+	pr.procs++
+	b.attached = pr.jid
+}
+
+// deref removes the prison once it is no longer persistent
+// and holds no processes.
+func (b *Backend) deref(pr *prison) {
+	if pr.persist {
+		return
+	}
+	if pr.procs > 0 {
+		return
+	}
+	delete(b.jails, pr.jid)
+}
+
+// byName returns the jail with the given name. Dying jails are
+// only considered when the dying flag has been given.
+func (b *Backend) byName(name string, flags uintptr) *prison {
+	for _, id := range b.sortedJIDs() {
+		pr := b.jails[id]
+		if pr.name == name && (pr.alive() || flags&jail.DyingFlag != 0) {
+			return pr
+		}
+	}
+
+	return nil
+}
+
+// allocJID returns the next free JID after the last one allocated
+// wrapping at MaxJID, or 0 if every JID is in use.
+func (b *Backend) allocJID() int32 {
+	jid := b.lastJID
+	for i := int32(0); i < b.MaxJID; i++ {
+		jid++
+		if jid > b.MaxJID {
+			jid = 1
+		}
+		if _, ok := b.jails[jid]; !ok {
+			b.lastJID = jid
+			return jid
+		}
+	}
+
+	return 0
+}
+
+// sortedJIDs returns the JIDs of every held jail in ascending order.
+func (b *Backend) sortedJIDs() []int32 {
+	ids := make([]int32, 0, len(b.jails))
+	for id := range b.jails {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	return ids
+}
+
+// request tracks the params and outcome of a single call.
+type request struct {
+	params jail.Params
+	err    error
+	errmsg string
+}
+
+// fail records the errno and message for the request the same way
+// the kernel fills in the errmsg parameter.
+func (r *request) fail(errno unix.Errno, format string, args ...interface{}) int32 {
+	r.err = errno
+	r.errmsg = fmt.Sprintf(format, args...)
+
+	return -1
+}
+
+// done writes the error message back in to the errmsg parameter
+// if it was requested.
+func (r *request) done() {
+	if _, ok := r.params["errmsg"]; ok {
+		r.params["errmsg"] = r.errmsg
+	}
+}
+
+// int32 returns the named parameter as an int32, whether it was
+// present and whether it could be converted.
+func (r *request) int32(name string) (int32, bool, bool) {
+	v, ok := r.params[name]
+	if !ok {
+		return 0, false, true
+	}
+	p, _ := jail.LookupParam(name)
+	cv, err := p.Convert(v)
+	if err != nil {
+		return 0, true, false
+	}
+
+	return cv.(int32), true, true
+}
+
+// boolName returns the name of the boolean parameter the given
+// "no" prefixed name negates.
+func boolName(name string) string {
+	i := strings.LastIndexByte(name, '.') + 1
+
+	return name[:i] + strings.TrimPrefix(name[i:], "no")
+}
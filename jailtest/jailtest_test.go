@@ -0,0 +1,558 @@
+package jailtest
+
+import (
+	"errors"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/briandowns/jail"
+	"golang.org/x/sys/unix"
+)
+
+func TestBackend_Set(t *testing.T) {
+	type args struct {
+		params jail.Params
+		flags  uintptr
+	}
+	tests := []struct {
+		name    string
+		setup   func(b *Backend)
+		args    args
+		want    int32
+		wantErr error
+	}{
+		{
+			name: "create",
+			args: args{
+				params: jail.Params{"name": "web", "persist": true},
+				flags:  jail.CreateFlag,
+			},
+			want: 1,
+		},
+		{
+			name: "create with jid",
+			args: args{
+				params: jail.Params{"jid": 10, "name": "web", "persist": true},
+				flags:  jail.CreateFlag,
+			},
+			want: 10,
+		},
+		{
+			name: "no create or update",
+			args: args{
+				params: jail.Params{"name": "web"},
+			},
+			wantErr: einval,
+		},
+		{
+			name: "unknown flag",
+			args: args{
+				params: jail.Params{"name": "web"},
+				flags:  jail.CreateFlag | 0x100,
+			},
+			wantErr: einval,
+		},
+		{
+			name: "unknown param",
+			args: args{
+				params: jail.Params{"name": "web", "foo": "bar"},
+				flags:  jail.CreateFlag,
+			},
+			wantErr: einval,
+		},
+		{
+			name: "wrong type",
+			args: args{
+				params: jail.Params{"name": 1},
+				flags:  jail.CreateFlag,
+			},
+			wantErr: einval,
+		},
+		{
+			name: "persist and nopersist",
+			args: args{
+				params: jail.Params{"name": "web", "persist": true, "nopersist": true},
+				flags:  jail.CreateFlag,
+			},
+			wantErr: einval,
+		},
+		{
+			name: "numeric name",
+			args: args{
+				params: jail.Params{"name": "12", "persist": true},
+				flags:  jail.CreateFlag,
+			},
+			wantErr: einval,
+		},
+		{
+			name: "name too long",
+			args: args{
+				params: jail.Params{"name": string(make([]byte, maxHostNameLen)), "persist": true},
+				flags:  jail.CreateFlag,
+			},
+			wantErr: enametoolong,
+		},
+		{
+			name:  "unprivileged",
+			setup: func(b *Backend) { b.Unprivileged = true },
+			args: args{
+				params: jail.Params{"name": "web", "persist": true},
+				flags:  jail.CreateFlag,
+			},
+			wantErr: eperm,
+		},
+		{
+			name: "name exists",
+			setup: func(b *Backend) {
+				b.Set(jail.Params{"name": "web", "persist": true}, jail.CreateFlag)
+			},
+			args: args{
+				params: jail.Params{"name": "web", "persist": true},
+				flags:  jail.CreateFlag,
+			},
+			wantErr: eexist,
+		},
+		{
+			name: "jid exists",
+			setup: func(b *Backend) {
+				b.Set(jail.Params{"jid": 3, "persist": true}, jail.CreateFlag)
+			},
+			args: args{
+				params: jail.Params{"jid": 3, "persist": true},
+				flags:  jail.CreateFlag,
+			},
+			wantErr: eexist,
+		},
+		{
+			name: "update missing",
+			args: args{
+				params: jail.Params{"name": "web"},
+				flags:  jail.UpdateFlag,
+			},
+			wantErr: enoent,
+		},
+		{
+			name: "update",
+			setup: func(b *Backend) {
+				b.Set(jail.Params{"name": "web", "persist": true}, jail.CreateFlag)
+			},
+			args: args{
+				params: jail.Params{"name": "web", "host.hostname": "web.local"},
+				flags:  jail.UpdateFlag,
+			},
+			want: 1,
+		},
+		{
+			name: "create or update",
+			setup: func(b *Backend) {
+				b.Set(jail.Params{"name": "web", "persist": true}, jail.CreateFlag)
+			},
+			args: args{
+				params: jail.Params{"name": "web"},
+				flags:  jail.CreateFlag | jail.UpdateFlag,
+			},
+			want: 1,
+		},
+		{
+			name: "rename to existing",
+			setup: func(b *Backend) {
+				b.Set(jail.Params{"name": "web", "persist": true}, jail.CreateFlag)
+				b.Set(jail.Params{"name": "db", "persist": true}, jail.CreateFlag)
+			},
+			args: args{
+				params: jail.Params{"jid": 1, "name": "db"},
+				flags:  jail.UpdateFlag,
+			},
+			wantErr: eexist,
+		},
+		{
+			name: "no jids left",
+			setup: func(b *Backend) {
+				b.MaxJID = 1
+				b.Set(jail.Params{"name": "web", "persist": true}, jail.CreateFlag)
+			},
+			args: args{
+				params: jail.Params{"name": "db", "persist": true},
+				flags:  jail.CreateFlag,
+			},
+			wantErr: eagain,
+		},
+		{
+			name: "update dying",
+			setup: func(b *Backend) {
+				b.Set(jail.Params{"name": "web"}, jail.CreateFlag|jail.AttachFlag)
+				b.Remove(1)
+			},
+			args: args{
+				params: jail.Params{"jid": 1, "host.hostname": "web"},
+				flags:  jail.UpdateFlag,
+			},
+			wantErr: enoent,
+		},
+		{
+			name: "update dying with flag",
+			setup: func(b *Backend) {
+				b.Set(jail.Params{"name": "web"}, jail.CreateFlag|jail.AttachFlag)
+				b.Remove(1)
+			},
+			args: args{
+				params: jail.Params{"jid": 1, "persist": true},
+				flags:  jail.UpdateFlag | jail.DyingFlag,
+			},
+			want: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New()
+			if tt.setup != nil {
+				tt.setup(b)
+			}
+			got, err := b.Set(tt.args.params, tt.args.flags)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Backend.Set() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Backend.Set() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackend_Get(t *testing.T) {
+	b := New()
+	if _, err := b.Set(jail.Params{"name": "web", "persist": true, "path": "/jails/web"}, jail.CreateFlag); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Set(jail.Params{"name": "db", "persist": true}, jail.CreateFlag); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		params  jail.Params
+		flags   uintptr
+		want    jail.Params
+		wantErr error
+	}{
+		{
+			name:   "by name",
+			params: jail.Params{"name": "web", "jid": 0, "path": ""},
+			want:   jail.Params{"name": "web", "jid": int32(1), "path": "/jails/web"},
+		},
+		{
+			name:   "by jid",
+			params: jail.Params{"jid": int32(2), "name": "", "persist": false},
+			want:   jail.Params{"jid": int32(2), "name": "db", "persist": true},
+		},
+		{
+			name:   "by numeric name",
+			params: jail.Params{"name": "2", "jid": 0},
+			want:   jail.Params{"name": "db", "jid": int32(2)},
+		},
+		{
+			name:   "lastjid",
+			params: jail.Params{"lastjid": 1, "jid": 0},
+			want:   jail.Params{"lastjid": 1, "jid": int32(2)},
+		},
+		{
+			name:   "unset param",
+			params: jail.Params{"jid": 2, "host.hostname": "x"},
+			want:   jail.Params{"jid": int32(2), "host.hostname": ""},
+		},
+		{
+			name:    "lastjid past end",
+			params:  jail.Params{"lastjid": 2},
+			wantErr: enoent,
+		},
+		{
+			name:    "not found",
+			params:  jail.Params{"name": "app"},
+			wantErr: enoent,
+		},
+		{
+			name:    "no jail specified",
+			params:  jail.Params{"path": ""},
+			wantErr: enoent,
+		},
+		{
+			name:    "unknown param",
+			params:  jail.Params{"name": "web", "foo": ""},
+			wantErr: einval,
+		},
+		{
+			name:    "errmsg",
+			params:  jail.Params{"name": "web", "foo": "", "errmsg": ""},
+			want:    jail.Params{"name": "web", "foo": "", "errmsg": "unknown parameter: foo"},
+			wantErr: einval,
+		},
+		{
+			name:    "unknown flag",
+			params:  jail.Params{"name": "web"},
+			flags:   jail.CreateFlag,
+			wantErr: einval,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := b.Get(tt.params, tt.flags)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Backend.Get() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			for k, v := range tt.want {
+				if tt.params[k] != v {
+					t.Errorf("Backend.Get() %s = %#v, want %#v", k, tt.params[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestBackend_lifecycle(t *testing.T) {
+	b := New()
+
+	jid, err := b.Set(jail.Params{"name": "web"}, jail.CreateFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Jails()) != 0 {
+		t.Errorf("jail %d without persist or processes should not remain", jid)
+	}
+
+	jid, err = b.Jail(&jail.Opts{
+		Version:  2,
+		Path:     "/jails/web",
+		Name:     "web",
+		IP4Addrs: []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("127.0.1.1")},
+		IP6Addrs: []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Attached() != jid {
+		t.Errorf("Attached() = %d, want %d", b.Attached(), jid)
+	}
+	p := jail.Params{"jid": jid, "ip4.addr": nil, "ip6.addr": nil, "host.hostname": ""}
+	if _, err := b.Get(p, 0); err != nil {
+		t.Fatal(err)
+	}
+	want := jail.Params{
+		"jid":           jid,
+		"ip4.addr":      []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("127.0.1.1")},
+		"ip6.addr":      []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+		"host.hostname": "web",
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("Get() = %v, want %v", p, want)
+	}
+
+	if err := b.Remove(jid); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Remove(jid); !errors.Is(err, einval) {
+		t.Errorf("Remove() of dying jail error = %v, want %v", err, einval)
+	}
+	jails := b.Jails()
+	if len(jails) != 1 || !jails[0].Dying {
+		t.Fatalf("Jails() = %+v, want one dying jail", jails)
+	}
+	if _, err := b.Get(jail.Params{"jid": jid}, 0); !errors.Is(err, enoent) {
+		t.Errorf("Get() of dying jail error = %v, want %v", err, enoent)
+	}
+	p = jail.Params{"jid": jid, "dying": false}
+	if _, err := b.Get(p, jail.DyingFlag); err != nil || p["dying"] != true {
+		t.Errorf("Get() with dying flag = %v, %v", p, err)
+	}
+
+	jid2, err := b.Set(jail.Params{"name": "web", "persist": true}, jail.CreateFlag)
+	if err != nil {
+		t.Fatalf("name of dying jail should be reusable: %v", err)
+	}
+	if jid2 == jid {
+		t.Errorf("JID %d of dying jail was reused", jid)
+	}
+
+	b.Release(jid)
+	if len(b.Jails()) != 1 {
+		t.Errorf("dying jail should be removed once released")
+	}
+
+	if err := b.Attach(jid); !errors.Is(err, einval) {
+		t.Errorf("Attach() error = %v, want %v", err, einval)
+	}
+	if _, err := b.Set(jail.Params{"jid": jid2, "nopersist": true}, jail.UpdateFlag); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Jails()) != 0 {
+		t.Errorf("jail without persist or processes should be removed")
+	}
+}
+
+func TestBackend_errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		params jail.Params
+		flags  uintptr
+		want   error
+	}{
+		{
+			name:   "exists",
+			params: jail.Params{"name": "web", "persist": true},
+			flags:  jail.CreateFlag,
+			want:   jail.ErrExists,
+		},
+		{
+			name:   "not found",
+			params: jail.Params{"name": "db"},
+			flags:  jail.UpdateFlag,
+			want:   jail.ErrNotFound,
+		},
+		{
+			name:   "unknown param",
+			params: jail.Params{"name": "db", "foo": 1},
+			flags:  jail.CreateFlag,
+			want:   jail.ErrUnknownParam,
+		},
+		{
+			name:   "invalid value",
+			params: jail.Params{"name": "db", "persist": true, "nopersist": true},
+			flags:  jail.CreateFlag,
+			want:   jail.ErrInvalidValue,
+		},
+		{
+			name:   "name too long",
+			params: jail.Params{"name": string(make([]byte, maxHostNameLen))},
+			flags:  jail.CreateFlag,
+			want:   jail.ErrNameTooLong,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New()
+			defer jail.SetBackend(jail.SetBackend(b))
+			if _, err := b.Set(jail.Params{"name": "web", "persist": true}, jail.CreateFlag); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := jail.Set(tt.params, tt.flags); !errors.Is(err, tt.want) {
+				t.Errorf("Set() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	b := New()
+	b.Unprivileged = true
+	defer jail.SetBackend(jail.SetBackend(b))
+	if err := jail.Remove(1); !errors.Is(err, jail.ErrPermission) {
+		t.Errorf("Remove() error = %v, want %v", err, jail.ErrPermission)
+	}
+}
+
+func TestSetBackend(t *testing.T) {
+	b := New()
+	defer jail.SetBackend(jail.SetBackend(b))
+
+	params := jail.NewParams()
+	params.Add("name", "web")
+	params.Add("persist", true)
+	if err := jail.Set(params, jail.CreateFlag); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := jail.Set(params, jail.CreateFlag); err == nil {
+		t.Errorf("Set() of existing jail should fail")
+	}
+	if err := jail.Remove(1); err != nil {
+		t.Errorf("Remove() error = %v", err)
+	}
+	if err := jail.Attach(1); err == nil {
+		t.Errorf("Attach() to removed jail should fail")
+	}
+}
+
+func TestIDName(t *testing.T) {
+	b := New()
+	defer jail.SetBackend(jail.SetBackend(b))
+
+	if _, err := b.Set(jail.Params{"name": "web", "persist": true}, jail.CreateFlag); err != nil {
+		t.Fatal(err)
+	}
+
+	jid, err := jail.ID("web")
+	if err != nil || jid != 1 {
+		t.Errorf("ID() = %d, %v, want 1", jid, err)
+	}
+	name, err := jail.Name(1)
+	if err != nil || name != "web" {
+		t.Errorf("Name() = %q, %v, want web", name, err)
+	}
+
+	if _, err := jail.ID("db"); !errors.Is(err, jail.ErrNotFound) {
+		t.Errorf("ID() error = %v, want %v", err, jail.ErrNotFound)
+	}
+	if _, err := jail.Name(2); !errors.Is(err, jail.ErrNotFound) {
+		t.Errorf("Name() error = %v, want %v", err, jail.ErrNotFound)
+	}
+}
+
+func TestJail_vnet(t *testing.T) {
+	b := New()
+	defer jail.SetBackend(jail.SetBackend(b))
+
+	jid, err := jail.Jail(&jail.Opts{Version: 2, Path: "/jails/web", Name: "web", VNet: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Attached() != jid {
+		t.Errorf("Attached() = %d, want %d", b.Attached(), jid)
+	}
+	if jails := b.Jails(); len(jails) != 1 || jails[0].Params["vnet"] != jail.JailSysNew {
+		t.Errorf("Jails() = %+v, want a vnet jail", jails)
+	}
+
+	_, err = jail.Jail(&jail.Opts{Version: 2, Path: "/jails/web", Name: "web", VNet: true})
+	if !errors.Is(err, jail.ErrExists) {
+		t.Errorf("Jail() error = %v, want %v", err, jail.ErrExists)
+	}
+}
+
+func TestJailError(t *testing.T) {
+	b := New()
+	defer jail.SetBackend(jail.SetBackend(b))
+
+	err := jail.Set(jail.Params{"name": "web", "path": "/jails/web"}, jail.UpdateFlag)
+	var je *jail.JailError
+	if !errors.As(err, &je) {
+		t.Fatalf("Set() error = %#v, want *jail.JailError", err)
+	}
+	want := jail.JailError{
+		Op:      "update",
+		Syscall: "jail_set",
+		Errno:   enoent,
+		Err:     jail.ErrNotFound,
+		Name:    "web",
+		Msg:     `jail "web" not found`,
+	}
+	if *je != want {
+		t.Errorf("Set() error = %+v, want %+v", *je, want)
+	}
+	if !errors.Is(err, unix.ENOENT) {
+		t.Errorf("errors.Is(err, ENOENT) = false")
+	}
+
+	err = jail.Get(jail.Params{"name": "web", "foo": ""}, 0)
+	if !errors.As(err, &je) || je.Msg != "unknown parameter: foo" || !errors.Is(err, jail.ErrUnknownParam) {
+		t.Errorf("Get() error = %v", err)
+	}
+
+	params := jail.Params{"name": "web", "jid": 0}
+	err = jail.Get(params, 0)
+	if !errors.As(err, &je) || je.Msg != `jail "web" not found` {
+		t.Errorf("Get() error = %v", err)
+	}
+	if _, ok := params["errmsg"]; ok {
+		t.Errorf("Get() should not leave errmsg in params")
+	}
+}
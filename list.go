@@ -0,0 +1,127 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package jail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// listFields are the parameters List retrieves when none are
+// requested, those shown by jls -v.
+var listFields = []string{
+	"jid",
+	"name",
+	"path",
+	"host.hostname",
+	"ip4.addr",
+	"ip6.addr",
+	"cpuset.id",
+	"parent",
+	"persist",
+	"dying",
+	"children.cur",
+	"children.max",
+	"securelevel",
+	"osrelease",
+	"osreldate",
+}
+
+// List returns every jail in order of JID along with the values of
+// the given parameters, or those shown by jls -v if none are given.
+// The JID and name of each jail are always returned.
+// Jails are retrieved one at a time with jail_get(2), each call
+// asking for the jail following the last JID seen, until the kernel
+// reports there are no more.
+func List(ctx context.Context, fields ...string) ([]JailInfo, error) {
+	return list(ctx, 0, fields)
+}
+
+// ListDying returns every jail as List does, including those in the
+// process of being removed.
+func ListDying(ctx context.Context, fields ...string) ([]JailInfo, error) {
+	return list(ctx, DyingFlag, fields)
+}
+
+// list iterates over the jails with the lastjid parameter.
+func list(ctx context.Context, flags uintptr, fields []string) ([]JailInfo, error) {
+	if len(fields) == 0 {
+		for _, f := range listFields {
+			if _, ok := LookupParam(f); ok {
+				fields = append(fields, f)
+			}
+		}
+	}
+	want := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		if _, ok := LookupParam(f); !ok || f == "lastjid" || f == "errmsg" {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownParam, f)
+		}
+		want[f] = true
+	}
+
+	var (
+		jails []JailInfo
+		last  int32
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		params := make(Params, len(want)+3)
+		for f := range want {
+			params[f] = nil
+		}
+		params["lastjid"] = last
+		params["jid"] = int32(0)
+		params["name"] = ""
+
+		if err := Get(params, flags); err != nil {
+			// jail_get(2) fails with ENOENT once lastjid is
+			// at or above the highest JID.
+			if errors.Is(err, ErrNotFound) {
+				return jails, nil
+			}
+			return nil, err
+		}
+
+		delete(params, "lastjid")
+		ji, err := NewJailInfo(params)
+		if err != nil {
+			return nil, err
+		}
+		jails = append(jails, *ji)
+
+		if ji.JID <= last {
+			return nil, fmt.Errorf("jail_get returned jid %d after %d", ji.JID, last)
+		}
+		last = ji.JID
+	}
+}
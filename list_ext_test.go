@@ -0,0 +1,95 @@
+package jail_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/briandowns/jail"
+	"github.com/briandowns/jail/jailtest"
+)
+
+func TestList(t *testing.T) {
+	b := jailtest.New()
+	defer jail.SetBackend(jail.SetBackend(b))
+
+	for _, p := range []jail.Params{
+		{"name": "web", "path": "/jails/web", "persist": true},
+		{"name": "db", "path": "/jails/db", "persist": true},
+		{"name": "mail", "path": "/jails/mail", "persist": true},
+	} {
+		if _, err := b.Set(p, jail.CreateFlag); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Attach(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Remove(2); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		list    func(context.Context, ...string) ([]jail.JailInfo, error)
+		fields  []string
+		want    []jail.Params
+		wantErr error
+	}{
+		{
+			name:   "alive",
+			list:   jail.List,
+			fields: []string{"path"},
+			want: []jail.Params{
+				{"jid": int32(1), "name": "web", "path": "/jails/web"},
+				{"jid": int32(3), "name": "mail", "path": "/jails/mail"},
+			},
+		},
+		{
+			name:   "dying",
+			list:   jail.ListDying,
+			fields: []string{"jid", "dying"},
+			want: []jail.Params{
+				{"jid": int32(1), "name": "web", "dying": false},
+				{"jid": int32(2), "name": "db", "dying": true},
+				{"jid": int32(3), "name": "mail", "dying": false},
+			},
+		},
+		{
+			name:    "unknown field",
+			list:    jail.List,
+			fields:  []string{"bogus"},
+			wantErr: jail.ErrUnknownParam,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jails, err := tt.list(context.Background(), tt.fields...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("List() error = %v, want %v", err, tt.wantErr)
+			}
+			var got []jail.Params
+			for _, j := range jails {
+				got = append(got, j.Params())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	jails, err := jail.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jails) != 2 || jails[1].Path != "/jails/mail" || !jails[1].Persist || jails[1].Extra["dying"] != false {
+		t.Errorf("List() = %+v, want the jls -v parameters of two jails", jails)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := jail.List(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("List() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,325 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package jail
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// defaultClass is the login class of users without one of their own,
+// LOGIN_DEFCLASS from login_cap.h.
+const defaultClass = "default"
+
+// loginEnv are the variables set from the string capabilities of a
+// login class, as setusercontext(3) does.
+var loginEnv = []struct{ cap, env string }{
+	{"lang", "LANG"},
+	{"charset", "MM_CHARSET"},
+	{"timezone", "TZ"},
+}
+
+// loginClass is the part of a login class of login.conf(5) applied
+// to a command: its environment and umask.
+type loginClass struct {
+	env   []string
+	umask *int
+}
+
+// readLoginClass reads the named class from the jail's
+// /etc/login.conf, falling back to the default class as
+// login_getclass(3) does. A ~ in a value is replaced by the
+// user's home directory and $ by the user's name.
+func readLoginClass(root, class string, pw *passwd) (*loginClass, error) {
+	f, err := openIn(root, "/etc/login.conf")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	db, err := parseLoginConf(f)
+	if err != nil {
+		return nil, err
+	}
+	caps, ok := db.class(class, 0)
+	if !ok {
+		if caps, ok = db.class(defaultClass, 0); !ok {
+			return nil, fmt.Errorf("unknown login class %s in jail", class)
+		}
+	}
+
+	lc := &loginClass{}
+	if v, ok := caps.str("path"); ok {
+		dirs := strings.FieldsFunc(v, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ','
+		})
+		for i, d := range dirs {
+			dirs[i] = substVar(d, pw)
+		}
+		lc.env = append(lc.env, "PATH="+strings.Join(dirs, ":"))
+	}
+	for _, e := range loginEnv {
+		if v, ok := caps.str(e.cap); ok {
+			lc.env = append(lc.env, e.env+"="+substVar(v, pw))
+		}
+	}
+	if v, ok := caps.str("setenv"); ok {
+		for _, kv := range splitList(v) {
+			name, val, _ := strings.Cut(kv, "=")
+			if name = strings.TrimSpace(name); name != "" {
+				lc.env = append(lc.env, name+"="+substVar(val, pw))
+			}
+		}
+	}
+	if v, ok := caps.num("umask"); ok {
+		lc.umask = &v
+	}
+
+	return lc, nil
+}
+
+// substVar replaces the unescaped ~ and $ of a login class value by
+// the user's home directory and name.
+func substVar(v string, pw *passwd) string {
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; {
+		case c == '\\' && i+1 < len(v) && (v[i+1] == '~' || v[i+1] == '$'):
+			i++
+			b.WriteByte(v[i])
+		case c == '~' && pw != nil:
+			b.WriteString(pw.home)
+		case c == '$' && pw != nil:
+			b.WriteString(pw.name)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+// splitList splits a comma separated list of a login class, where a
+// comma may be escaped with a backslash.
+func splitList(v string) []string {
+	var (
+		list []string
+		b    strings.Builder
+	)
+	for i := 0; i < len(v); i++ {
+		switch {
+		case v[i] == '\\' && i+1 < len(v) && v[i+1] == ',':
+			i++
+			b.WriteByte(',')
+		case v[i] == ',':
+			list = append(list, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(v[i])
+		}
+	}
+
+	return append(list, b.String())
+}
+
+// maxTC is the number of tc= capabilities followed in reading a
+// record before giving up, as getcap(3) does.
+const maxTC = 32
+
+// capDB is a capability database of getcap(3), the capabilities of
+// each record by its names.
+type capDB map[string][]string
+
+// caps are the capabilities of a record in the order given, the first
+// of each name taking precedence.
+type caps []string
+
+// class returns the capabilities of the named record with those of
+// the records named by its tc= capabilities in their place.
+func (db capDB) class(name string, depth int) (caps, bool) {
+	rec, ok := db[name]
+	if !ok || depth > maxTC {
+		return nil, false
+	}
+
+	var c caps
+	for _, f := range rec {
+		if tc, ok := strings.CutPrefix(f, "tc="); ok {
+			sub, _ := db.class(tc, depth+1)
+			c = append(c, sub...)
+			continue
+		}
+		c = append(c, f)
+	}
+
+	return c, true
+}
+
+// lookup returns the first capability of the name, with the
+// character following the name. A capability cancelled with @ is not
+// found.
+func (c caps) lookup(name string) (byte, string, bool) {
+	for _, f := range c {
+		rest, ok := strings.CutPrefix(f, name)
+		if !ok {
+			continue
+		}
+		if rest == "" {
+			return 0, "", true
+		}
+		switch rest[0] {
+		case '@':
+			return 0, "", false
+		case '=', '#':
+			return rest[0], rest[1:], true
+		}
+	}
+
+	return 0, "", false
+}
+
+// str returns the value of a string capability.
+func (c caps) str(name string) (string, bool) {
+	typ, v, ok := c.lookup(name)
+	if !ok || typ != '=' {
+		return "", false
+	}
+
+	return unescapeCap(v), true
+}
+
+// num returns the value of a numeric capability, given in decimal,
+// octal with a leading 0 or hexadecimal with a leading 0x. login.conf
+// gives some as strings, umask=022, which are read alike.
+func (c caps) num(name string) (int, bool) {
+	typ, v, ok := c.lookup(name)
+	if !ok || typ == 0 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 0, 32)
+	if err != nil {
+		return 0, false
+	}
+
+	return int(n), true
+}
+
+// unescapeCap decodes the escapes of a string capability: \ followed
+// by up to three octal digits, one of the letters of getcap(3) or any
+// other character standing for itself, and ^ followed by a character
+// for its control character.
+func unescapeCap(v string) string {
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case c == '^' && i+1 < len(v):
+			i++
+			b.WriteByte(v[i] & 0x1f)
+		case c == '\\' && i+1 < len(v):
+			i++
+			switch c = v[i]; c {
+			case 'E', 'e':
+				b.WriteByte(0x1b)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				n := 0
+				for j := 0; j < 3 && i < len(v) && v[i] >= '0' && v[i] <= '7'; j++ {
+					n = n<<3 | int(v[i]-'0')
+					i++
+				}
+				i--
+				b.WriteByte(byte(n))
+			default:
+				b.WriteByte(c)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+// parseLoginConf parses a capability database of getcap(3). A record
+// is continued on the next line after a trailing backslash, and its
+// names, separated by |, are followed by its colon separated
+// capabilities. Blank lines and those starting with # are skipped.
+func parseLoginConf(r io.Reader) (capDB, error) {
+	db := make(capDB)
+	var rec strings.Builder
+	add := func() {
+		defer rec.Reset()
+		fields := strings.Split(rec.String(), ":")
+		if fields[0] == "" {
+			return
+		}
+		var c []string
+		for _, f := range fields[1:] {
+			if f = strings.TrimSpace(f); f != "" {
+				c = append(c, f)
+			}
+		}
+		for _, name := range strings.Split(fields[0], "|") {
+			if _, ok := db[name]; !ok {
+				db[name] = c
+			}
+		}
+	}
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimLeft(s.Text(), " \t")
+		if rec.Len() == 0 && (line == "" || line[0] == '#') {
+			continue
+		}
+		if cont, ok := strings.CutSuffix(line, "\\"); ok {
+			rec.WriteString(cont)
+			continue
+		}
+		rec.WriteString(line)
+		add()
+	}
+	if rec.Len() > 0 {
+		add()
+	}
+
+	return db, s.Err()
+}
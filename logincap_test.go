@@ -0,0 +1,129 @@
+package jail
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_readLoginClass(t *testing.T) {
+	root := newJailRoot(t)
+	loop := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(loop, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	conf := "a:lang=C:tc=b:\nb:tc=a:umask=077:\n"
+	if err := os.WriteFile(filepath.Join(loop, "etc", "login.conf"), []byte(conf), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pw := &passwd{name: "root", uid: 0, home: "/root"}
+
+	tests := []struct {
+		name    string
+		root    string
+		class   string
+		want    *loginClass
+		wantErr error
+	}{
+		{
+			name:  "inherited",
+			root:  root,
+			class: "root",
+			want: &loginClass{
+				env:   []string{"PATH=/sbin:/bin", "BLOCKSIZE=K", "PAGER=less"},
+				umask: intPtr(0o22),
+			},
+		},
+		{
+			name:  "alias",
+			root:  root,
+			class: "system daemons",
+			want: &loginClass{
+				env:   []string{"HOME=/root", "OWNER=root", "LIST=a,b"},
+				umask: intPtr(0o22),
+			},
+		},
+		{
+			name:  "unknown",
+			root:  root,
+			class: "nope",
+			want: &loginClass{
+				env:   []string{"PATH=/sbin:/bin:/usr/sbin:/usr/bin:/usr/local/bin:/root/bin", "BLOCKSIZE=K", "PAGER=less"},
+				umask: intPtr(0o22),
+			},
+		},
+		{
+			name:  "tc loop",
+			root:  loop,
+			class: "a",
+			want:  &loginClass{env: []string{"LANG=C"}, umask: intPtr(0o77)},
+		},
+		{
+			name:    "no login.conf",
+			root:    t.TempDir(),
+			class:   "default",
+			wantErr: os.ErrNotExist,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readLoginClass(tt.root, tt.class, pw)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("readLoginClass() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readLoginClass() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseLoginConf(t *testing.T) {
+	const conf = `# comment
+first|alias|long name:\
+	:a=1:\
+	:b#2:c:
+
+	# indented comment
+first:a=other:
+last:d@:`
+	want := capDB{
+		"first":     {"a=1", "b#2", "c"},
+		"alias":     {"a=1", "b#2", "c"},
+		"long name": {"a=1", "b#2", "c"},
+		"last":      {"d@"},
+	}
+
+	got, err := parseLoginConf(strings.NewReader(conf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseLoginConf() = %q, want %q", got, want)
+	}
+}
+
+func Test_unescapeCap(t *testing.T) {
+	tests := []struct {
+		name string
+		v    string
+		want string
+	}{
+		{name: "plain", v: "less", want: "less"},
+		{name: "octal", v: `a\072b`, want: "a:b"},
+		{name: "short octal", v: `\0x`, want: "\x00x"},
+		{name: "letters", v: `\E\n\t`, want: "\x1b\n\t"},
+		{name: "control", v: "^C", want: "\x03"},
+		{name: "escaped", v: `\\\^`, want: `\^`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unescapeCap(tt.v); got != tt.want {
+				t.Errorf("unescapeCap() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
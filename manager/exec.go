@@ -0,0 +1,161 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package manager
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	osuser "os/user"
+	"strconv"
+	"syscall"
+
+	"github.com/briandowns/jail"
+)
+
+// Cmd is a command run by an exec hook.
+type Cmd struct {
+	// Line is the command line, run by /bin/sh -c.
+	Line string
+
+	// JID is the jail the command is run in, or 0 to run it
+	// on the host.
+	JID int32
+
+	// User is the user to run the command as, the current user
+	// if empty. For commands run in a jail it is looked up in the
+	// jail's password database unless SystemUser is set.
+	User       string
+	SystemUser bool
+
+	// Clean runs the command in a clean environment.
+	Clean bool
+
+	// Stdout and Stderr receive the output of the command and
+	// are discarded if nil.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Runner runs the commands of exec hooks.
+type Runner interface {
+	Run(ctx context.Context, cmd *Cmd) error
+}
+
+// ExecRunner is the Runner used by default. It runs commands with
+// /bin/sh, on the host or in the jail through jail.Command, as the
+// user looked up in the host's or the jail's password database.
+type ExecRunner struct{}
+
+// Run runs the command, killing it if the context is done first.
+func (ExecRunner) Run(ctx context.Context, cmd *Cmd) error {
+	if cmd.JID != 0 {
+		c := jail.CommandContext(ctx, cmd.JID, "/bin/sh", "-c", cmd.Line)
+		c.Clean = cmd.Clean
+		c.Stdout, c.Stderr = cmd.Stdout, cmd.Stderr
+		switch {
+		case cmd.User != "" && cmd.SystemUser:
+			u, err := hostUser(cmd.User)
+			if err != nil {
+				return err
+			}
+			c.SysProcAttr = &syscall.SysProcAttr{Credential: u.cred}
+		case cmd.User != "":
+			c.User = cmd.User
+		}
+		return c.Run()
+	}
+
+	c := exec.CommandContext(ctx, "/bin/sh", "-c", cmd.Line)
+	c.Stdout, c.Stderr = cmd.Stdout, cmd.Stderr
+	var u *user
+	if cmd.User != "" {
+		var err error
+		if u, err = hostUser(cmd.User); err != nil {
+			return err
+		}
+		c.SysProcAttr = &syscall.SysProcAttr{Credential: u.cred}
+	}
+	if cmd.Clean {
+		// As su -l does for a user.
+		home := "/"
+		c.Env = []string{"PATH=" + defaultPath, "TERM=" + os.Getenv("TERM")}
+		if u != nil {
+			home, c.Dir = u.home, u.home
+			c.Env = append(c.Env, "USER="+u.name, "SHELL=/bin/sh")
+		}
+		c.Env = append(c.Env, "HOME="+home)
+	}
+
+	return c.Run()
+}
+
+// defaultPath is the PATH of commands run in a clean environment on
+// the host.
+const defaultPath = "/sbin:/bin:/usr/sbin:/usr/bin"
+
+// user is a user of the host.
+type user struct {
+	name string
+	home string
+	cred *syscall.Credential
+}
+
+// hostUser looks up the named user, or a numeric user ID, in the
+// host's password database.
+func hostUser(name string) (*user, error) {
+	u, err := osuser.Lookup(name)
+	if _, ok := err.(osuser.UnknownUserError); ok {
+		u, err = osuser.LookupId(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	cred := &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: []uint32{uint32(gid)}}
+	ids, err := u.GroupIds()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if g, err := strconv.ParseUint(id, 10, 32); err == nil && uint32(g) != cred.Gid {
+			cred.Groups = append(cred.Groups, uint32(g))
+		}
+	}
+
+	return &user{name: u.Username, home: u.HomeDir, cred: cred}, nil
+}
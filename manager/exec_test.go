@@ -0,0 +1,84 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"os"
+	osuser "os/user"
+	"testing"
+)
+
+func TestExecRunner_Run(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     *Cmd
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "host",
+			cmd:  &Cmd{Line: "echo hello"},
+			want: "hello\n",
+		},
+		{
+			name: "clean",
+			cmd:  &Cmd{Line: "echo $HOME $PATH", Clean: true},
+			want: "/ " + defaultPath + "\n",
+		},
+		{
+			name:    "failure",
+			cmd:     &Cmd{Line: "exit 3"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown user",
+			cmd:     &Cmd{Line: "true", User: "no-such-user-here"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			tt.cmd.Stdout = &out
+			err := ExecRunner{}.Run(context.Background(), tt.cmd)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExecRunner.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if out.String() != tt.want {
+				t.Errorf("ExecRunner.Run() output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_hostUser(t *testing.T) {
+	cur, err := osuser.Current()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name    string
+		user    string
+		wantErr bool
+	}{
+		{name: "by name", user: cur.Username},
+		{name: "by uid", user: cur.Uid},
+		{name: "unknown", user: "no-such-user-here", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := hostUser(tt.user)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("hostUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.name != cur.Username || got.home != cur.HomeDir || int(got.cred.Uid) != os.Getuid() ||
+				len(got.cred.Groups) == 0 || got.cred.Groups[0] != got.cred.Gid {
+				t.Errorf("hostUser() = %+v, cred %+v", got, got.cred)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package manager
+
+import (
+	"context"
+	"errors"
+	"syscall"
+
+	"github.com/briandowns/jail"
+)
+
+// Killer signals the processes of jails.
+type Killer interface {
+	// Kill sends the signal to every process in the jail and
+	// returns how many there were. A signal of 0 only counts
+	// them.
+	Kill(ctx context.Context, jid int32, sig syscall.Signal) (int, error)
+}
+
+// ProcKiller is the Killer used by default. It reads the processes
+// of the jail with jail.Processes and signals them with jail.Signal.
+type ProcKiller struct{}
+
+// Kill signals the processes of the jail. Zombies, which have already
+// exited, are not counted. A jail that no longer exists has none.
+func (ProcKiller) Kill(_ context.Context, jid int32, sig syscall.Signal) (int, error) {
+	if sig != 0 {
+		n, err := jail.Signal(jid, sig)
+		if errors.Is(err, jail.ErrNotFound) {
+			return 0, nil
+		}
+		return n, err
+	}
+
+	procs, err := jail.Processes(jid)
+	if errors.Is(err, jail.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, p := range procs {
+		if p.State != jail.ProcZombie {
+			n++
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,669 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+// Package manager starts and stops the jails defined in a jail.conf(5)
+// file the way jail(8) does, running the exec.* hooks and mounting the
+// file systems of each jail around the calls made to the kernel.
+//
+// The commands and mounts are made through the Runner and Mounter of
+// the Manager so the sequence can be exercised off of a FreeBSD host
+// with fakes in their place and the jailtest backend.
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/briandowns/jail"
+	"github.com/briandowns/jail/jailconf"
+	"github.com/briandowns/jail/vnet"
+)
+
+// Error is returned when a step of starting or stopping a jail fails.
+type Error struct {
+	Jail string
+	Step string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return "jail " + e.Jail + ": " + e.Step + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Manager starts and stops the jails of a configuration.
+type Manager struct {
+	// Config is the configuration the jails are resolved from.
+	Config *jailconf.File
+
+	// Runner runs the commands of the exec.* hooks.
+	Runner Runner
+
+	// Mounter mounts the file systems given by the mount.*
+	// parameters.
+	Mounter Mounter
+
+	// Killer signals the processes left in a jail being stopped.
+	Killer Killer
+
+	// Network moves the interfaces of vnet.interface in to jails
+	// and provisions the epairs of Networks. vnet.New() is used if
+	// nil.
+	Network *vnet.Network
+
+	// Networks are the networks of VNET jails by name, set up on
+	// an epair once the jail is created and torn down once it is
+	// removed.
+	Networks map[string]*vnet.Config
+
+	// Stdout and Stderr receive the output of commands for jails
+	// without exec.consolelog. The output is discarded if nil.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	mu    sync.Mutex
+	links map[string]*vnet.Link
+}
+
+// New creates a Manager for the configuration that runs commands and
+// mounts file systems on the host.
+func New(f *jailconf.File) *Manager {
+	return &Manager{
+		Config:  f,
+		Runner:  ExecRunner{},
+		Mounter: ExecMounter{},
+		Killer:  ProcKiller{},
+		Network: vnet.New(),
+		Stdout:  os.Stdout,
+		Stderr:  os.Stderr,
+	}
+}
+
+// Start creates the named jail, returning its JID. The steps jail(8)
+// takes are followed in order:
+//
+//   - exec.prepare is run on the host
+//   - the file systems of mount, mount.fstab, mount.devfs,
+//     mount.fdescfs and mount.procfs are mounted
+//   - exec.prestart is run on the host
+//   - the jail is created
+//   - the interfaces of vnet.interface are moved in to the jail and
+//     its network, if in Networks, set up
+//   - exec.created is run on the host
+//   - exec.start, or command, is run in the jail
+//   - exec.poststart is run on the host
+//
+// Each command is given exec.timeout seconds to complete. When a step
+// fails those before it are undone: the jail is removed, the file
+// systems unmounted and exec.release run, and an *Error is returned.
+func (m *Manager) Start(ctx context.Context, name string) (_ int32, err error) {
+	j, err := jailconf.ResolveJail(m.Config, name)
+	if err != nil {
+		return 0, err
+	}
+
+	s, err := m.session(j)
+	if err != nil {
+		return 0, err
+	}
+	defer s.close()
+
+	var undo []step
+	defer func() {
+		if err == nil {
+			return
+		}
+		ctx := context.WithoutCancel(ctx)
+		for i := len(undo) - 1; i >= 0; i-- {
+			if uerr := undo[i].do(ctx); uerr != nil {
+				err = errors.Join(err, s.fail(undo[i].name, uerr))
+			}
+		}
+	}()
+
+	if err := s.exec(ctx, "exec.prepare", 0); err != nil {
+		return 0, err
+	}
+	undo = append(undo, step{"exec.release", func(ctx context.Context) error {
+		return s.exec(ctx, "exec.release", 0)
+	}})
+
+	mounts, err := Mounts(j)
+	if err != nil {
+		return 0, s.fail("mount", err)
+	}
+	for _, mnt := range mounts {
+		if err := s.mount(ctx, mnt); err != nil {
+			return 0, err
+		}
+		undo = append(undo, step{"unmount", func(ctx context.Context) error {
+			return s.unmount(ctx, mnt)
+		}})
+	}
+
+	if err := s.exec(ctx, "exec.prestart", 0); err != nil {
+		return 0, err
+	}
+
+	id, err := s.create()
+	if err != nil {
+		return 0, err
+	}
+	undo = append(undo, step{"remove", func(context.Context) error {
+		return jail.Remove(id)
+	}})
+
+	for _, iface := range j.Pseudo["vnet.interface"] {
+		if err := s.run(ctx, func(ctx context.Context) error {
+			return m.network().Netif.MoveToJail(ctx, iface, id)
+		}); err != nil {
+			return 0, s.fail("vnet.interface", err)
+		}
+	}
+	if cfg := m.Networks[name]; cfg != nil {
+		var l *vnet.Link
+		if err := s.run(ctx, func(ctx context.Context) (err error) {
+			l, err = m.network().Setup(ctx, id, cfg)
+			return err
+		}); err != nil {
+			return 0, s.fail("vnet", err)
+		}
+		m.setLink(name, l)
+		undo = append(undo, step{"vnet", func(ctx context.Context) error {
+			m.setLink(name, nil)
+			return m.network().Teardown(ctx, l)
+		}})
+	}
+
+	if err := s.exec(ctx, "exec.created", 0); err != nil {
+		return 0, err
+	}
+
+	start := "exec.start"
+	if _, ok := j.Pseudo["command"]; ok {
+		start = "command"
+	}
+	if err := s.exec(ctx, start, id); err != nil {
+		return 0, err
+	}
+
+	if err := s.exec(ctx, "exec.poststart", 0); err != nil {
+		return 0, err
+	}
+
+	if persist, _ := j.Params["persist"].(bool); !persist {
+		p := jail.NewParams()
+		p["jid"] = id
+		p["persist"] = false
+		if err := jail.Set(p, jail.UpdateFlag); err != nil {
+			return 0, s.fail("create", err)
+		}
+	}
+
+	return id, nil
+}
+
+// Stop removes the named jail. The steps jail(8) takes are followed
+// in order:
+//
+//   - exec.prestop is run on the host
+//   - exec.stop is run in the jail
+//   - the processes of the jail are given stop.timeout seconds to
+//     exit, then sent SIGTERM and given as long again before being
+//     sent SIGKILL
+//   - the jail is removed and waited on to finish dying
+//   - the epair set up for the jail's network is destroyed
+//   - exec.poststop is run on the host
+//   - the file systems mounted by Start are unmounted in reverse
+//   - exec.release is run on the host
+//
+// A failure of exec.prestop or while signalling the processes leaves
+// the jail running. A failure of exec.stop does not, as with jail(8)
+// it is reported once the jail has been removed. From there on the
+// remaining steps are all attempted and their errors joined.
+func (m *Manager) Stop(ctx context.Context, name string) error {
+	j, err := jailconf.ResolveJail(m.Config, name)
+	if err != nil {
+		return err
+	}
+	jid, err := jail.ID(jailName(j))
+	if err != nil {
+		return err
+	}
+
+	s, err := m.session(j)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	mounts, err := Mounts(j)
+	if err != nil {
+		return s.fail("unmount", err)
+	}
+
+	if err := s.exec(ctx, "exec.prestop", 0); err != nil {
+		return err
+	}
+
+	var errs []error
+	if err := s.exec(ctx, "exec.stop", jid); err != nil {
+		errs = append(errs, err)
+	}
+	if err := s.drain(ctx, jid); err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+
+	if err := jail.Remove(jid); err != nil && !errors.Is(err, jail.ErrNotFound) {
+		return errors.Join(append(errs, s.fail("remove", err))...)
+	}
+	if err := s.waitDead(ctx, jid); err != nil {
+		errs = append(errs, err)
+	}
+	if err := s.teardown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := s.exec(ctx, "exec.poststop", 0); err != nil {
+		errs = append(errs, err)
+	}
+	for i := len(mounts) - 1; i >= 0; i-- {
+		if err := s.unmount(ctx, mounts[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := s.exec(ctx, "exec.release", 0); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// network returns the Network of the Manager.
+func (m *Manager) network() *vnet.Network {
+	if m.Network == nil {
+		return vnet.New()
+	}
+
+	return m.Network
+}
+
+// setLink records the epair set up for the named jail, or forgets it
+// if l is nil.
+func (m *Manager) setLink(name string, l *vnet.Link) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if l == nil {
+		delete(m.links, name)
+		return
+	}
+	if m.links == nil {
+		m.links = make(map[string]*vnet.Link)
+	}
+	m.links[name] = l
+}
+
+// link returns the epair recorded for the named jail.
+func (m *Manager) link(name string) *vnet.Link {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.links[name]
+}
+
+// step is an action taken, or undone, while starting or stopping a
+// jail.
+type step struct {
+	name string
+	do   func(context.Context) error
+}
+
+// Mounts returns the file systems mounted for the jail, in the order
+// they are mounted: the entries of mount.fstab and mount followed by
+// devfs, fdescfs and procfs.
+func Mounts(j *jailconf.Jail) ([]Mount, error) {
+	var mounts []Mount
+	for _, name := range j.Pseudo["mount.fstab"] {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		ms, err := parseFstab(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		mounts = append(mounts, ms...)
+	}
+	for _, line := range j.Pseudo["mount"] {
+		m, err := parseFstabLine(line)
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, m)
+	}
+
+	path, _ := j.Params["path"].(string)
+	if path == "" && (pseudoBool(j, "mount.devfs") || pseudoBool(j, "mount.fdescfs") || pseudoBool(j, "mount.procfs")) {
+		return nil, fmt.Errorf("%w: mounting devfs, fdescfs or procfs requires a path", jail.ErrInvalidValue)
+	}
+	if pseudoBool(j, "mount.devfs") {
+		ruleset := defaultDevfsRuleset
+		if v, ok := j.Params["devfs_ruleset"].(int32); ok {
+			ruleset = int(v)
+		}
+		mounts = append(mounts, devfsMount(path, ruleset))
+	}
+	if pseudoBool(j, "mount.fdescfs") {
+		mounts = append(mounts, Mount{Source: "fdescfs", Target: filepath.Join(path, "dev", "fd"), FSType: "fdescfs"})
+	}
+	if pseudoBool(j, "mount.procfs") {
+		mounts = append(mounts, Mount{Source: "proc", Target: filepath.Join(path, "proc"), FSType: "procfs"})
+	}
+
+	return mounts, nil
+}
+
+// pseudoBool returns the value of a boolean pseudo-parameter.
+func pseudoBool(j *jailconf.Jail, name string) bool {
+	v := j.Pseudo[name]
+
+	return len(v) == 1 && v[0] == "true"
+}
+
+// session holds what is needed to carry out the steps for a single
+// jail.
+type session struct {
+	m       *Manager
+	j       *jailconf.Jail
+	timeout time.Duration
+	stop    time.Duration
+	stdout  io.Writer
+	stderr  io.Writer
+	log     *os.File
+}
+
+// session prepares to start or stop the jail, opening the file named
+// by exec.consolelog.
+func (m *Manager) session(j *jailconf.Jail) (*session, error) {
+	s := &session{m: m, j: j, stop: defaultStopTimeout, stdout: m.Stdout, stderr: m.Stderr}
+
+	for _, t := range []struct {
+		param string
+		d     *time.Duration
+	}{
+		{"exec.timeout", &s.timeout},
+		{"stop.timeout", &s.stop},
+	} {
+		v := j.Pseudo[t.param]
+		if len(v) == 0 {
+			continue
+		}
+		n, err := strconv.Atoi(v[0])
+		if err != nil || n < 0 {
+			return nil, s.fail(t.param, fmt.Errorf("%w: %q", jail.ErrInvalidValue, v[0]))
+		}
+		*t.d = time.Duration(n) * time.Second
+	}
+
+	if v := j.Pseudo["exec.consolelog"]; len(v) > 0 {
+		f, err := os.OpenFile(v[0], os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return nil, s.fail("exec.consolelog", err)
+		}
+		s.log, s.stdout, s.stderr = f, f, f
+	}
+
+	return s, nil
+}
+
+// close closes the console log.
+func (s *session) close() {
+	if s.log != nil {
+		s.log.Close()
+	}
+}
+
+// fail wraps the error of the step.
+func (s *session) fail(step string, err error) error {
+	return &Error{Jail: s.j.Name, Step: step, Err: err}
+}
+
+// exec runs the commands of the exec.* parameter in turn, in the jail
+// if jid is set and otherwise on the host.
+func (s *session) exec(ctx context.Context, param string, jid int32) error {
+	for _, line := range s.j.Pseudo[param] {
+		cmd := &Cmd{
+			Line:   line,
+			JID:    jid,
+			Clean:  pseudoBool(s.j, "exec.clean"),
+			Stdout: s.stdout,
+			Stderr: s.stderr,
+		}
+		if jid != 0 {
+			cmd.User = first(s.j.Pseudo["exec.jail_user"])
+			cmd.SystemUser = pseudoBool(s.j, "exec.system_jail_user")
+		} else {
+			cmd.User = first(s.j.Pseudo["exec.system_user"])
+		}
+
+		if err := s.run(ctx, func(ctx context.Context) error {
+			return s.m.Runner.Run(ctx, cmd)
+		}); err != nil {
+			return s.fail(param, fmt.Errorf("%q: %w", line, err))
+		}
+	}
+
+	return nil
+}
+
+// mount mounts the file system.
+func (s *session) mount(ctx context.Context, m Mount) error {
+	err := s.run(ctx, func(ctx context.Context) error {
+		return s.m.Mounter.Mount(ctx, m)
+	})
+	if err != nil {
+		return s.fail("mount", err)
+	}
+
+	return nil
+}
+
+// unmount unmounts the file system.
+func (s *session) unmount(ctx context.Context, m Mount) error {
+	err := s.run(ctx, func(ctx context.Context) error {
+		return s.m.Mounter.Unmount(ctx, m)
+	})
+	if err != nil {
+		return s.fail("unmount", err)
+	}
+
+	return nil
+}
+
+// run calls fn within exec.timeout.
+func (s *session) run(ctx context.Context, fn func(context.Context) error) error {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	if err := fn(ctx); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: %w", ctx.Err(), err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// defaultStopTimeout is the time given to the processes of a jail to
+// exit when stop.timeout is not set.
+const defaultStopTimeout = 10 * time.Second
+
+// pollInterval is how often a jail being stopped is checked.
+var pollInterval = 100 * time.Millisecond
+
+// drain waits for the processes of the jail to exit, signalling those
+// that do not in time.
+func (s *session) drain(ctx context.Context, jid int32) error {
+	for _, sig := range []syscall.Signal{syscall.SIGTERM, syscall.SIGKILL} {
+		ok, err := s.wait(ctx, s.stop, func() (bool, error) {
+			n, err := s.m.Killer.Kill(ctx, jid, 0)
+			return n == 0, err
+		})
+		if err != nil {
+			return s.fail("stop", err)
+		}
+		if ok {
+			return nil
+		}
+		if _, err := s.m.Killer.Kill(ctx, jid, sig); err != nil {
+			return s.fail("stop", fmt.Errorf("%v: %w", sig, err))
+		}
+	}
+
+	return nil
+}
+
+// waitDead waits up to stop.timeout for the removed jail to finish
+// dying, failing if it has not by then.
+func (s *session) waitDead(ctx context.Context, jid int32) error {
+	ok, err := s.wait(ctx, s.stop, func() (bool, error) {
+		p := jail.NewParams()
+		p["jid"] = jid
+		p["dying"] = false
+		err := jail.Get(p, jail.DyingFlag)
+		if errors.Is(err, jail.ErrNotFound) {
+			return true, nil
+		}
+		return false, err
+	})
+	if err != nil {
+		return s.fail("remove", err)
+	}
+	if !ok {
+		return s.fail("remove", fmt.Errorf("%w: jail %d still dying after %v", context.DeadlineExceeded, jid, s.stop))
+	}
+
+	return nil
+}
+
+// wait calls done every pollInterval until it reports true or d has
+// passed, reporting whether it did.
+func (s *session) wait(ctx context.Context, d time.Duration, done func() (bool, error)) (bool, error) {
+	deadline := time.Now().Add(d)
+	for {
+		ok, err := done()
+		if ok || err != nil {
+			return ok, err
+		}
+		if !time.Now().Before(deadline) {
+			return false, nil
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// teardown destroys the epair of the jail's network. Only an epair
+// recorded by this Manager when it started the jail is destroyed; an
+// error is returned for any other, as its host end cannot be known.
+func (s *session) teardown(ctx context.Context) error {
+	if s.m.Networks[s.j.Name] == nil {
+		return nil
+	}
+
+	l := s.m.link(s.j.Name)
+	if l == nil {
+		return s.fail("vnet", fmt.Errorf("no epair recorded for jail %q", s.j.Name))
+	}
+	err := s.run(ctx, func(ctx context.Context) error {
+		return s.m.network().Teardown(ctx, l)
+	})
+	if err != nil {
+		return s.fail("vnet", err)
+	}
+	s.m.setLink(s.j.Name, nil)
+
+	return nil
+}
+
+// create creates the jail. It is made persistent so it outlives the
+// commands run in it until Start is done.
+func (s *session) create() (int32, error) {
+	p := jail.NewParams()
+	for k, v := range s.j.Params {
+		p[k] = v
+	}
+	p["persist"] = true
+
+	if err := jail.Set(p, jail.CreateFlag); err != nil {
+		return 0, s.fail("create", err)
+	}
+	jid, err := jail.ID(jailName(s.j))
+	if err != nil {
+		return 0, s.fail("create", err)
+	}
+
+	return jid, nil
+}
+
+// jailName returns the name the jail is created under, which its name
+// parameter may set apart from the name of its block.
+func jailName(j *jailconf.Jail) string {
+	if name, ok := j.Params["name"].(string); ok {
+		return name
+	}
+
+	return j.Name
+}
+
+// first returns the first value or the empty string.
+func first(v []string) string {
+	if len(v) == 0 {
+		return ""
+	}
+
+	return v[0]
+}
@@ -0,0 +1,603 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/briandowns/jail"
+	"github.com/briandowns/jail/jailconf"
+	"github.com/briandowns/jail/jailtest"
+	"github.com/briandowns/jail/vnet"
+)
+
+// recorder is a Runner, Mounter, Killer and vnet.Netif that records
+// what is done, failing the commands and mounts given.
+type recorder struct {
+	mu    sync.Mutex
+	calls []string
+	fail  map[string]bool
+	block map[string]bool
+
+	// procs is the number of processes in each jail and ignore
+	// the signals they do not exit on.
+	procs  map[int32]int
+	ignore map[syscall.Signal]bool
+}
+
+func (r *recorder) record(call string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = append(r.calls, call)
+	if r.fail[call] {
+		return errors.New("failed")
+	}
+
+	return nil
+}
+
+func (r *recorder) Run(ctx context.Context, cmd *Cmd) error {
+	call := cmd.Line
+	if cmd.JID != 0 {
+		call = fmt.Sprintf("jexec %d %s", cmd.JID, cmd.Line)
+	}
+	if r.block[call] {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	return r.record(call)
+}
+
+func (r *recorder) Mount(_ context.Context, m Mount) error {
+	return r.record("mount " + m.Target)
+}
+
+func (r *recorder) Unmount(_ context.Context, m Mount) error {
+	return r.record("umount " + m.Target)
+}
+
+func (r *recorder) Kill(_ context.Context, jid int32, sig syscall.Signal) (int, error) {
+	r.mu.Lock()
+	n := r.procs[jid]
+	r.mu.Unlock()
+	if sig == 0 {
+		return n, nil
+	}
+	if err := r.record(fmt.Sprintf("kill %d %v", jid, sig)); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.ignore[sig] {
+		r.procs[jid] = 0
+	}
+
+	return n, nil
+}
+
+func (r *recorder) CreateEpair(context.Context) (string, string, error) {
+	return "epair0a", "epair0b", r.record("ifconfig epair create")
+}
+
+func (r *recorder) Destroy(_ context.Context, iface string) error {
+	return r.record("ifconfig " + iface + " destroy")
+}
+
+func (r *recorder) AddMember(_ context.Context, bridge, iface string) error {
+	return r.record("ifconfig " + bridge + " addm " + iface)
+}
+
+func (r *recorder) MoveToJail(_ context.Context, iface string, jid int32) error {
+	return r.record(fmt.Sprintf("ifconfig %s vnet %d", iface, jid))
+}
+
+func (r *recorder) Rename(_ context.Context, jid int32, iface, name string) error {
+	return r.record(fmt.Sprintf("ifconfig -j %d %s name %s", jid, iface, name))
+}
+
+func (r *recorder) AddAddr(_ context.Context, jid int32, iface string, addr netip.Prefix) error {
+	return r.record(fmt.Sprintf("ifconfig -j %d %s %s", jid, iface, addr))
+}
+
+func (r *recorder) Up(_ context.Context, jid int32, iface string) error {
+	return r.record(fmt.Sprintf("ifconfig -j %d %s up", jid, iface))
+}
+
+func (r *recorder) AddDefaultRoute(_ context.Context, jid int32, gw netip.Addr) error {
+	return r.record(fmt.Sprintf("route -j %d add default %s", jid, gw))
+}
+
+const startConf = `
+path = "/jails/$name";
+exec.prepare = "prepare";
+exec.release = "release";
+exec.prestart = "prestart";
+exec.created = "created";
+exec.start = "/bin/sh /etc/rc";
+exec.poststart = "poststart";
+exec.prestop = "prestop";
+exec.stop = "/bin/sh /etc/rc.shutdown";
+exec.poststop = "poststop";
+stop.timeout = 0;
+mount = "tmpfs /jails/$name/tmp tmpfs rw";
+mount.devfs;
+mount.procfs;
+
+web {
+	persist;
+}
+
+db {
+	command = "/usr/local/bin/db";
+}
+
+slow {
+	exec.timeout = 1;
+	exec.start = "sleep";
+}
+`
+
+func newTestManager(t *testing.T, src string, r *recorder) (*Manager, *jailtest.Backend) {
+	t.Helper()
+
+	f, err := jailconf.Parse("jail.conf", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := jailtest.New()
+	prev := jail.SetBackend(b)
+	t.Cleanup(func() { jail.SetBackend(prev) })
+
+	return &Manager{Config: f, Runner: r, Mounter: r, Killer: r, Network: &vnet.Network{Netif: r}}, b
+}
+
+func TestManager_Start(t *testing.T) {
+	tests := []struct {
+		name      string
+		jail      string
+		fail      string
+		block     string
+		wantCalls []string
+		wantStep  string
+		wantJails int
+	}{
+		{
+			name: "persistent",
+			jail: "web",
+			wantCalls: []string{
+				"prepare",
+				"mount /jails/web/tmp",
+				"mount /jails/web/dev",
+				"mount /jails/web/proc",
+				"prestart",
+				"created",
+				"jexec 1 /bin/sh /etc/rc",
+				"poststart",
+			},
+			wantJails: 1,
+		},
+		{
+			name: "command in place of exec.start",
+			jail: "db",
+			wantCalls: []string{
+				"prepare",
+				"mount /jails/db/tmp",
+				"mount /jails/db/dev",
+				"mount /jails/db/proc",
+				"prestart",
+				"created",
+				"jexec 1 /usr/local/bin/db",
+				"poststart",
+			},
+		},
+		{
+			name: "poststart fails",
+			jail: "web",
+			fail: "poststart",
+			wantCalls: []string{
+				"prepare",
+				"mount /jails/web/tmp",
+				"mount /jails/web/dev",
+				"mount /jails/web/proc",
+				"prestart",
+				"created",
+				"jexec 1 /bin/sh /etc/rc",
+				"poststart",
+				"umount /jails/web/proc",
+				"umount /jails/web/dev",
+				"umount /jails/web/tmp",
+				"release",
+			},
+			wantStep: "exec.poststart",
+		},
+		{
+			name: "mount fails",
+			jail: "web",
+			fail: "mount /jails/web/dev",
+			wantCalls: []string{
+				"prepare",
+				"mount /jails/web/tmp",
+				"mount /jails/web/dev",
+				"umount /jails/web/tmp",
+				"release",
+			},
+			wantStep: "mount",
+		},
+		{
+			name:      "prepare fails",
+			jail:      "web",
+			fail:      "prepare",
+			wantCalls: []string{"prepare"},
+			wantStep:  "exec.prepare",
+		},
+		{
+			name:  "timeout",
+			jail:  "slow",
+			block: "jexec 1 sleep",
+			wantCalls: []string{
+				"prepare",
+				"mount /jails/slow/tmp",
+				"mount /jails/slow/dev",
+				"mount /jails/slow/proc",
+				"prestart",
+				"created",
+				"umount /jails/slow/proc",
+				"umount /jails/slow/dev",
+				"umount /jails/slow/tmp",
+				"release",
+			},
+			wantStep: "exec.start",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{
+				fail:  map[string]bool{tt.fail: true},
+				block: map[string]bool{tt.block: true},
+			}
+			m, b := newTestManager(t, startConf, r)
+
+			start := time.Now()
+			jid, err := m.Start(context.Background(), tt.jail)
+			if tt.wantStep != "" {
+				var e *Error
+				if !errors.As(err, &e) || e.Step != tt.wantStep || e.Jail != tt.jail {
+					t.Fatalf("Manager.Start() error = %v, want step %s", err, tt.wantStep)
+				}
+				if jid != 0 {
+					t.Errorf("Manager.Start() = %d, want 0", jid)
+				}
+			} else if err != nil {
+				t.Fatalf("Manager.Start() error = %v", err)
+			}
+			if time.Since(start) > 5*time.Second {
+				t.Errorf("Manager.Start() took %v", time.Since(start))
+			}
+			if !reflect.DeepEqual(r.calls, tt.wantCalls) {
+				t.Errorf("calls = %q, want %q", r.calls, tt.wantCalls)
+			}
+			if got := len(b.Jails()); got != tt.wantJails {
+				t.Errorf("jails = %d, want %d", got, tt.wantJails)
+			}
+		})
+	}
+}
+
+func TestManager_Start_exists(t *testing.T) {
+	r := &recorder{}
+	m, _ := newTestManager(t, startConf, r)
+
+	if _, err := m.Start(context.Background(), "web"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := m.Start(context.Background(), "web")
+	if !errors.Is(err, jail.ErrExists) {
+		t.Errorf("Manager.Start() error = %v, want %v", err, jail.ErrExists)
+	}
+	if !strings.HasPrefix(err.Error(), "jail web: create: ") {
+		t.Errorf("Manager.Start() error = %q", err)
+	}
+}
+
+func TestManager_Start_notFound(t *testing.T) {
+	m, _ := newTestManager(t, startConf, &recorder{})
+
+	if _, err := m.Start(context.Background(), "mail"); !errors.Is(err, jail.ErrNotFound) {
+		t.Errorf("Manager.Start() error = %v, want %v", err, jail.ErrNotFound)
+	}
+}
+
+func TestManager_Stop(t *testing.T) {
+	tests := []struct {
+		name      string
+		procs     int
+		ignore    []syscall.Signal
+		fail      string
+		wantCalls []string
+		wantStep  string
+		wantJails int
+	}{
+		{
+			name: "no processes",
+			wantCalls: []string{
+				"prestop",
+				"jexec 1 /bin/sh /etc/rc.shutdown",
+				"poststop",
+				"umount /jails/web/proc",
+				"umount /jails/web/dev",
+				"umount /jails/web/tmp",
+				"release",
+			},
+		},
+		{
+			name:  "processes exit on SIGTERM",
+			procs: 3,
+			wantCalls: []string{
+				"prestop",
+				"jexec 1 /bin/sh /etc/rc.shutdown",
+				"kill 1 terminated",
+				"poststop",
+				"umount /jails/web/proc",
+				"umount /jails/web/dev",
+				"umount /jails/web/tmp",
+				"release",
+			},
+		},
+		{
+			name:   "processes killed",
+			procs:  3,
+			ignore: []syscall.Signal{syscall.SIGTERM},
+			wantCalls: []string{
+				"prestop",
+				"jexec 1 /bin/sh /etc/rc.shutdown",
+				"kill 1 terminated",
+				"kill 1 killed",
+				"poststop",
+				"umount /jails/web/proc",
+				"umount /jails/web/dev",
+				"umount /jails/web/tmp",
+				"release",
+			},
+		},
+		{
+			name: "stop fails",
+			fail: "jexec 1 /bin/sh /etc/rc.shutdown",
+			wantCalls: []string{
+				"prestop",
+				"jexec 1 /bin/sh /etc/rc.shutdown",
+				"poststop",
+				"umount /jails/web/proc",
+				"umount /jails/web/dev",
+				"umount /jails/web/tmp",
+				"release",
+			},
+			wantStep: "exec.stop",
+		},
+		{
+			name: "unmount fails",
+			fail: "umount /jails/web/dev",
+			wantCalls: []string{
+				"prestop",
+				"jexec 1 /bin/sh /etc/rc.shutdown",
+				"poststop",
+				"umount /jails/web/proc",
+				"umount /jails/web/dev",
+				"umount /jails/web/tmp",
+				"release",
+			},
+			wantStep: "unmount",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{procs: make(map[int32]int), ignore: make(map[syscall.Signal]bool)}
+			m, b := newTestManager(t, startConf, r)
+
+			jid, err := m.Start(context.Background(), "web")
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.calls = nil
+			r.procs[jid] = tt.procs
+			r.fail = map[string]bool{tt.fail: true}
+			for _, sig := range tt.ignore {
+				r.ignore[sig] = true
+			}
+
+			err = m.Stop(context.Background(), "web")
+			if tt.wantStep != "" {
+				var e *Error
+				if !errors.As(err, &e) || e.Step != tt.wantStep {
+					t.Fatalf("Manager.Stop() error = %v, want step %s", err, tt.wantStep)
+				}
+			} else if err != nil {
+				t.Fatalf("Manager.Stop() error = %v", err)
+			}
+			if !reflect.DeepEqual(r.calls, tt.wantCalls) {
+				t.Errorf("calls = %q, want %q", r.calls, tt.wantCalls)
+			}
+			if got := len(b.Jails()); got != tt.wantJails {
+				t.Errorf("jails = %d, want %d", got, tt.wantJails)
+			}
+		})
+	}
+}
+
+func TestManager_Stop_dying(t *testing.T) {
+	r := &recorder{}
+	m, b := newTestManager(t, `
+path = "/jails/$name";
+stop.timeout = 0;
+exec.poststop = "poststop";
+web { persist; }
+`, r)
+
+	jid, err := m.Start(context.Background(), "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jail.Attach(jid); err != nil {
+		t.Fatal(err)
+	}
+	r.calls = nil
+
+	err = m.Stop(context.Background(), "web")
+	var e *Error
+	if !errors.As(err, &e) || e.Step != "remove" || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Manager.Stop() error = %v, want step remove", err)
+	}
+	if want := []string{"poststop"}; !reflect.DeepEqual(r.calls, want) {
+		t.Errorf("calls = %q, want %q", r.calls, want)
+	}
+	if got := b.Jails(); len(got) != 1 || !got[0].Dying {
+		t.Errorf("jails = %+v, want one dying", got)
+	}
+}
+
+const vnetConf = `
+path = "/jails/$name";
+stop.timeout = 0;
+exec.created = "created";
+
+web {
+	vnet = new;
+	vnet.interface = "igb1";
+	persist;
+}
+`
+
+var webNetworks = map[string]*vnet.Config{
+	"web": {
+		Bridge: "bridge0",
+		Name:   "eth0",
+		Addrs:  []netip.Prefix{netip.MustParsePrefix("192.0.2.10/24")},
+	},
+}
+
+var vnetSetup = []string{
+	"ifconfig igb1 vnet 1",
+	"ifconfig epair create",
+	"ifconfig bridge0 addm epair0a",
+	"ifconfig -j 0 epair0a up",
+	"ifconfig epair0b vnet 1",
+	"ifconfig -j 1 epair0b name eth0",
+	"ifconfig -j 1 eth0 192.0.2.10/24",
+	"ifconfig -j 1 lo0 up",
+	"ifconfig -j 1 eth0 up",
+}
+
+func TestManager_vnet(t *testing.T) {
+	tests := []struct {
+		name        string
+		fail        string
+		restart     bool
+		wantStart   []string
+		wantStop    []string
+		wantErr     bool
+		wantStopErr bool
+	}{
+		{
+			name:      "start and stop",
+			wantStart: append(append([]string{}, vnetSetup...), "created"),
+			wantStop:  []string{"ifconfig epair0a destroy"},
+		},
+		{
+			name:        "stopped by another manager",
+			restart:     true,
+			wantStart:   append(append([]string{}, vnetSetup...), "created"),
+			wantStopErr: true,
+		},
+		{
+			name:      "created fails",
+			fail:      "created",
+			wantStart: append(append([]string{}, vnetSetup...), "created", "ifconfig epair0a destroy"),
+			wantErr:   true,
+		},
+		{
+			name:      "interface fails",
+			fail:      "ifconfig igb1 vnet 1",
+			wantStart: []string{"ifconfig igb1 vnet 1"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{fail: map[string]bool{tt.fail: true}}
+			m, b := newTestManager(t, vnetConf, r)
+			m.Networks = webNetworks
+
+			_, err := m.Start(context.Background(), "web")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Manager.Start() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(r.calls, tt.wantStart) {
+				t.Errorf("start calls = %q, want %q", r.calls, tt.wantStart)
+			}
+			if err != nil {
+				return
+			}
+
+			if tt.restart {
+				m = &Manager{Config: m.Config, Runner: r, Mounter: r, Killer: r, Network: m.Network, Networks: m.Networks}
+			}
+			r.calls = nil
+			if err := m.Stop(context.Background(), "web"); (err != nil) != tt.wantStopErr {
+				t.Fatalf("Manager.Stop() error = %v, wantStopErr %v", err, tt.wantStopErr)
+			}
+			if !reflect.DeepEqual(r.calls, tt.wantStop) {
+				t.Errorf("stop calls = %q, want %q", r.calls, tt.wantStop)
+			}
+			if got := b.Jails(); len(got) != 0 {
+				t.Errorf("jails = %+v, want none", got)
+			}
+		})
+	}
+}
+
+func TestManager_name(t *testing.T) {
+	const conf = `
+web {
+	name = "www";
+	persist;
+}
+`
+	m, b := newTestManager(t, conf, &recorder{})
+
+	jid, err := m.Start(context.Background(), "web")
+	if err != nil {
+		t.Fatalf("Manager.Start() error = %v", err)
+	}
+	if got, err := jail.ID("www"); err != nil || got != jid {
+		t.Errorf("jail.ID() = %d, %v, want %d", got, err, jid)
+	}
+	if err := m.StartAll(context.Background(), nil, "web"); err != nil {
+		t.Errorf("Manager.StartAll() error = %v", err)
+	}
+	if err := m.Stop(context.Background(), "web"); err != nil {
+		t.Fatalf("Manager.Stop() error = %v", err)
+	}
+	if got := b.Jails(); len(got) != 0 {
+		t.Errorf("jails = %+v, want none", got)
+	}
+	if err := m.StopAll(context.Background(), nil, "web"); err != nil {
+		t.Errorf("Manager.StopAll() error = %v", err)
+	}
+}
+
+func TestManager_Stop_notRunning(t *testing.T) {
+	m, _ := newTestManager(t, startConf, &recorder{})
+
+	if err := m.Stop(context.Background(), "web"); !errors.Is(err, jail.ErrNotFound) {
+		t.Errorf("Manager.Stop() error = %v, want %v", err, jail.ErrNotFound)
+	}
+}
@@ -0,0 +1,146 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package manager
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Mount is a file system mounted for a jail, in the form of an
+// fstab(5) entry.
+type Mount struct {
+	Source  string
+	Target  string
+	FSType  string
+	Options []string
+}
+
+// String returns the mount as an fstab(5) line.
+func (m Mount) String() string {
+	opts := strings.Join(m.Options, ",")
+	if opts == "" {
+		opts = "rw"
+	}
+
+	return m.Source + " " + m.Target + " " + m.FSType + " " + opts
+}
+
+// Mounter mounts and unmounts the file systems of jails.
+type Mounter interface {
+	Mount(ctx context.Context, m Mount) error
+	Unmount(ctx context.Context, m Mount) error
+}
+
+// ExecMounter is the Mounter used by default. It runs mount(8) and
+// umount(8).
+type ExecMounter struct{}
+
+// Mount mounts the file system.
+func (ExecMounter) Mount(ctx context.Context, m Mount) error {
+	args := []string{"-t", m.FSType}
+	if len(m.Options) > 0 {
+		args = append(args, "-o", strings.Join(m.Options, ","))
+	}
+	args = append(args, m.Source, m.Target)
+
+	return runMount(ctx, "/sbin/mount", args...)
+}
+
+// Unmount unmounts the file system.
+func (ExecMounter) Unmount(ctx context.Context, m Mount) error {
+	return runMount(ctx, "/sbin/umount", m.Target)
+}
+
+// runMount runs mount(8) or umount(8), returning their output in
+// the error on failure.
+func runMount(ctx context.Context, name string, args ...string) error {
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w: %s", filepath.Base(name), strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// parseFstab reads the entries of an fstab(5) file.
+func parseFstab(r io.Reader) ([]Mount, error) {
+	var mounts []Mount
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		if i := strings.IndexByte(line, '#'); i != -1 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		m, err := parseFstabLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		mounts = append(mounts, m)
+	}
+
+	return mounts, s.Err()
+}
+
+// parseFstabLine parses a single fstab(5) entry.
+func parseFstabLine(line string) (Mount, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return Mount{}, fmt.Errorf("invalid fstab entry %q", line)
+	}
+
+	m := Mount{Source: fields[0], Target: fields[1], FSType: fields[2]}
+	if len(fields) > 3 {
+		m.Options = strings.Split(fields[3], ",")
+	}
+
+	return m, nil
+}
+
+// defaultDevfsRuleset is the devfs ruleset used for jails that do not
+// set devfs_ruleset, devfsrules_jail from /etc/defaults/devfs.rules.
+const defaultDevfsRuleset = 4
+
+// devfsMount returns the mount of devfs in the jail at path.
+func devfsMount(path string, ruleset int) Mount {
+	return Mount{
+		Source:  "devfs",
+		Target:  filepath.Join(path, "dev"),
+		FSType:  "devfs",
+		Options: []string{"rw", "ruleset=" + strconv.Itoa(ruleset)},
+	}
+}
@@ -0,0 +1,121 @@
+package manager
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/briandowns/jail/jailconf"
+)
+
+func Test_parseFstab(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    []Mount
+		wantErr bool
+	}{
+		{
+			name: "entries",
+			src: `# Device	Mountpoint	FStype	Options	Dump	Pass#
+/usr/ports	/jails/web/usr/ports	nullfs	ro	0	0
+
+tmpfs /jails/web/tmp tmpfs rw,mode=1777 # scratch
+`,
+			want: []Mount{
+				{Source: "/usr/ports", Target: "/jails/web/usr/ports", FSType: "nullfs", Options: []string{"ro"}},
+				{Source: "tmpfs", Target: "/jails/web/tmp", FSType: "tmpfs", Options: []string{"rw", "mode=1777"}},
+			},
+		},
+		{
+			name: "empty",
+			src:  "# nothing\n\n",
+		},
+		{
+			name:    "missing type",
+			src:     "/usr/ports /jails/web/usr/ports\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFstab(strings.NewReader(tt.src))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFstab() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFstab() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMounts(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "all",
+			src: `web {
+	path = /jails/web;
+	mount = "/usr/ports /jails/web/usr/ports nullfs ro";
+	mount.devfs;
+	devfs_ruleset = 5;
+	mount.fdescfs;
+	mount.procfs;
+}`,
+			want: []string{
+				"/usr/ports /jails/web/usr/ports nullfs ro",
+				"devfs /jails/web/dev devfs rw,ruleset=5",
+				"fdescfs /jails/web/dev/fd fdescfs rw",
+				"proc /jails/web/proc procfs rw",
+			},
+		},
+		{
+			name: "default ruleset",
+			src:  `web { path = /jails/web; mount.devfs; }`,
+			want: []string{"devfs /jails/web/dev devfs rw,ruleset=4"},
+		},
+		{
+			name: "none",
+			src:  `web { path = /jails/web; mount.nodevfs; }`,
+		},
+		{
+			name:    "devfs without path",
+			src:     `web { mount.devfs; }`,
+			wantErr: true,
+		},
+		{
+			name:    "missing fstab",
+			src:     `web { path = /jails/web; mount.fstab = testdata/missing; }`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := jailconf.Parse("jail.conf", []byte(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			j, err := jailconf.ResolveJail(f, "web")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mounts, err := Mounts(j)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Mounts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, m := range mounts {
+				got = append(got, m.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Mounts() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
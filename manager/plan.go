@@ -0,0 +1,318 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/briandowns/jail"
+	"github.com/briandowns/jail/jailconf"
+)
+
+// CycleError is returned when jails depend on one another through
+// depend.
+type CycleError struct {
+	// Cycle holds the jails in the cycle, beginning and ending
+	// with the same jail.
+	Cycle []string
+}
+
+func (e *CycleError) Error() string {
+	return "dependency cycle: " + strings.Join(e.Cycle, " -> ")
+}
+
+// Plan is the order jails are started or stopped in. The jails of
+// each stage only depend on those of earlier stages when starting,
+// or later stages when stopping, so those within a stage can be
+// handled at once.
+type Plan struct {
+	Stop   bool
+	Stages [][]string
+}
+
+// String returns the plan with a line for each stage.
+func (p *Plan) String() string {
+	op := "start"
+	if p.Stop {
+		op = "stop"
+	}
+
+	var sb strings.Builder
+	for i, stage := range p.Stages {
+		sb.WriteString(strconv.Itoa(i+1) + ": " + op + " " + strings.Join(stage, ", ") + "\n")
+	}
+
+	return sb.String()
+}
+
+// StartPlan returns the order the named jails, or every jail in the
+// configuration if none are named, are started in. The jails they
+// depend on are included before them. An error wrapping
+// jail.ErrNotFound is returned for an undefined jail and a
+// *CycleError when jails depend on one another.
+func (m *Manager) StartPlan(names ...string) (*Plan, error) {
+	return m.plan(names, false)
+}
+
+// StopPlan returns the order the named jails, or every jail in the
+// configuration if none are named, are stopped in. Each jail is
+// stopped before those it depends on.
+func (m *Manager) StopPlan(names ...string) (*Plan, error) {
+	return m.plan(names, true)
+}
+
+// plan orders the jails by their dependencies.
+func (m *Manager) plan(names []string, stop bool) (*Plan, error) {
+	jails, err := jailconf.Resolve(m.Config)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*jailconf.Jail, len(jails))
+	for _, j := range jails {
+		byName[j.Name] = j
+	}
+
+	if len(names) == 0 {
+		for _, j := range jails {
+			names = append(names, j.Name)
+		}
+	}
+	for _, name := range names {
+		if _, ok := byName[name]; !ok {
+			return nil, fmt.Errorf("%w: %s", jail.ErrNotFound, name)
+		}
+	}
+
+	// Jails are only started along with the jails they depend on,
+	// those being stopped are taken as named.
+	want := make(map[string]bool)
+	var add func(name string) error
+	add = func(name string) error {
+		if want[name] {
+			return nil
+		}
+		want[name] = true
+		if stop {
+			return nil
+		}
+		for _, d := range byName[name].Pseudo["depend"] {
+			if _, ok := byName[d]; !ok {
+				return fmt.Errorf("%w: jail %s depends on undefined jail %s", jail.ErrNotFound, name, d)
+			}
+			if err := add(d); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, name := range names {
+		if err := add(name); err != nil {
+			return nil, err
+		}
+	}
+
+	// The depth of a jail is one more than the deepest jail it
+	// depends on, and gives the stage it is started in.
+	depth := make(map[string]int)
+	visiting := make(map[string]bool)
+	var (
+		stack []string
+		visit func(name string) error
+	)
+	visit = func(name string) error {
+		if _, ok := depth[name]; ok {
+			return nil
+		}
+		if visiting[name] {
+			i := len(stack) - 1
+			for stack[i] != name {
+				i--
+			}
+			return &CycleError{Cycle: append(append([]string(nil), stack[i:]...), name)}
+		}
+		visiting[name] = true
+		stack = append(stack, name)
+
+		d := 0
+		for _, dep := range byName[name].Pseudo["depend"] {
+			if !want[dep] {
+				continue
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+			d = max(d, depth[dep]+1)
+		}
+
+		stack = stack[:len(stack)-1]
+		delete(visiting, name)
+		depth[name] = d
+		return nil
+	}
+
+	p := &Plan{Stop: stop}
+	for _, j := range jails {
+		if !want[j.Name] {
+			continue
+		}
+		if err := visit(j.Name); err != nil {
+			return nil, err
+		}
+	}
+	for _, j := range jails {
+		d, ok := depth[j.Name]
+		if !ok {
+			continue
+		}
+		for len(p.Stages) <= d {
+			p.Stages = append(p.Stages, nil)
+		}
+		p.Stages[d] = append(p.Stages[d], j.Name)
+	}
+	if stop {
+		for i, j := 0, len(p.Stages)-1; i < j; i, j = i+1, j-1 {
+			p.Stages[i], p.Stages[j] = p.Stages[j], p.Stages[i]
+		}
+	}
+
+	return p, nil
+}
+
+// Options controls how StartAll and StopAll carry out a plan.
+type Options struct {
+	// Parallel is the most jails handled at once. The jails are
+	// handled one at a time if it is less than 2.
+	Parallel int
+
+	// DryRun writes the plan to Out without carrying it out.
+	DryRun bool
+	Out    io.Writer
+}
+
+// StartAll starts the named jails, or every jail in the configuration
+// if none are named, along with the jails they depend on in the order
+// given by StartPlan. Jails already running are skipped. When a jail
+// fails to start the jails being started alongside it are finished
+// and no later stage is begun.
+func (m *Manager) StartAll(ctx context.Context, opts *Options, names ...string) error {
+	p, err := m.StartPlan(names...)
+	if err != nil {
+		return err
+	}
+
+	return m.run(ctx, p, opts, func(ctx context.Context, name string) error {
+		if _, err := m.lookup(name); err == nil {
+			return nil
+		}
+		_, err := m.Start(ctx, name)
+		return err
+	})
+}
+
+// StopAll stops the named jails, or every jail in the configuration
+// if none are named, in the order given by StopPlan. Jails that are
+// not running are skipped.
+func (m *Manager) StopAll(ctx context.Context, opts *Options, names ...string) error {
+	p, err := m.StopPlan(names...)
+	if err != nil {
+		return err
+	}
+
+	return m.run(ctx, p, opts, func(ctx context.Context, name string) error {
+		if _, err := m.lookup(name); errors.Is(err, jail.ErrNotFound) {
+			return nil
+		}
+		return m.Stop(ctx, name)
+	})
+}
+
+// lookup returns the JID of the named jail if it is running.
+func (m *Manager) lookup(name string) (int32, error) {
+	j, err := jailconf.ResolveJail(m.Config, name)
+	if err != nil {
+		return 0, err
+	}
+
+	return jail.ID(jailName(j))
+}
+
+// run calls fn for each jail of the plan, stage by stage.
+func (m *Manager) run(ctx context.Context, p *Plan, opts *Options, fn func(context.Context, string) error) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if opts.DryRun {
+		if opts.Out == nil {
+			return nil
+		}
+		_, err := io.WriteString(opts.Out, p.String())
+		return err
+	}
+
+	sem := make(chan struct{}, max(opts.Parallel, 1))
+	for _, stage := range p.Stages {
+		var (
+			wg   sync.WaitGroup
+			mu   sync.Mutex
+			errs []error
+		)
+		for _, name := range stage {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				wg.Wait()
+				return errors.Join(append(errs, ctx.Err())...)
+			}
+			wg.Add(1)
+			go func() {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				if err := fn(ctx, name); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if err := errors.Join(errs...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
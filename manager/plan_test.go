@@ -0,0 +1,211 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/briandowns/jail"
+)
+
+const planConf = `
+path = "/jails/$name";
+persist;
+
+db {}
+cache {}
+app {
+	depend = db, cache;
+}
+web {
+	depend = app;
+}
+mail {}
+`
+
+func TestManager_StartPlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		jails   []string
+		want    [][]string
+		wantErr error
+	}{
+		{
+			name: "all",
+			src:  planConf,
+			want: [][]string{{"db", "cache", "mail"}, {"app"}, {"web"}},
+		},
+		{
+			name:  "dependencies included",
+			src:   planConf,
+			jails: []string{"web"},
+			want:  [][]string{{"db", "cache"}, {"app"}, {"web"}},
+		},
+		{
+			name:  "no dependencies",
+			src:   planConf,
+			jails: []string{"mail"},
+			want:  [][]string{{"mail"}},
+		},
+		{
+			name:    "undefined jail",
+			src:     planConf,
+			jails:   []string{"ftp"},
+			wantErr: jail.ErrNotFound,
+		},
+		{
+			name:    "undefined dependency",
+			src:     `web { depend = db; }`,
+			wantErr: jail.ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestManager(t, tt.src, &recorder{})
+
+			p, err := m.StartPlan(tt.jails...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Manager.StartPlan() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(p.Stages, tt.want) {
+				t.Errorf("Manager.StartPlan() = %q, want %q", p.Stages, tt.want)
+			}
+		})
+	}
+}
+
+func TestManager_StopPlan(t *testing.T) {
+	m, _ := newTestManager(t, planConf, &recorder{})
+
+	p, err := m.StopPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"web"}, {"app"}, {"db", "cache", "mail"}}
+	if !reflect.DeepEqual(p.Stages, want) {
+		t.Errorf("Manager.StopPlan() = %q, want %q", p.Stages, want)
+	}
+
+	p, err = m.StopPlan("db", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = [][]string{{"db", "web"}}
+	if !reflect.DeepEqual(p.Stages, want) {
+		t.Errorf("Manager.StopPlan() = %q, want %q", p.Stages, want)
+	}
+}
+
+func TestManager_StartPlan_cycle(t *testing.T) {
+	m, _ := newTestManager(t, `
+a { depend = b; }
+b { depend = c; }
+c { depend = a; }
+d {}
+`, &recorder{})
+
+	_, err := m.StartPlan()
+	var e *CycleError
+	if !errors.As(err, &e) {
+		t.Fatalf("Manager.StartPlan() error = %v, want *CycleError", err)
+	}
+	if got, want := err.Error(), "dependency cycle: a -> b -> c -> a"; got != want {
+		t.Errorf("Manager.StartPlan() error = %q, want %q", got, want)
+	}
+}
+
+func TestPlan_String(t *testing.T) {
+	p := &Plan{Stages: [][]string{{"db", "cache"}, {"app"}}}
+	want := "1: start db, cache\n2: start app\n"
+	if got := p.String(); got != want {
+		t.Errorf("Plan.String() = %q, want %q", got, want)
+	}
+
+	p.Stop = true
+	want = "1: stop db, cache\n2: stop app\n"
+	if got := p.String(); got != want {
+		t.Errorf("Plan.String() = %q, want %q", got, want)
+	}
+}
+
+func TestManager_StartAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     *Options
+		jails    []string
+		fail     string
+		want     []string
+		wantErr  bool
+		wantPlan string
+	}{
+		{
+			name: "sequential",
+			want: []string{"app", "cache", "db", "mail", "web"},
+		},
+		{
+			name: "parallel",
+			opts: &Options{Parallel: 3},
+			want: []string{"app", "cache", "db", "mail", "web"},
+		},
+		{
+			name:     "dry run",
+			opts:     &Options{DryRun: true},
+			jails:    []string{"web"},
+			wantPlan: "1: start db, cache\n2: start app\n3: start web\n",
+		},
+		{
+			name:    "dependency fails",
+			fail:    "/jails/cache/dev",
+			want:    []string{"db", "mail"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{}
+			m, b := newTestManager(t, planConf+"mount.devfs;\n", r)
+			r.fail = map[string]bool{"mount " + tt.fail: true}
+			var out bytes.Buffer
+			if tt.opts != nil {
+				tt.opts.Out = &out
+			}
+
+			err := m.StartAll(context.Background(), tt.opts, tt.jails...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Manager.StartAll() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, j := range b.Jails() {
+				got = append(got, j.Name)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("running = %q, want %q", got, tt.want)
+			}
+			if out.String() != tt.wantPlan {
+				t.Errorf("plan = %q, want %q", out.String(), tt.wantPlan)
+			}
+		})
+	}
+}
+
+func TestManager_StopAll(t *testing.T) {
+	m, b := newTestManager(t, planConf, &recorder{})
+
+	if err := m.StartAll(context.Background(), nil, "web"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.StopAll(context.Background(), &Options{Parallel: 2}); err != nil {
+		t.Fatalf("Manager.StopAll() error = %v", err)
+	}
+	if got := b.Jails(); len(got) != 0 {
+		t.Errorf("running = %+v, want none", got)
+	}
+}
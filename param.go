@@ -0,0 +1,527 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package jail
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Flags describing how a parameter's value is handled.
+const (
+	JailRawValue      = 0x01
+	JailBool          = 0x02
+	JailParamNoBool   = 0x04
+	JailParamSys      = 0x80
+	JailParamReadOnly = 0x100
+)
+
+// CTLTYPE values from sys/sysctl.h giving the type of a parameter.
+const (
+	CtlTypeNode   = 1
+	CtlTypeInt    = 2
+	CtlTypeString = 3
+	CtlTypeS64    = 4
+	CtlTypeStruct = 5
+	CtlTypeUint   = 6
+	CtlTypeLong   = 7
+	CtlTypeUlong  = 8
+	CtlTypeU64    = 9
+)
+
+// Struct types of parameters holding arrays of structures.
+const (
+	StructInAddr  = 1
+	StructIn6Addr = 2
+)
+
+// sysctl kind flags and mask from sys/sysctl.h.
+const (
+	ctlTypeMask = 0xf
+	ctlFlagRD   = 0x80000000
+	ctlFlagWR   = 0x40000000
+	ctlFlagRW   = ctlFlagRD | ctlFlagWR
+)
+
+// paramSysctlPrefix is the sysctl tree the kernel describes its
+// jail parameters under.
+const paramSysctlPrefix = "security.jail.param."
+
+// JailParam describes a jail parameter known to the kernel, as
+// jailparam_init(3) does. CtlType holds the CTLTYPE of the value,
+// ValueLen the maximum size of the value in bytes and ElemLen the
+// size of each element of array values.
+type JailParam struct {
+	Name       string
+	Value      interface{}
+	ValueLen   int
+	ElemLen    int
+	CtlType    int
+	StructType int
+	Flags      int
+}
+
+// builtinParams contains the parameters of FreeBSD 14 in the form
+// they are described by the security.jail.param sysctls.
+var builtinParams = []struct {
+	name   string
+	kind   uint32
+	format string
+	size   int
+}{
+	{"jid", CtlTypeInt | ctlFlagRW, "I", 0},
+	{"lastjid", CtlTypeInt | ctlFlagRD, "I", 0},
+	{"errmsg", CtlTypeString | ctlFlagRD, "A", ErrMsgLen},
+	{"name", CtlTypeString | ctlFlagRW, "A", 256},
+	{"path", CtlTypeString | ctlFlagRW, "A", 1024},
+	{"parent", CtlTypeInt | ctlFlagRD, "I", 0},
+	{"securelevel", CtlTypeInt | ctlFlagRW, "I", 0},
+	{"devfs_ruleset", CtlTypeInt | ctlFlagRW, "I", 0},
+	{"enforce_statfs", CtlTypeInt | ctlFlagRW, "I", 0},
+	{"children.max", CtlTypeInt | ctlFlagRW, "I", 0},
+	{"children.cur", CtlTypeInt | ctlFlagRD, "I", 0},
+	{"cpuset.id", CtlTypeInt | ctlFlagRD, "I", 0},
+	{"persist", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"dying", CtlTypeInt | ctlFlagRD, "B", 0},
+	{"osrelease", CtlTypeString | ctlFlagRW, "A", 32},
+	{"osreldate", CtlTypeInt | ctlFlagRW, "I", 0},
+	{"vnet", CtlTypeInt | ctlFlagRW, "E,jailsys", 0},
+	{"host", CtlTypeInt | ctlFlagRW, "E,jailsys", 0},
+	{"host.hostname", CtlTypeString | ctlFlagRW, "A", 256},
+	{"host.domainname", CtlTypeString | ctlFlagRW, "A", 256},
+	{"host.hostuuid", CtlTypeString | ctlFlagRW, "A", 64},
+	{"host.hostid", CtlTypeUlong | ctlFlagRW, "LU", 0},
+	{"ip4", CtlTypeInt | ctlFlagRW, "E,jailsys", 0},
+	{"ip4.addr", CtlTypeStruct | ctlFlagRW, "S,in_addr", net.IPv4len},
+	{"ip4.saddrsel", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"ip6", CtlTypeInt | ctlFlagRW, "E,jailsys", 0},
+	{"ip6.addr", CtlTypeStruct | ctlFlagRW, "S,in6_addr", net.IPv6len},
+	{"ip6.saddrsel", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"sysvmsg", CtlTypeInt | ctlFlagRW, "E,jailsys", 0},
+	{"sysvsem", CtlTypeInt | ctlFlagRW, "E,jailsys", 0},
+	{"sysvshm", CtlTypeInt | ctlFlagRW, "E,jailsys", 0},
+	{"linux", CtlTypeInt | ctlFlagRW, "E,jailsys", 0},
+	{"linux.osname", CtlTypeString | ctlFlagRW, "A", 64},
+	{"linux.osrelease", CtlTypeString | ctlFlagRW, "A", 64},
+	{"linux.oss_version", CtlTypeInt | ctlFlagRW, "I", 0},
+	{"meta", CtlTypeString | ctlFlagRW, "A", 4096},
+	{"env", CtlTypeString | ctlFlagRW, "A", 4096},
+	{"zfs.mount_snapshot", CtlTypeInt | ctlFlagRW, "I", 0},
+	{"allow.set_hostname", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.sysvipc", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.raw_sockets", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.chflags", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.mount", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.mount.devfs", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.mount.fdescfs", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.mount.fusefs", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.mount.linprocfs", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.mount.linsysfs", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.mount.nullfs", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.mount.procfs", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.mount.tmpfs", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.mount.zfs", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.quotas", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.socket_af", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.mlock", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.nfsd", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.reserved_ports", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.read_msgbuf", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.unprivileged_proc_debug", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.unprivileged_parent_tampering", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.suser", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.extattr", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.adjtime", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.settime", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.routing", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.setaudit", CtlTypeInt | ctlFlagRW, "B", 0},
+	{"allow.vmm", CtlTypeInt | ctlFlagRW, "B", 0},
+}
+
+// JailSys is the value of a jailsys parameter, such as host, ip4, ip6
+// or vnet, which controls whether the jail has its own copy of the
+// system resource, inherits its parent's or has none at all.
+type JailSys int32
+
+// Values of jailsys parameters from sys/jail.h.
+const (
+	JailSysDisable JailSys = 0
+	JailSysNew     JailSys = 1
+	JailSysInherit JailSys = 2
+)
+
+// String returns the name used for the value in jail.conf(5).
+func (s JailSys) String() string {
+	switch s {
+	case JailSysDisable:
+		return "disable"
+	case JailSysNew:
+		return "new"
+	case JailSysInherit:
+		return "inherit"
+	}
+
+	return "JailSys(" + strconv.Itoa(int(s)) + ")"
+}
+
+// ParseJailSys converts the jail.conf(5) name of a jailsys value,
+// new, inherit or disable, to a JailSys.
+func ParseJailSys(s string) (JailSys, error) {
+	switch s {
+	case "disable":
+		return JailSysDisable, nil
+	case "new":
+		return JailSysNew, nil
+	case "inherit":
+		return JailSysInherit, nil
+	}
+
+	return 0, fmt.Errorf("%w: invalid jailsys value %q", ErrInvalidValue, s)
+}
+
+// catalog holds every known parameter keyed by name.
+var catalog = struct {
+	sync.RWMutex
+	params map[string]JailParam
+}{
+	params: make(map[string]JailParam),
+}
+
+func init() {
+	for _, p := range builtinParams {
+		jp, err := newParam(p.name, p.kind, p.format, p.size)
+		if err != nil {
+			panic(err)
+		}
+		catalog.params[jp.Name] = jp
+	}
+}
+
+// newParam describes the named parameter from the sysctl kind and
+// format of its node. Size is the maximum length of string parameters
+// and the element size of structure parameters.
+func newParam(name string, kind uint32, format string, size int) (JailParam, error) {
+	p := JailParam{
+		Name:    strings.TrimSuffix(name, "."),
+		CtlType: int(kind & ctlTypeMask),
+	}
+	if kind&ctlFlagWR == 0 {
+		p.Flags |= JailParamReadOnly
+	}
+
+	switch p.CtlType {
+	case CtlTypeInt:
+		// Formats other than these are taken as a plain int, as
+		// libjail does, so a parameter added by a newer kernel
+		// does not make the whole catalog fail to load.
+		switch format {
+		case "IU":
+			p.CtlType = CtlTypeUint
+		case "B":
+			p.Flags |= JailBool
+		case "E,jailsys":
+			p.Flags |= JailParamSys
+		}
+		p.ValueLen = 4
+	case CtlTypeUint:
+		p.ValueLen = 4
+	case CtlTypeLong, CtlTypeUlong, CtlTypeS64, CtlTypeU64:
+		p.ValueLen = 8
+	case CtlTypeString:
+		p.ValueLen = size
+	case CtlTypeStruct:
+		p.ElemLen = size
+		p.ValueLen = maxAFIPs * size
+		switch format {
+		case "S,in_addr":
+			p.StructType = StructInAddr
+		case "S,in6_addr":
+			p.StructType = StructIn6Addr
+		default:
+			p.Flags |= JailRawValue
+		}
+	default:
+		return JailParam{}, fmt.Errorf("unknown type %d for parameter: %s", p.CtlType, name)
+	}
+
+	return p, nil
+}
+
+// parseParam describes the parameter found at the given sysctl node
+// under security.jail.param. Value is what reading the node returned,
+// the decimal maximum length of string parameters or the size_t element
+// size of structure parameters.
+func parseParam(node string, kind uint32, format string, value []byte) (JailParam, error) {
+	name := strings.TrimPrefix(node, paramSysctlPrefix)
+
+	var size int
+	switch kind & ctlTypeMask {
+	case CtlTypeString:
+		s := string(value)
+		if i := strings.IndexByte(s, 0); i != -1 {
+			s = s[:i]
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return JailParam{}, fmt.Errorf("invalid length %q for parameter: %s", s, name)
+		}
+		size = n
+	case CtlTypeStruct:
+		switch len(value) {
+		case 4:
+			size = int(binary.NativeEndian.Uint32(value))
+		case 8:
+			size = int(binary.NativeEndian.Uint64(value))
+		default:
+			return JailParam{}, fmt.Errorf("invalid size for parameter: %s", name)
+		}
+	}
+
+	return newParam(name, kind, format, size)
+}
+
+// addParam adds the parameter to the catalog, replacing any
+// existing description of it.
+func addParam(p JailParam) {
+	catalog.Lock()
+	defer catalog.Unlock()
+
+	catalog.params[p.Name] = p
+}
+
+// LookupParam returns the description of the named parameter and
+// whether it is known. Boolean parameters can also be looked up by
+// their "no" prefixed name, such as nopersist or allow.nomount, in
+// which case the JailParamNoBool flag is set.
+func LookupParam(name string) (JailParam, bool) {
+	catalog.RLock()
+	defer catalog.RUnlock()
+
+	if p, ok := catalog.params[name]; ok {
+		return p, true
+	}
+
+	i := strings.LastIndexByte(name, '.') + 1
+	if !strings.HasPrefix(name[i:], "no") {
+		return JailParam{}, false
+	}
+	p, ok := catalog.params[name[:i]+name[i+2:]]
+	if !ok || p.Flags&JailBool == 0 {
+		return JailParam{}, false
+	}
+	p.Name = name
+	p.Flags |= JailParamNoBool
+
+	return p, true
+}
+
+// ParamNames returns the names of every known parameter in
+// sorted order.
+func ParamNames() []string {
+	catalog.RLock()
+	defer catalog.RUnlock()
+
+	names := make([]string, 0, len(catalog.params))
+	for name := range catalog.params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// LoadParams walks the security.jail.param sysctl tree and adds
+// every parameter the running kernel describes to the catalog so
+// parameters added by newer kernels and modules are recognized. It
+// does nothing on systems without jail support.
+func LoadParams() error {
+	params, err := sysctlParams()
+	if err != nil {
+		return err
+	}
+
+	for _, p := range params {
+		addParam(p)
+	}
+
+	return nil
+}
+
+// ReadOnly reports whether the parameter can only be retrieved.
+func (p JailParam) ReadOnly() bool {
+	return p.Flags&JailParamReadOnly != 0
+}
+
+// boolName returns the name a boolean parameter is sent to the
+// kernel under for the given value. The plain name is used when the
+// parameter is to be enabled and the "no" prefixed one otherwise.
+func (p JailParam) boolName(v bool) string {
+	i := strings.LastIndexByte(p.Name, '.') + 1
+	base := p.Name
+	if p.Flags&JailParamNoBool != 0 {
+		base = p.Name[:i] + p.Name[i+2:]
+		v = !v
+	}
+	if v {
+		return base
+	}
+
+	return base[:i] + "no" + base[i:]
+}
+
+// Zero returns the zero value of the Go type values of the
+// parameter are held in.
+func (p JailParam) Zero() interface{} {
+	switch {
+	case p.Flags&JailBool != 0:
+		return false
+	case p.Flags&JailParamSys != 0:
+		return JailSys(0)
+	case p.StructType != 0:
+		return []netip.Addr(nil)
+	}
+
+	switch p.CtlType {
+	case CtlTypeInt:
+		return int32(0)
+	case CtlTypeUint:
+		return uint32(0)
+	case CtlTypeLong, CtlTypeS64:
+		return int64(0)
+	case CtlTypeUlong, CtlTypeU64:
+		return uint64(0)
+	case CtlTypeString:
+		return ""
+	}
+
+	return []byte(nil)
+}
+
+// Convert converts the given value to the Go type values of the
+// parameter are held in: int32, uint32, int64 or uint64 for integers,
+// string, bool, JailSys and []netip.Addr for addresses. Integers of any
+// size are accepted as long as the value fits and jailsys parameters
+// also accept the names new, inherit and disable.
+func (p JailParam) Convert(v interface{}) (interface{}, error) {
+	zero := p.Zero()
+	switch zero.(type) {
+	case JailSys:
+		if s, ok := v.(string); ok {
+			js, err := ParseJailSys(s)
+			if err != nil {
+				return nil, err
+			}
+			return js, nil
+		}
+	case []netip.Addr:
+		return p.convertAddrs(v)
+	case []byte:
+		if b, ok := v.([]byte); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("%w: %s requires a []byte value", ErrInvalidValue, p.Name)
+	}
+
+	rv := reflect.ValueOf(v)
+	zt := reflect.TypeOf(zero)
+	out := reflect.New(zt).Elem()
+	switch zt.Kind() {
+	case reflect.String:
+		if rv.Kind() == reflect.String {
+			out.SetString(rv.String())
+			return out.Interface(), nil
+		}
+	case reflect.Bool:
+		if rv.Kind() == reflect.Bool {
+			out.SetBool(rv.Bool())
+			return out.Interface(), nil
+		}
+	case reflect.Int32, reflect.Int64, reflect.Uint32, reflect.Uint64:
+		var (
+			n   int64
+			u   uint64
+			neg bool
+		)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = rv.Int()
+			u, neg = uint64(n), n < 0
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			u = rv.Uint()
+			n = int64(u)
+			if u > math.MaxInt64 {
+				n = -1
+			}
+		default:
+			return nil, fmt.Errorf("%w: %s requires an integer value, got %T", ErrInvalidValue, p.Name, v)
+		}
+		if out.CanInt() {
+			if (!neg && u > math.MaxInt64) || out.OverflowInt(n) {
+				return nil, fmt.Errorf("%w: %v out of range for %s", ErrInvalidValue, v, p.Name)
+			}
+			out.SetInt(n)
+		} else {
+			if neg || out.OverflowUint(u) {
+				return nil, fmt.Errorf("%w: %v out of range for %s", ErrInvalidValue, v, p.Name)
+			}
+			out.SetUint(u)
+		}
+		return out.Interface(), nil
+	}
+
+	return nil, fmt.Errorf("%w: %s requires a %s value, got %T", ErrInvalidValue, p.Name, zt, v)
+}
+
+// convertAddrs converts the given address or addresses in to a slice
+// of netip.Addr of the parameter's family.
+func (p JailParam) convertAddrs(v interface{}) (interface{}, error) {
+	addrs, err := ToAddrs(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", p.Name, err)
+	}
+
+	for i, a := range addrs {
+		if p.StructType == StructInAddr {
+			a = a.Unmap()
+			addrs[i] = a
+		}
+		if (p.StructType == StructInAddr) != a.Is4() {
+			return nil, fmt.Errorf("%w: %s is of the wrong family for %s", ErrInvalidValue, a, p.Name)
+		}
+	}
+
+	return addrs, nil
+}
@@ -0,0 +1,306 @@
+package jail
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestLookupParam(t *testing.T) {
+	tests := []struct {
+		name      string
+		param     string
+		wantOK    bool
+		wantFlags int
+		wantType  int
+	}{
+		{name: "int", param: "jid", wantOK: true, wantType: CtlTypeInt},
+		{name: "string", param: "path", wantOK: true, wantType: CtlTypeString},
+		{name: "bool", param: "persist", wantOK: true, wantFlags: JailBool, wantType: CtlTypeInt},
+		{name: "no bool", param: "nopersist", wantOK: true, wantFlags: JailBool | JailParamNoBool, wantType: CtlTypeInt},
+		{name: "nested no bool", param: "allow.mount.nozfs", wantOK: true, wantFlags: JailBool | JailParamNoBool, wantType: CtlTypeInt},
+		{name: "jailsys", param: "vnet", wantOK: true, wantFlags: JailParamSys, wantType: CtlTypeInt},
+		{name: "read only", param: "dying", wantOK: true, wantFlags: JailBool | JailParamReadOnly, wantType: CtlTypeInt},
+		{name: "ulong", param: "host.hostid", wantOK: true, wantType: CtlTypeUlong},
+		{name: "struct", param: "ip6.addr", wantOK: true, wantType: CtlTypeStruct},
+		{name: "no prefix on non bool", param: "nopath", wantOK: false},
+		{name: "typo", param: "allow.raw_socket", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := LookupParam(tt.param)
+			if ok != tt.wantOK {
+				t.Fatalf("LookupParam() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if got.Name != tt.param {
+				t.Errorf("LookupParam() name = %q, want %q", got.Name, tt.param)
+			}
+			if got.Flags != tt.wantFlags {
+				t.Errorf("LookupParam() flags = %#x, want %#x", got.Flags, tt.wantFlags)
+			}
+			if got.CtlType != tt.wantType {
+				t.Errorf("LookupParam() type = %d, want %d", got.CtlType, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestJailParam_Convert(t *testing.T) {
+	tests := []struct {
+		name    string
+		param   string
+		value   interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "int", param: "jid", value: 5, want: int32(5)},
+		{name: "uint to int", param: "children.max", value: uint8(5), want: int32(5)},
+		{name: "int overflow", param: "jid", value: int64(1) << 33, wantErr: true},
+		{name: "negative ulong", param: "host.hostid", value: -1, wantErr: true},
+		{name: "ulong", param: "host.hostid", value: uint64(1) << 63, want: uint64(1) << 63},
+		{name: "string", param: "path", value: "/jails/web", want: "/jails/web"},
+		{name: "string for int", param: "jid", value: "5", wantErr: true},
+		{name: "bool", param: "allow.mount", value: true, want: true},
+		{name: "int for bool", param: "persist", value: 1, wantErr: true},
+		{name: "jailsys name", param: "vnet", value: "new", want: JailSysNew},
+		{name: "jailsys int", param: "ip4", value: 2, want: JailSysInherit},
+		{name: "jailsys bad name", param: "host", value: "shared", wantErr: true},
+		{
+			name:  "net.IP",
+			param: "ip4.addr",
+			value: net.ParseIP("192.0.2.1"),
+			want:  []netip.Addr{netip.MustParseAddr("192.0.2.1")},
+		},
+		{
+			name:  "netip",
+			param: "ip6.addr",
+			value: []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+			want:  []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+		},
+		{name: "wrong family", param: "ip6.addr", value: netip.MustParseAddr("192.0.2.1"), wantErr: true},
+		{
+			name:  "cidr strings",
+			param: "ip4.addr",
+			value: []string{"192.0.2.1/24", "127.0.1.1"},
+			want:  []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("127.0.1.1")},
+		},
+		{name: "bad string for addr", param: "ip4.addr", value: "192.0.2", wantErr: true},
+		{name: "int for addr", param: "ip4.addr", value: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, ok := LookupParam(tt.param)
+			if !ok {
+				t.Fatalf("unknown param %s", tt.param)
+			}
+			got, err := p.Convert(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("JailParam.Convert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidValue) {
+				t.Errorf("JailParam.Convert() error = %v, want %v", err, ErrInvalidValue)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("JailParam.Convert() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseParam(t *testing.T) {
+	tests := []struct {
+		name    string
+		node    string
+		kind    uint32
+		format  string
+		value   []byte
+		want    JailParam
+		wantErr bool
+	}{
+		{
+			name:   "string",
+			node:   "security.jail.param.host.hostname",
+			kind:   CtlTypeString | ctlFlagRW,
+			format: "A",
+			value:  []byte("256\x00"),
+			want:   JailParam{Name: "host.hostname", CtlType: CtlTypeString, ValueLen: 256},
+		},
+		{
+			name:   "jailsys node",
+			node:   "security.jail.param.vnet.",
+			kind:   CtlTypeInt | ctlFlagRW,
+			format: "E,jailsys",
+			want:   JailParam{Name: "vnet", CtlType: CtlTypeInt, ValueLen: 4, Flags: JailParamSys},
+		},
+		{
+			name:   "read only bool",
+			node:   "security.jail.param.dying",
+			kind:   CtlTypeInt | ctlFlagRD,
+			format: "B",
+			want:   JailParam{Name: "dying", CtlType: CtlTypeInt, ValueLen: 4, Flags: JailBool | JailParamReadOnly},
+		},
+		{
+			name:   "uint",
+			node:   "security.jail.param.devfs_ruleset",
+			kind:   CtlTypeInt | ctlFlagRW,
+			format: "IU",
+			want:   JailParam{Name: "devfs_ruleset", CtlType: CtlTypeUint, ValueLen: 4},
+		},
+		{
+			name:   "in6_addr",
+			node:   "security.jail.param.ip6.addr",
+			kind:   CtlTypeStruct | ctlFlagRW,
+			format: "S,in6_addr",
+			value:  binary.NativeEndian.AppendUint64(nil, 16),
+			want: JailParam{
+				Name:       "ip6.addr",
+				CtlType:    CtlTypeStruct,
+				StructType: StructIn6Addr,
+				ElemLen:    16,
+				ValueLen:   maxAFIPs * 16,
+			},
+		},
+		{
+			name:   "unknown struct",
+			node:   "security.jail.param.mac.label",
+			kind:   CtlTypeStruct | ctlFlagRW,
+			format: "S,mac",
+			value:  binary.NativeEndian.AppendUint64(nil, 8),
+			want: JailParam{
+				Name:     "mac.label",
+				CtlType:  CtlTypeStruct,
+				ElemLen:  8,
+				ValueLen: maxAFIPs * 8,
+				Flags:    JailRawValue,
+			},
+		},
+		{
+			name:    "bad length",
+			node:    "security.jail.param.path",
+			kind:    CtlTypeString | ctlFlagRW,
+			format:  "A",
+			value:   []byte("x"),
+			wantErr: true,
+		},
+		{
+			name:   "unknown int format",
+			node:   "security.jail.param.foo",
+			kind:   CtlTypeInt | ctlFlagRW,
+			format: "K",
+			want:   JailParam{Name: "foo", CtlType: CtlTypeInt, ValueLen: 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseParam(tt.node, tt.kind, tt.format, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseParam() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseParam() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParams_Add(t *testing.T) {
+	p := NewParams()
+	if err := p.Add("jid", 3); err != nil {
+		t.Fatalf("Params.Add() error = %v", err)
+	}
+	if p["jid"] != int32(3) {
+		t.Errorf("Params.Add() stored %#v, want int32(3)", p["jid"])
+	}
+	if err := p.Add("jid", 4); err == nil {
+		t.Errorf("Params.Add() of existing key should fail")
+	}
+	if err := p.Add("allow.raw_socket", true); !errors.Is(err, ErrUnknownParam) {
+		t.Errorf("Params.Add() error = %v, want %v", err, ErrUnknownParam)
+	}
+	if err := p.Add("path", 1); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("Params.Add() error = %v, want %v", err, ErrInvalidValue)
+	}
+	if err := p.Add("host.hostname", nil); err != nil {
+		t.Errorf("Params.Add() of nil value error = %v", err)
+	}
+}
+
+func Test_addParam(t *testing.T) {
+	if _, ok := LookupParam("allow.mount.p9fs"); ok {
+		t.Skip("allow.mount.p9fs already known")
+	}
+	p, err := parseParam("security.jail.param.allow.mount.p9fs", CtlTypeInt|ctlFlagRW, "B", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addParam(p)
+	defer func() {
+		catalog.Lock()
+		delete(catalog.params, p.Name)
+		catalog.Unlock()
+	}()
+
+	if _, ok := LookupParam("allow.mount.nop9fs"); !ok {
+		t.Errorf("LookupParam() of discovered param failed")
+	}
+}
+
+func TestParseJailSys(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    JailSys
+		wantErr bool
+	}{
+		{name: "disable", s: "disable", want: JailSysDisable},
+		{name: "new", s: "new", want: JailSysNew},
+		{name: "inherit", s: "inherit", want: JailSysInherit},
+		{name: "unknown", s: "yes", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseJailSys(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseJailSys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseJailSys() = %v, want %v", got, tt.want)
+			}
+			if err == nil && got.String() != tt.s {
+				t.Errorf("JailSys.String() = %q, want %q", got.String(), tt.s)
+			}
+		})
+	}
+}
+
+func TestJailParam_boolName(t *testing.T) {
+	tests := []struct {
+		name  string
+		param string
+		value bool
+		want  string
+	}{
+		{name: "true", param: "persist", value: true, want: "persist"},
+		{name: "false", param: "persist", value: false, want: "nopersist"},
+		{name: "dotted false", param: "allow.mount", value: false, want: "allow.nomount"},
+		{name: "no prefix true", param: "nopersist", value: true, want: "nopersist"},
+		{name: "no prefix false", param: "allow.nomount", value: false, want: "allow.mount"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, ok := LookupParam(tt.param)
+			if !ok {
+				t.Fatalf("unknown param %s", tt.param)
+			}
+			if got := p.boolName(tt.value); got != tt.want {
+				t.Errorf("JailParam.boolName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package jail
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ProcState is the run state of a process, ki_stat in kinfo_proc.
+type ProcState byte
+
+// Process states from sys/proc.h.
+const (
+	ProcIdle     ProcState = 1
+	ProcRunnable ProcState = 2
+	ProcSleeping ProcState = 3
+	ProcStopped  ProcState = 4
+	ProcZombie   ProcState = 5
+	ProcWaiting  ProcState = 6
+	ProcLocked   ProcState = 7
+)
+
+// String returns the letter ps(1) shows for the state.
+func (s ProcState) String() string {
+	switch s {
+	case ProcIdle:
+		return "I"
+	case ProcRunnable:
+		return "R"
+	case ProcSleeping:
+		return "S"
+	case ProcStopped:
+		return "T"
+	case ProcZombie:
+		return "Z"
+	case ProcWaiting:
+		return "W"
+	case ProcLocked:
+		return "L"
+	}
+
+	return "?"
+}
+
+// Process is a process running in a jail.
+type Process struct {
+	PID  int32
+	PPID int32
+	JID  int32
+	UID  uint32
+
+	// User is the name of the user in the jail's password
+	// database, or the UID if it has none.
+	User string
+
+	// Command is the name of the executable, at most 19 bytes.
+	Command string
+
+	State ProcState
+
+	// RSS is the resident set size in bytes.
+	RSS int64
+
+	Start time.Time
+}
+
+// Processes returns the processes of the jail with the given JID, or
+// those not in a jail for 0, as read from the kern.proc.all sysctl.
+func Processes(jid int32) ([]Process, error) {
+	root := "/"
+	if jid != 0 {
+		params := Params{"jid": jid, "path": ""}
+		if err := Get(params, 0); err != nil {
+			return nil, err
+		}
+		root, _ = params["path"].(string)
+	}
+
+	b, err := readProcs()
+	if err != nil {
+		return nil, err
+	}
+	all, err := parseKinfoProcs(b, os.Getpagesize())
+	if err != nil {
+		return nil, err
+	}
+
+	var procs []Process
+	for _, p := range all {
+		if p.JID == jid {
+			procs = append(procs, p)
+		}
+	}
+	setUsers(root, procs)
+
+	return procs, nil
+}
+
+// setUsers names the users of the processes from the password
+// database of the jail rooted at root. It is read through lookupUID,
+// which resolves the file as it would be in the jail, so a link
+// planted in the jail cannot point the lookup at the host's.
+func setUsers(root string, procs []Process) {
+	users := make(map[uint32]string)
+	for i := range procs {
+		uid := procs[i].UID
+		name, ok := users[uid]
+		if !ok {
+			name = strconv.FormatUint(uint64(uid), 10)
+			if pw, err := lookupUID(root, int(uid)); err == nil {
+				name = pw.name
+			}
+			users[uid] = name
+		}
+		procs[i].User = name
+	}
+}
+
+// Signal sends the signal to every process of the jail with the given
+// JID and returns how many were signalled. Processes that exit before
+// being signalled are not counted.
+func Signal(jid int32, sig syscall.Signal) (int, error) {
+	if jid == 0 {
+		return 0, fmt.Errorf("%w: jid 0", ErrNotFound)
+	}
+
+	procs, err := Processes(jid)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, p := range procs {
+		err := syscall.Kill(int(p.PID), sig)
+		if errors.Is(err, syscall.ESRCH) {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// Offsets of the fields of struct kinfo_proc from sys/user.h, as laid
+// out on amd64 and arm64.
+const (
+	kinfoProcSize = 1088
+
+	kiStructSize = 0
+	kiPID        = 72
+	kiPPID       = 76
+	kiUID        = 168
+	kiRSSize     = 264
+	kiStart      = 336
+	kiStat       = 388
+	kiComm       = 447
+	kiJID        = 592
+
+	commLen = 19
+)
+
+// parseKinfoProcs decodes the array of little-endian struct kinfo_proc
+// returned by the kern.proc sysctls. The RSS, given by the kernel in pages, is
+// converted to bytes with the page size.
+func parseKinfoProcs(b []byte, pageSize int) ([]Process, error) {
+	var procs []Process
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, fmt.Errorf("kinfo_proc: short record of %d bytes", len(b))
+		}
+		size := int(binary.LittleEndian.Uint32(b[kiStructSize:]))
+		if size != kinfoProcSize {
+			return nil, fmt.Errorf("kinfo_proc: unsupported structure size %d", size)
+		}
+		if len(b) < size {
+			return nil, fmt.Errorf("kinfo_proc: short record of %d bytes", len(b))
+		}
+
+		procs = append(procs, parseKinfoProc(b[:size], pageSize))
+		b = b[size:]
+	}
+
+	return procs, nil
+}
+
+// parseKinfoProc decodes a single struct kinfo_proc.
+func parseKinfoProc(b []byte, pageSize int) Process {
+	le := binary.LittleEndian
+
+	comm := b[kiComm : kiComm+commLen+1]
+	for i, c := range comm {
+		if c == 0 {
+			comm = comm[:i]
+			break
+		}
+	}
+
+	return Process{
+		PID:     int32(le.Uint32(b[kiPID:])),
+		PPID:    int32(le.Uint32(b[kiPPID:])),
+		JID:     int32(le.Uint32(b[kiJID:])),
+		UID:     le.Uint32(b[kiUID:]),
+		Command: string(comm),
+		State:   ProcState(b[kiStat]),
+		RSS:     int64(le.Uint64(b[kiRSSize:])) * int64(pageSize),
+		Start:   time.Unix(int64(le.Uint64(b[kiStart:])), int64(le.Uint64(b[kiStart+8:]))*1000),
+	}
+}
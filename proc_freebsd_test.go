@@ -0,0 +1,42 @@
+package jail
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test_readProcs decodes the kern.proc.all of the running system and
+// checks the entry of the test process against what is known of it.
+func Test_readProcs(t *testing.T) {
+	b, err := readProcs()
+	if err != nil {
+		t.Fatalf("readProcs() error = %v", err)
+	}
+	procs, err := parseKinfoProcs(b, os.Getpagesize())
+	if err != nil {
+		t.Fatalf("parseKinfoProcs() error = %v", err)
+	}
+
+	comm := filepath.Base(os.Args[0])
+	if len(comm) > commLen {
+		comm = comm[:commLen]
+	}
+	for _, p := range procs {
+		if p.PID != int32(os.Getpid()) {
+			continue
+		}
+		if p.PPID != int32(os.Getppid()) || p.UID != uint32(os.Getuid()) || p.Command != comm {
+			t.Errorf("process = %+v, want ppid %d, uid %d and command %q", p, os.Getppid(), os.Getuid(), comm)
+		}
+		if p.RSS <= 0 {
+			t.Errorf("process RSS = %d", p.RSS)
+		}
+		if p.Start.After(time.Now()) || time.Since(p.Start) > time.Hour {
+			t.Errorf("process start = %v", p.Start)
+		}
+		return
+	}
+	t.Errorf("process %d not found in %d processes", os.Getpid(), len(procs))
+}
@@ -0,0 +1,189 @@
+package jail
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// kinfoProc returns a struct kinfo_proc as the amd64 kernel lays it
+// out, the offsets taken from sys/user.h.
+func kinfoProc(pid, ppid, jid int32, uid uint32, rss uint64, sec, usec int64, stat byte, comm string) []byte {
+	le := binary.LittleEndian
+	b := make([]byte, 1088)
+	le.PutUint32(b[0:], 1088)           // ki_structsize
+	le.PutUint32(b[72:], uint32(pid))   // ki_pid
+	le.PutUint32(b[76:], uint32(ppid))  // ki_ppid
+	le.PutUint32(b[168:], uid)          // ki_uid
+	le.PutUint64(b[264:], rss)          // ki_rssize
+	le.PutUint64(b[336:], uint64(sec))  // ki_start.tv_sec
+	le.PutUint64(b[344:], uint64(usec)) // ki_start.tv_usec
+	b[388] = stat                       // ki_stat
+	copy(b[447:467], comm)              // ki_comm
+	le.PutUint32(b[592:], uint32(jid))  // ki_jid
+
+	return b
+}
+
+func Test_parseKinfoProc(t *testing.T) {
+	b := append(
+		kinfoProc(4242, 1, 7, 80, 300, 1760000000, 250000, 3, "nginx"),
+		kinfoProc(99, 4242, 0, 1001, 0, 1, 999999, 5, "a-command-of-19-chr")...,
+	)
+	want := []Process{
+		{
+			PID:     4242,
+			PPID:    1,
+			JID:     7,
+			UID:     80,
+			Command: "nginx",
+			State:   ProcSleeping,
+			RSS:     300 * 4096,
+			Start:   time.Unix(1760000000, 250000000),
+		},
+		{
+			PID:     99,
+			PPID:    4242,
+			JID:     0,
+			UID:     1001,
+			Command: "a-command-of-19-chr",
+			State:   ProcZombie,
+			RSS:     0,
+			Start:   time.Unix(1, 999999000),
+		},
+	}
+
+	got, err := parseKinfoProcs(b, 4096)
+	if err != nil {
+		t.Fatalf("parseKinfoProcs() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseKinfoProcs() = %+v, want %+v", got, want)
+	}
+}
+
+func Test_parseKinfoProcs(t *testing.T) {
+	// ki_structsize is the first field, 1088 bytes on amd64.
+	short := make([]byte, 100)
+	copy(short, []byte{0x40, 0x04, 0x00, 0x00})
+	wrongSize := make([]byte, 1096)
+	copy(wrongSize, []byte{0x48, 0x04, 0x00, 0x00})
+
+	tests := []struct {
+		name    string
+		b       []byte
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			b:    nil,
+		},
+		{
+			name:    "truncated size",
+			b:       []byte{0x40, 0x04},
+			wantErr: true,
+		},
+		{
+			name:    "truncated record",
+			b:       short,
+			wantErr: true,
+		},
+		{
+			name:    "unsupported size",
+			b:       wrongSize,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseKinfoProcs(tt.b, 4096)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseKinfoProcs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("parseKinfoProcs() returned %d processes, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func Test_setUsers(t *testing.T) {
+	root := newJailRoot(t)
+
+	// A jail whose /etc/passwd links to a file on the host.
+	escaped := t.TempDir()
+	host := filepath.Join(t.TempDir(), "passwd")
+	if err := os.WriteFile(host, []byte("evil:*:1001:1001::/:/bin/sh\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(escaped, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(host, filepath.Join(escaped, "etc", "passwd")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		root string
+		uids []uint32
+		want []string
+	}{
+		{
+			name: "jail users",
+			root: root,
+			uids: []uint32{0, 80, 1001, 80},
+			want: []string{"root", "www", "app", "www"},
+		},
+		{
+			name: "unknown user",
+			root: root,
+			uids: []uint32{4242},
+			want: []string{"4242"},
+		},
+		{
+			name: "password database out of the jail",
+			root: escaped,
+			uids: []uint32{1001},
+			want: []string{"1001"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			procs := make([]Process, len(tt.uids))
+			for i, uid := range tt.uids {
+				procs[i].UID = uid
+			}
+			setUsers(tt.root, procs)
+			for i, p := range procs {
+				if p.User != tt.want[i] {
+					t.Errorf("setUsers() user of uid %d = %q, want %q", p.UID, p.User, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestProcState_String(t *testing.T) {
+	tests := []struct {
+		name string
+		s    ProcState
+		want string
+	}{
+		{name: "runnable", s: ProcRunnable, want: "R"},
+		{name: "sleeping", s: ProcSleeping, want: "S"},
+		{name: "zombie", s: ProcZombie, want: "Z"},
+		{name: "unknown", s: 0, want: "?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.s.String(); got != tt.want {
+				t.Errorf("ProcState.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package rctl
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	sysRctlGetRacct   = 525
+	sysRctlGetRules   = 526
+	sysRctlAddRule    = 528
+	sysRctlRemoveRule = 529
+)
+
+// errno values from FreeBSD's errno.h.
+const (
+	eperm  = unix.Errno(1)
+	esrch  = unix.Errno(3)
+	einval = unix.Errno(22)
+	erange = unix.Errno(34)
+	enosys = unix.Errno(78)
+)
+
+var (
+	// ErrNotEnabled [ENOSYS] Resource accounting is not enabled, it
+	// is turned on by setting kern.racct.enable=1 in loader.conf(5).
+	ErrNotEnabled = errors.New("resource accounting is not enabled")
+
+	// ErrPermission [EPERM] The process is not the super-user or is
+	// in a jail.
+	ErrPermission = errors.New("not allowed or restricted")
+
+	// ErrNotFound [ESRCH] No rule matched the filter, or the subject
+	// does not exist.
+	ErrNotFound = errors.New("no matching rule or subject")
+)
+
+// errnoErrors maps the errno values the calls fail with to the error
+// describing them.
+var errnoErrors = map[unix.Errno]error{
+	eperm:  ErrPermission,
+	esrch:  ErrNotFound,
+	einval: ErrInvalidRule,
+	enosys: ErrNotEnabled,
+}
+
+// syscallNames maps the rctl system calls to their names.
+var syscallNames = map[int]string{
+	sysRctlGetRacct:   "rctl_get_racct",
+	sysRctlGetRules:   "rctl_get_rules",
+	sysRctlAddRule:    "rctl_add_rule",
+	sysRctlRemoveRule: "rctl_remove_rule",
+}
+
+// Error records a failed rctl system call along with the rule or
+// filter it was made with.
+type Error struct {
+	Syscall string
+	Rule    string
+	Errno   unix.Errno
+
+	// Err is the sentinel error the errno maps to, or nil if it is
+	// not one the call is documented to return.
+	Err error
+}
+
+// newError converts the error returned from the given syscall in to an
+// *Error. Errors other than a unix.Errno are returned unchanged.
+func newError(call int, rule string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var e unix.Errno
+	if !errors.As(err, &e) {
+		return err
+	}
+
+	return &Error{Syscall: syscallNames[call], Rule: rule, Errno: e, Err: errnoErrors[e]}
+}
+
+// Error returns the call, the rule and a description of the errno.
+func (e *Error) Error() string {
+	msg := e.Errno.Error()
+	if e.Err != nil {
+		msg = e.Err.Error()
+	}
+
+	return e.Syscall + " " + e.Rule + ": " + msg
+}
+
+// Unwrap returns the errno and the sentinel error so errors.Is can be
+// used to match either.
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Errno}
+	}
+
+	return []error{e.Errno, e.Err}
+}
+
+// AddRule adds the rule. A rule for the same subject, resource and
+// action replaces the one already in place.
+func AddRule(r Rule) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	_, err := call(sysRctlAddRule, r.String())
+
+	return err
+}
+
+// Rules returns the rules matching the filter, e.g.
+// Rule{Subject: SubjectJail, SubjectID: "web"} for those of the web
+// jail.
+func Rules(filter Rule) ([]Rule, error) {
+	out, err := call(sysRctlGetRules, filter.filter())
+	if err != nil {
+		return nil, err
+	}
+
+	return parseRules(out)
+}
+
+// RemoveRule removes the rules matching the filter. An error
+// wrapping ErrNotFound is returned if there were none.
+func RemoveRule(filter Rule) error {
+	_, err := call(sysRctlRemoveRule, filter.filter())
+
+	return err
+}
+
+// JailRules returns the rules of the named jail.
+func JailRules(name string) ([]Rule, error) {
+	return Rules(Rule{Subject: SubjectJail, SubjectID: name})
+}
+
+// RemoveJailRules removes every rule of the named jail.
+func RemoveJailRules(name string) error {
+	err := RemoveRule(Rule{Subject: SubjectJail, SubjectID: name})
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+// GetUsage returns the resource use of the subject given by the
+// filter, which must name a single subject.
+func GetUsage(filter Rule) (Usage, error) {
+	out, err := call(sysRctlGetRacct, filter.filter())
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseUsage(out)
+}
+
+// JailUsage returns the resource use of the named jail, such as its
+// memoryuse, pcpu, maxproc and openfiles.
+func JailUsage(name string) (Usage, error) {
+	return GetUsage(Rule{Subject: SubjectJail, SubjectID: name})
+}
+
+// outputLen is the size of the buffer the output of a call is first
+// retrieved in to. It is doubled until the output fits.
+const outputLen = 4096
+
+// maxOutputLen is the largest buffer an output is retrieved in to.
+const maxOutputLen = 16 << 20
+
+// call makes the system call with the rule, growing the output buffer
+// while the kernel reports it is too small, and returns the output.
+func call(num int, rule string) (string, error) {
+	in := append([]byte(rule), 0)
+	for n := outputLen; ; n *= 2 {
+		out := make([]byte, n)
+		err := syscall4(num, in, out)
+		if errors.Is(err, erange) && n < maxOutputLen {
+			continue
+		}
+		if err != nil {
+			return "", newError(num, rule, err)
+		}
+		if i := strings.IndexByte(string(out), 0); i >= 0 {
+			out = out[:i]
+		}
+		return string(out), nil
+	}
+}
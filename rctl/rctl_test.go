@@ -0,0 +1,57 @@
+package rctl
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func Test_newError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantIs  error
+		wantMsg string
+	}{
+		{
+			name:    "not enabled",
+			err:     enosys,
+			wantIs:  ErrNotEnabled,
+			wantMsg: "rctl_add_rule jail:web:memoryuse:deny=1: resource accounting is not enabled",
+		},
+		{
+			name:    "no rule",
+			err:     esrch,
+			wantIs:  ErrNotFound,
+			wantMsg: "rctl_add_rule jail:web:memoryuse:deny=1: no matching rule or subject",
+		},
+		{
+			name:    "undocumented",
+			err:     unix.Errno(5),
+			wantIs:  unix.Errno(5),
+			wantMsg: "rctl_add_rule jail:web:memoryuse:deny=1: " + unix.Errno(5).Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newError(sysRctlAddRule, "jail:web:memoryuse:deny=1", tt.err)
+			if !errors.Is(err, tt.wantIs) {
+				t.Errorf("newError() = %v, want %v", err, tt.wantIs)
+			}
+			if err.Error() != tt.wantMsg {
+				t.Errorf("newError() = %q, want %q", err, tt.wantMsg)
+			}
+		})
+	}
+
+	if err := newError(sysRctlAddRule, "", nil); err != nil {
+		t.Errorf("newError() = %v, want nil", err)
+	}
+}
+
+func TestAddRule(t *testing.T) {
+	if err := AddRule(JailRule("web", MemoryUse, "refuse", 1)); !errors.Is(err, ErrInvalidRule) {
+		t.Errorf("AddRule() error = %v, want %v", err, ErrInvalidRule)
+	}
+}
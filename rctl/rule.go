@@ -0,0 +1,355 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+// Package rctl manages the resource limits of jails through the
+// rctl(4) rules of the FreeBSD kernel, the library equivalent of
+// rctl(8). Rules take the form
+//
+//	subject:subject-id:resource:action=amount/per
+//
+// such as jail:web:memoryuse:deny=1g. The kernel only enforces rules,
+// and keeps usage, when kern.racct.enable is set at boot.
+package rctl
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Subject is the kind of object a rule applies to.
+type Subject string
+
+// Subjects supported by the kernel.
+const (
+	SubjectProcess    Subject = "process"
+	SubjectUser       Subject = "user"
+	SubjectLoginClass Subject = "loginclass"
+	SubjectJail       Subject = "jail"
+)
+
+// subjectAliases maps the abbreviations rctl(8) accepts to their
+// subject.
+var subjectAliases = map[string]Subject{
+	"p": SubjectProcess,
+	"u": SubjectUser,
+	"l": SubjectLoginClass,
+	"c": SubjectLoginClass,
+	"j": SubjectJail,
+}
+
+// parseSubject returns the subject of the given name or abbreviation.
+func parseSubject(s string) (Subject, error) {
+	switch sub := Subject(s); sub {
+	case SubjectProcess, SubjectUser, SubjectLoginClass, SubjectJail:
+		return sub, nil
+	}
+	if sub, ok := subjectAliases[s]; ok {
+		return sub, nil
+	}
+
+	return "", fmt.Errorf("%w: unknown subject %q", ErrInvalidRule, s)
+}
+
+// Resource is a resource whose use is accounted and can be limited.
+type Resource string
+
+// Resources as described in rctl(8).
+const (
+	CPUTime         Resource = "cputime"
+	DataSize        Resource = "datasize"
+	StackSize       Resource = "stacksize"
+	CoreDumpSize    Resource = "coredumpsize"
+	MemoryUse       Resource = "memoryuse"
+	MemoryLocked    Resource = "memorylocked"
+	MaxProc         Resource = "maxproc"
+	OpenFiles       Resource = "openfiles"
+	VMemoryUse      Resource = "vmemoryuse"
+	PseudoTerminals Resource = "pseudoterminals"
+	SwapUse         Resource = "swapuse"
+	NThr            Resource = "nthr"
+	MsgqQueued      Resource = "msgqqueued"
+	MsgqSize        Resource = "msgqsize"
+	NMsgq           Resource = "nmsgq"
+	NSem            Resource = "nsem"
+	NSemop          Resource = "nsemop"
+	NShm            Resource = "nshm"
+	ShmSize         Resource = "shmsize"
+	WallClock       Resource = "wallclock"
+	PCPU            Resource = "pcpu"
+	ReadBPS         Resource = "readbps"
+	WriteBPS        Resource = "writebps"
+	ReadIOPS        Resource = "readiops"
+	WriteIOPS       Resource = "writeiops"
+)
+
+// resources holds every known resource.
+var resources = map[Resource]bool{
+	CPUTime: true, DataSize: true, StackSize: true, CoreDumpSize: true,
+	MemoryUse: true, MemoryLocked: true, MaxProc: true, OpenFiles: true,
+	VMemoryUse: true, PseudoTerminals: true, SwapUse: true, NThr: true,
+	MsgqQueued: true, MsgqSize: true, NMsgq: true, NSem: true,
+	NSemop: true, NShm: true, ShmSize: true, WallClock: true,
+	PCPU: true, ReadBPS: true, WriteBPS: true, ReadIOPS: true,
+	WriteIOPS: true,
+}
+
+// Action is what is done when a rule's amount is exceeded. Besides
+// the actions below a signal can be sent by giving its name, such as
+// sigterm.
+type Action string
+
+// Actions as described in rctl(8).
+const (
+	Deny     Action = "deny"
+	Log      Action = "log"
+	Devctl   Action = "devctl"
+	Throttle Action = "throttle"
+	SigTerm  Action = "sigterm"
+	SigKill  Action = "sigkill"
+)
+
+// validAction reports whether the action is known or names a signal.
+func validAction(a Action) bool {
+	switch a {
+	case Deny, Log, Devctl, Throttle:
+		return true
+	}
+	name, ok := strings.CutPrefix(string(a), "sig")
+	if !ok || name == "" {
+		return false
+	}
+	for _, c := range name {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ErrInvalidRule is returned for rules that cannot be parsed and
+// reported by the kernel, as EINVAL, for rules it does not accept.
+var ErrInvalidRule = errors.New("invalid rule")
+
+// Rule is an rctl rule. A rule with trailing fields left empty, such
+// as jail:web, is a filter matching every rule it is a prefix of and
+// is used with Rules, RemoveRule and Usage.
+type Rule struct {
+	Subject   Subject
+	SubjectID string
+	Resource  Resource
+	Action    Action
+	Amount    int64
+
+	// Per is the subject the amount is accounted for, when it is
+	// not the subject of the rule, e.g. a limit applying to each
+	// process of a jail.
+	Per Subject
+}
+
+// JailRule returns the rule limiting the resource of the named jail.
+func JailRule(name string, res Resource, action Action, amount int64) Rule {
+	return Rule{Subject: SubjectJail, SubjectID: name, Resource: res, Action: action, Amount: amount}
+}
+
+// ParseRule parses a rule, or a filter, in the syntax of rctl(8).
+// Amounts can be given with a k, m, g, t, p or e suffix for powers of
+// 1024 and subjects abbreviated to their first letter.
+func ParseRule(s string) (Rule, error) {
+	var r Rule
+
+	rest, per, hasPer := strings.Cut(s, "/")
+	fields := strings.SplitN(rest, ":", 4)
+	if fields[0] == "" {
+		return Rule{}, fmt.Errorf("%w: %q: missing subject", ErrInvalidRule, s)
+	}
+
+	var err error
+	if r.Subject, err = parseSubject(fields[0]); err != nil {
+		return Rule{}, err
+	}
+	if len(fields) > 1 {
+		r.SubjectID = fields[1]
+	}
+	if len(fields) > 2 && fields[2] != "" {
+		r.Resource = Resource(fields[2])
+		if !resources[r.Resource] {
+			return Rule{}, fmt.Errorf("%w: %q: unknown resource %s", ErrInvalidRule, s, r.Resource)
+		}
+	}
+	if len(fields) > 3 && fields[3] != "" {
+		action, amount, hasAmount := strings.Cut(fields[3], "=")
+		r.Action = Action(action)
+		if !validAction(r.Action) {
+			return Rule{}, fmt.Errorf("%w: %q: unknown action %s", ErrInvalidRule, s, action)
+		}
+		if hasAmount {
+			if r.Amount, err = ParseAmount(amount); err != nil {
+				return Rule{}, fmt.Errorf("%w: %q: %w", ErrInvalidRule, s, err)
+			}
+		}
+	}
+	if hasPer {
+		if r.Per, err = parseSubject(per); err != nil {
+			return Rule{}, err
+		}
+	}
+
+	return r, nil
+}
+
+// String returns the rule in the syntax of rctl(8). The trailing
+// empty fields of a filter are left out.
+func (r Rule) String() string {
+	parts := []string{string(r.Subject), r.SubjectID, string(r.Resource), string(r.Action)}
+	n := len(parts)
+	for n > 1 && parts[n-1] == "" {
+		n--
+	}
+
+	s := strings.Join(parts[:n], ":")
+	if r.Action != "" {
+		s += "=" + strconv.FormatInt(r.Amount, 10)
+		if r.Per != "" {
+			s += "/" + string(r.Per)
+		}
+	}
+
+	return s
+}
+
+// filter returns the rule as a filter. A filter of only the subject
+// keeps its colon, matching every subject of that kind.
+func (r Rule) filter() string {
+	s := r.String()
+	if s == string(r.Subject) {
+		s += ":"
+	}
+
+	return s
+}
+
+// Validate checks the rule is complete and uses known values.
+func (r Rule) Validate() error {
+	switch {
+	case r.Subject == "":
+		return fmt.Errorf("%w: missing subject", ErrInvalidRule)
+	case r.SubjectID == "":
+		return fmt.Errorf("%w: missing subject id", ErrInvalidRule)
+	case r.Resource == "":
+		return fmt.Errorf("%w: missing resource", ErrInvalidRule)
+	case r.Action == "":
+		return fmt.Errorf("%w: missing action", ErrInvalidRule)
+	case r.Amount < 0:
+		return fmt.Errorf("%w: negative amount", ErrInvalidRule)
+	}
+	if _, err := parseSubject(string(r.Subject)); err != nil {
+		return err
+	}
+	if !resources[r.Resource] {
+		return fmt.Errorf("%w: unknown resource %s", ErrInvalidRule, r.Resource)
+	}
+	if !validAction(r.Action) {
+		return fmt.Errorf("%w: unknown action %s", ErrInvalidRule, r.Action)
+	}
+	if r.Per != "" {
+		if _, err := parseSubject(string(r.Per)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// amountSuffixes are the multipliers of the suffixes an amount can be
+// given with, as per expand_number(3).
+var amountSuffixes = map[byte]uint{'k': 10, 'm': 20, 'g': 30, 't': 40, 'p': 50, 'e': 60}
+
+// ParseAmount parses an amount with an optional k, m, g, t, p or e
+// suffix, in either case, for powers of 1024.
+func ParseAmount(s string) (int64, error) {
+	var shift uint
+	if n := len(s); n > 0 {
+		if sh, ok := amountSuffixes[s[n-1]|0x20]; ok {
+			shift, s = sh, s[:n-1]
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if shift > 0 && n > (1<<63-1)>>shift {
+		return 0, fmt.Errorf("amount %q out of range", s)
+	}
+
+	return n << shift, nil
+}
+
+// parseRules parses the comma separated rules returned by the kernel.
+func parseRules(s string) ([]Rule, error) {
+	var rules []Rule
+	for _, f := range strings.Split(s, ",") {
+		if f == "" {
+			continue
+		}
+		r, err := ParseRule(f)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, nil
+}
+
+// Usage is the use of each resource accounted for a subject.
+type Usage map[Resource]int64
+
+// ParseUsage parses the comma separated resource=amount pairs
+// returned by the kernel for rctl_get_racct(2).
+func ParseUsage(s string) (Usage, error) {
+	u := make(Usage)
+	for _, f := range strings.Split(s, ",") {
+		if f == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(f, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid usage %q", f)
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid usage %q", f)
+		}
+		u[Resource(k)] = n
+	}
+
+	return u, nil
+}
@@ -0,0 +1,236 @@
+package rctl
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Rule
+		wantStr string
+		wantErr bool
+	}{
+		{
+			name:    "jail memory",
+			s:       "jail:web:memoryuse:deny=1g",
+			want:    Rule{Subject: SubjectJail, SubjectID: "web", Resource: MemoryUse, Action: Deny, Amount: 1 << 30},
+			wantStr: "jail:web:memoryuse:deny=1073741824",
+		},
+		{
+			name:    "per process",
+			s:       "jail:web:maxproc:sigterm=100/process",
+			want:    Rule{Subject: SubjectJail, SubjectID: "web", Resource: MaxProc, Action: SigTerm, Amount: 100, Per: SubjectProcess},
+			wantStr: "jail:web:maxproc:sigterm=100/process",
+		},
+		{
+			name:    "abbreviated",
+			s:       "j:db:pcpu:throttle=50/p",
+			want:    Rule{Subject: SubjectJail, SubjectID: "db", Resource: PCPU, Action: Throttle, Amount: 50, Per: SubjectProcess},
+			wantStr: "jail:db:pcpu:throttle=50/process",
+		},
+		{
+			name:    "filter",
+			s:       "jail:web",
+			want:    Rule{Subject: SubjectJail, SubjectID: "web"},
+			wantStr: "jail:web",
+		},
+		{
+			name:    "filter by resource",
+			s:       "jail::openfiles",
+			want:    Rule{Subject: SubjectJail, Resource: OpenFiles},
+			wantStr: "jail::openfiles",
+		},
+		{
+			name:    "subject only",
+			s:       "user:",
+			want:    Rule{Subject: SubjectUser},
+			wantStr: "user",
+		},
+		{
+			name:    "unknown subject",
+			s:       "host:web:memoryuse:deny=1",
+			wantErr: true,
+		},
+		{
+			name:    "unknown resource",
+			s:       "jail:web:memory:deny=1",
+			wantErr: true,
+		},
+		{
+			name:    "unknown action",
+			s:       "jail:web:memoryuse:refuse=1",
+			wantErr: true,
+		},
+		{
+			name:    "bad amount",
+			s:       "jail:web:memoryuse:deny=lots",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			s:       "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRule(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrInvalidRule) {
+					t.Errorf("ParseRule() error = %v, want %v", err, ErrInvalidRule)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseRule() = %+v, want %+v", got, tt.want)
+			}
+			if s := got.String(); s != tt.wantStr {
+				t.Errorf("Rule.String() = %q, want %q", s, tt.wantStr)
+			}
+		})
+	}
+}
+
+func TestRule_filter(t *testing.T) {
+	tests := []struct {
+		rule Rule
+		want string
+	}{
+		{Rule{Subject: SubjectJail}, "jail:"},
+		{Rule{Subject: SubjectJail, SubjectID: "web"}, "jail:web"},
+		{JailRule("web", MemoryUse, Deny, 1024), "jail:web:memoryuse:deny=1024"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.rule.filter(); got != tt.want {
+				t.Errorf("Rule.filter() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRule_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		wantErr bool
+	}{
+		{"complete", JailRule("web", OpenFiles, Log, 1000), false},
+		{"signal", JailRule("web", CPUTime, "sigxcpu", 3600), false},
+		{"missing id", Rule{Subject: SubjectJail, Resource: MemoryUse, Action: Deny}, true},
+		{"missing action", Rule{Subject: SubjectJail, SubjectID: "web", Resource: MemoryUse}, true},
+		{"unknown resource", JailRule("web", "disk", Deny, 1), true},
+		{"unknown action", JailRule("web", MemoryUse, "sig", 1), true},
+		{"negative amount", JailRule("web", MemoryUse, Deny, -1), true},
+		{"unknown per", Rule{Subject: SubjectJail, SubjectID: "web", Resource: MemoryUse, Action: Deny, Per: "host"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Rule.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidRule) {
+				t.Errorf("Rule.Validate() error = %v, want %v", err, ErrInvalidRule)
+			}
+		})
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    int64
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"100", 100, false},
+		{"512k", 512 << 10, false},
+		{"2M", 2 << 20, false},
+		{"1g", 1 << 30, false},
+		{"3t", 3 << 40, false},
+		{"7e", 7 << 60, false},
+		{"8e", 0, true},
+		{"-1", 0, true},
+		{"1.5g", 0, true},
+		{"g", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseAmount(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAmount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseAmount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseRules(t *testing.T) {
+	got, err := parseRules("jail:web:memoryuse:deny=1073741824,jail:web:maxproc:deny=100,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Rule{
+		JailRule("web", MemoryUse, Deny, 1<<30),
+		JailRule("web", MaxProc, Deny, 100),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRules() = %+v, want %+v", got, want)
+	}
+
+	if got, err := parseRules(""); err != nil || got != nil {
+		t.Errorf("parseRules() = %+v, %v, want no rules", got, err)
+	}
+}
+
+func TestParseUsage(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Usage
+		wantErr bool
+	}{
+		{
+			name: "jail",
+			s:    "cputime=12,datasize=4096,memoryuse=52428800,maxproc=7,openfiles=112,pcpu=3,",
+			want: Usage{CPUTime: 12, DataSize: 4096, MemoryUse: 50 << 20, MaxProc: 7, OpenFiles: 112, PCPU: 3},
+		},
+		{
+			name: "empty",
+			s:    "",
+			want: Usage{},
+		},
+		{
+			name:    "missing value",
+			s:       "cputime",
+			wantErr: true,
+		},
+		{
+			name:    "bad value",
+			s:       "cputime=x",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseUsage(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseUsage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseUsage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package jail
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// runEnv is the environment variable a child process started by
+// RunInJail finds its jail and function in.
+const runEnv = "_JAIL_RUN"
+
+var (
+	runMu    sync.RWMutex
+	runFuncs = make(map[string]func() error)
+)
+
+// Register registers the function to be run in jails by RunInJail.
+// It must be a top-level function, as the values captured by a
+// closure cannot be carried over to the child process, and should be
+// registered from an init function so the child finds it.
+func Register(fn func() error) {
+	name := funcName(fn)
+	if name == "" || isClosure(name) {
+		panic("jail: Register requires a top-level function, got " + name)
+	}
+
+	runMu.Lock()
+	defer runMu.Unlock()
+
+	runFuncs[name] = fn
+}
+
+// funcName returns the name of the function.
+func funcName(fn func() error) string {
+	if fn == nil {
+		return ""
+	}
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return ""
+	}
+
+	return f.Name()
+}
+
+// isClosure reports whether the function name is that of a closure
+// or method value, e.g. main.main.func1 or main.T.Run-fm.
+func isClosure(name string) bool {
+	if strings.HasSuffix(name, "-fm") {
+		return true
+	}
+	last := name[strings.LastIndexByte(name, '.')+1:]
+	if _, err := strconv.Atoi(last); err == nil {
+		return true
+	}
+	n, ok := strings.CutPrefix(last, "func")
+	if !ok {
+		return false
+	}
+	_, err := strconv.Atoi(n)
+
+	return err == nil
+}
+
+// RunInJail runs the function in the jail and returns its error.
+//
+// Attaching to a jail with Attach, Jail or the AttachFlag affects the
+// whole process, every goroutine and thread, and cannot be undone.
+// RunInJail instead starts a child from the current executable which
+// attaches itself to the jail, runs the function and exits, leaving
+// the calling process where it is. The function must have been
+// registered with Register and the program must call Init at the
+// start of main.
+func RunInJail(jid int32, fn func() error) error {
+	name := funcName(fn)
+
+	runMu.RLock()
+	_, ok := runFuncs[name]
+	runMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("jail: function %s is not registered", name)
+	}
+
+	if _, err := Name(jid); err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	cmd := exec.Command(exe)
+	cmd.Args = os.Args[:1]
+	cmd.Env = append(os.Environ(), runEnv+"="+strconv.Itoa(int(jid))+":"+name)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = []*os.File{w}
+
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		return err
+	}
+
+	msg, _ := io.ReadAll(r)
+	err = cmd.Wait()
+	if len(msg) > 0 {
+		return fmt.Errorf("%s in jail %d: %s", name, jid, msg)
+	}
+
+	return err
+}
+
+// Init runs the function of a child process started by RunInJail and
+// exits. In any other process it returns immediately. It must be
+// called at the start of main, or TestMain, before the program does
+// anything it would not want to do twice.
+func Init() {
+	v, ok := os.LookupEnv(runEnv)
+	if !ok {
+		return
+	}
+
+	os.Exit(runChild(v, os.NewFile(3, "jail-run")))
+}
+
+// runChild attaches to the jail and runs the function given by the
+// value of runEnv, writing an error to errf. It returns the status the
+// child exits with.
+func runChild(v string, errf io.Writer) int {
+	os.Unsetenv(runEnv)
+
+	fail := func(err error) int {
+		fmt.Fprint(errf, err)
+		return 1
+	}
+
+	id, name, _ := strings.Cut(v, ":")
+	jid, err := strconv.ParseInt(id, 10, 32)
+	if err != nil {
+		return fail(fmt.Errorf("invalid %s: %q", runEnv, v))
+	}
+
+	runMu.RLock()
+	fn, ok := runFuncs[name]
+	runMu.RUnlock()
+	if !ok {
+		return fail(fmt.Errorf("function %s is not registered", name))
+	}
+
+	if err := Attach(int32(jid)); err != nil {
+		return fail(err)
+	}
+	if err := fn(); err != nil {
+		return fail(err)
+	}
+
+	return 0
+}
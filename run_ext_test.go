@@ -0,0 +1,117 @@
+package jail_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/briandowns/jail"
+	"github.com/briandowns/jail/jailtest"
+)
+
+// childBackend is the backend of a child process started by
+// RunInJail in TestRunInJail.
+var childBackend *jailtest.Backend
+
+func TestMain(m *testing.M) {
+	if os.Getenv("JAILTEST_CHILD") != "" {
+		childBackend = jailtest.New()
+		childBackend.Set(jail.Params{"name": "web", "persist": true}, jail.CreateFlag)
+		jail.SetBackend(childBackend)
+	}
+	jail.Init()
+
+	os.Exit(m.Run())
+}
+
+func init() {
+	jail.Register(attached)
+	jail.Register(failing)
+}
+
+func attached() error {
+	if jid := childBackend.Attached(); jid != 1 {
+		return fmt.Errorf("attached to %d, want 1", jid)
+	}
+
+	return nil
+}
+
+func failing() error {
+	return errors.New("boom")
+}
+
+func TestRunInJail(t *testing.T) {
+	b := jailtest.New()
+	defer jail.SetBackend(jail.SetBackend(b))
+	t.Setenv("JAILTEST_CHILD", "1")
+
+	if _, err := b.Set(jail.Params{"name": "web", "persist": true}, jail.CreateFlag); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		jid     int32
+		fn      func() error
+		wantErr string
+		wantIs  error
+	}{
+		{
+			name: "attached",
+			jid:  1,
+			fn:   attached,
+		},
+		{
+			name:    "error",
+			jid:     1,
+			fn:      failing,
+			wantErr: "jail_test.failing in jail 1: boom",
+		},
+		{
+			name:   "no jail",
+			jid:    2,
+			fn:     attached,
+			wantIs: jail.ErrNotFound,
+		},
+		{
+			name:    "not registered",
+			jid:     1,
+			fn:      func() error { return nil },
+			wantErr: "jail: function github.com/briandowns/jail_test.TestRunInJail.func1 is not registered",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := jail.RunInJail(tt.jid, tt.fn)
+			switch {
+			case tt.wantIs != nil:
+				if !errors.Is(err, tt.wantIs) {
+					t.Errorf("RunInJail() error = %v, want %v", err, tt.wantIs)
+				}
+			case tt.wantErr != "":
+				if err == nil || !strings.HasSuffix(err.Error(), tt.wantErr) {
+					t.Errorf("RunInJail() error = %v, want %q", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Errorf("RunInJail() error = %v", err)
+			}
+		})
+	}
+
+	if b.Attached() != 0 {
+		t.Errorf("Attached() = %d, the calling process should not be attached", b.Attached())
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Register() of a closure should panic")
+		}
+	}()
+
+	jail.Register(func() error { return nil })
+}
@@ -0,0 +1,25 @@
+package jail
+
+import "testing"
+
+func Test_isClosure(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"main.serve", false},
+		{"github.com/briandowns/jail.Remove", false},
+		{"main.(*Server).Run", false},
+		{"main.main.func1", true},
+		{"main.main.func1.2", true},
+		{"main.(*Server).Run-fm", true},
+		{"main.F[go.shape.int]", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isClosure(tt.name); got != tt.want {
+				t.Errorf("isClosure() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
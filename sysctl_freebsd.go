@@ -0,0 +1,171 @@
+//go:build freebsd
+
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package jail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// The sysctl.* nodes used to walk the MIB, from sys/sysctl.h.
+const (
+	ctlSysctl         = 0
+	ctlSysctlName     = 1
+	ctlSysctlNext     = 2
+	ctlSysctlName2OID = 3
+	ctlSysctlOIDFmt   = 4
+)
+
+// sysctl performs __sysctl(2) for the given MIB, reading the value in
+// to old and writing new. It returns the number of bytes read.
+func sysctl(mib []int32, old, new []byte) (int, error) {
+	var oldp, newp unsafe.Pointer
+	if len(old) > 0 {
+		oldp = unsafe.Pointer(&old[0])
+	}
+	if len(new) > 0 {
+		newp = unsafe.Pointer(&new[0])
+	}
+
+	n := uintptr(len(old))
+	_, _, e1 := unix.Syscall6(unix.SYS___SYSCTL,
+		uintptr(unsafe.Pointer(&mib[0])), uintptr(len(mib)),
+		uintptr(oldp), uintptr(unsafe.Pointer(&n)),
+		uintptr(newp), uintptr(len(new)))
+	if e1 != 0 {
+		return 0, e1
+	}
+
+	return int(n), nil
+}
+
+// sysctlOID returns the MIB of the named sysctl node.
+func sysctlOID(name string) ([]int32, error) {
+	buf := make([]byte, unix.CTL_MAXNAME*4)
+	n, err := sysctl([]int32{ctlSysctl, ctlSysctlName2OID}, buf, []byte(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return bytesToOID(buf[:n]), nil
+}
+
+// sysctlNext returns the MIB of the node following the given one.
+func sysctlNext(oid []int32) ([]int32, error) {
+	buf := make([]byte, unix.CTL_MAXNAME*4)
+	n, err := sysctl(append([]int32{ctlSysctl, ctlSysctlNext}, oid...), buf, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytesToOID(buf[:n]), nil
+}
+
+// sysctlQuery performs the given sysctl.* query for the node
+// and returns the result.
+func sysctlQuery(query int32, oid []int32) ([]byte, error) {
+	buf := make([]byte, 1024)
+	n, err := sysctl(append([]int32{ctlSysctl, query}, oid...), buf, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf[:n], nil
+}
+
+// bytesToOID converts the bytes returned by the kernel in to a MIB.
+func bytesToOID(b []byte) []int32 {
+	oid := make([]int32, len(b)/4)
+	for i := range oid {
+		oid[i] = int32(binary.NativeEndian.Uint32(b[4*i:]))
+	}
+
+	return oid
+}
+
+// sysctlParams walks the security.jail.param sysctl tree, as
+// jailparam_all(3) does, and describes each parameter found.
+func sysctlParams() ([]JailParam, error) {
+	prefix, err := sysctlOID(strings.TrimSuffix(paramSysctlPrefix, "."))
+	if err != nil {
+		return nil, err
+	}
+
+	var params []JailParam
+	oid := prefix
+	for {
+		if oid, err = sysctlNext(oid); err != nil {
+			if err == unix.ENOENT {
+				break
+			}
+			return nil, err
+		}
+		if len(oid) < len(prefix) || !slices.Equal(oid[:len(prefix)], prefix) {
+			break
+		}
+
+		name, err := sysctlQuery(ctlSysctlName, oid)
+		if err != nil {
+			return nil, err
+		}
+		name = bytes.TrimRight(name, "\x00")
+
+		format, err := sysctlQuery(ctlSysctlOIDFmt, oid)
+		if err != nil {
+			return nil, err
+		}
+		if len(format) < 4 {
+			continue
+		}
+		kind := binary.NativeEndian.Uint32(format)
+		if kind&ctlTypeMask == CtlTypeNode {
+			continue
+		}
+
+		buf := make([]byte, 64)
+		n, err := sysctl(oid, buf, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		p, err := parseParam(string(name), kind, string(bytes.TrimRight(format[4:], "\x00")), buf[:n])
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, p)
+	}
+
+	return params, nil
+}
@@ -0,0 +1,181 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package jail
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// paramRanges holds the inclusive range of values allowed for
+// integer parameters.
+var paramRanges = map[string]struct {
+	min, max int64
+}{
+	"jid":               {0, MaxChildJails},
+	"securelevel":       {-1, 3},
+	"enforce_statfs":    {0, 2},
+	"children.max":      {0, MaxChildJails},
+	"devfs_ruleset":     {0, 65535},
+	"osreldate":         {0, 1<<31 - 1},
+	"linux.oss_version": {0, 1<<31 - 1},
+}
+
+// Validate is used to make sure that the params assigned
+// are indeed correct and usable. This has been exposed for
+// a caller to do validation as well as the package interally.
+//
+// Each value is checked against the parameter's type, strings
+// against their maximum length and integers against their allowed
+// range. Parameters that cannot be given together, such as persist
+// and nopersist or vnet and ip4.addr, are reported as well. Every
+// problem found is returned joined in to a single error.
+func (p Params) Validate() error {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	values := make(map[string]interface{}, len(p))
+	for _, k := range keys {
+		if k == "errmsg" {
+			continue
+		}
+		v, err := validateParam(k, p[k])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		values[k] = v
+	}
+
+	errs = append(errs, validateConflicts(values)...)
+
+	return errors.Join(errs...)
+}
+
+// validateParam checks the value of a single parameter and returns
+// it converted to the parameter's Go type.
+func validateParam(k string, v interface{}) (interface{}, error) {
+	jp, ok := LookupParam(k)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownParam, k)
+	}
+	if jp.ReadOnly() {
+		return nil, fmt.Errorf("%w: %s is read-only", ErrInvalidValue, k)
+	}
+	if v == nil {
+		return nil, fmt.Errorf("%w: %s has no value", ErrInvalidValue, k)
+	}
+
+	cv, err := jp.Convert(v)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cv := cv.(type) {
+	case string:
+		if len(cv) >= jp.ValueLen {
+			return nil, fmt.Errorf("%w: %s is longer than %d bytes", ErrNameTooLong, k, jp.ValueLen-1)
+		}
+		if strings.IndexByte(cv, 0) != -1 {
+			return nil, fmt.Errorf("%w: %s contains a NUL byte", ErrInvalidValue, k)
+		}
+	case int32:
+		if r, ok := paramRanges[k]; ok && (int64(cv) < r.min || int64(cv) > r.max) {
+			return nil, fmt.Errorf("%w: %s must be between %d and %d, got %d", ErrInvalidValue, k, r.min, r.max, cv)
+		}
+	case JailSys:
+		if cv < JailSysDisable || cv > JailSysInherit {
+			return nil, fmt.Errorf("%w: %s must be new, inherit or disable, got %d", ErrInvalidValue, k, cv)
+		}
+	case []netip.Addr:
+		if len(cv) > maxAFIPs {
+			return nil, fmt.Errorf("%w: %s holds more than %d addresses", ErrInvalidValue, k, maxAFIPs)
+		}
+		seen := make(map[netip.Addr]bool, len(cv))
+		for _, a := range cv {
+			if !a.IsValid() || a.IsUnspecified() {
+				return nil, fmt.Errorf("%w: %s holds an invalid address %s", ErrInvalidValue, k, a)
+			}
+			if seen[a] {
+				return nil, fmt.Errorf("%w: %s holds %s more than once", ErrInvalidValue, k, a)
+			}
+			seen[a] = true
+		}
+	}
+
+	return cv, nil
+}
+
+// validateConflicts reports parameters that cannot be given together.
+func validateConflicts(values map[string]interface{}) []error {
+	var errs []error
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		jp, _ := LookupParam(k)
+		if jp.Flags&JailParamNoBool == 0 {
+			continue
+		}
+		i := strings.LastIndexByte(k, '.') + 1
+		if b := k[:i] + k[i+2:]; values[b] != nil {
+			errs = append(errs, fmt.Errorf("%w: %s and %s are mutually exclusive", ErrInvalidValue, b, k))
+		}
+	}
+
+	if values["vnet"] == JailSysNew {
+		for _, k := range []string{"ip4.addr", "ip6.addr"} {
+			if addrs, ok := values[k].([]netip.Addr); ok && len(addrs) > 0 {
+				errs = append(errs, fmt.Errorf("%w: vnet and %s are mutually exclusive", ErrInvalidValue, k))
+			}
+		}
+	}
+
+	for _, af := range []string{"ip4", "ip6"} {
+		sys, ok := values[af].(JailSys)
+		if !ok || sys == JailSysNew {
+			continue
+		}
+		if addrs, ok := values[af+".addr"].([]netip.Addr); ok && len(addrs) > 0 {
+			errs = append(errs, fmt.Errorf("%w: %s.addr requires %s to be new", ErrInvalidValue, af, af))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,127 @@
+package jail
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestParams_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  Params
+		wantErr []error
+	}{
+		{
+			name: "valid",
+			params: Params{
+				"name":           "web",
+				"path":           "/jails/web",
+				"persist":        true,
+				"securelevel":    -1,
+				"enforce_statfs": 2,
+				"ip4":            JailSysNew,
+				"ip4.addr":       []netip.Addr{netip.MustParseAddr("192.0.2.1")},
+				"allow.nomount":  true,
+				"errmsg":         "",
+			},
+		},
+		{
+			name:    "unknown",
+			params:  Params{"allow.raw_socket": true},
+			wantErr: []error{ErrUnknownParam},
+		},
+		{
+			name:    "read only",
+			params:  Params{"dying": true},
+			wantErr: []error{ErrInvalidValue},
+		},
+		{
+			name:    "nil value",
+			params:  Params{"path": nil},
+			wantErr: []error{ErrInvalidValue},
+		},
+		{
+			name:    "wrong type",
+			params:  Params{"securelevel": "1"},
+			wantErr: []error{ErrInvalidValue},
+		},
+		{
+			name:    "string too long",
+			params:  Params{"host.hostname": strings.Repeat("a", 256)},
+			wantErr: []error{ErrNameTooLong},
+		},
+		{
+			name:    "securelevel range",
+			params:  Params{"securelevel": 4},
+			wantErr: []error{ErrInvalidValue},
+		},
+		{
+			name:    "enforce_statfs range",
+			params:  Params{"enforce_statfs": -1},
+			wantErr: []error{ErrInvalidValue},
+		},
+		{
+			name:    "jailsys range",
+			params:  Params{"vnet": 3},
+			wantErr: []error{ErrInvalidValue},
+		},
+		{
+			name:    "persist and nopersist",
+			params:  Params{"persist": true, "nopersist": true},
+			wantErr: []error{ErrInvalidValue},
+		},
+		{
+			name: "vnet and ip4.addr",
+			params: Params{
+				"vnet":     JailSysNew,
+				"ip4.addr": netip.MustParseAddr("192.0.2.1"),
+			},
+			wantErr: []error{ErrInvalidValue},
+		},
+		{
+			name: "ip6 disabled with addresses",
+			params: Params{
+				"ip6":      JailSysDisable,
+				"ip6.addr": netip.MustParseAddr("2001:db8::1"),
+			},
+			wantErr: []error{ErrInvalidValue},
+		},
+		{
+			name: "duplicate address",
+			params: Params{
+				"ip4.addr": []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("192.0.2.1")},
+			},
+			wantErr: []error{ErrInvalidValue},
+		},
+		{
+			name: "every problem",
+			params: Params{
+				"foo":         1,
+				"securelevel": 9,
+				"path":        strings.Repeat("a", 1024),
+			},
+			wantErr: []error{ErrUnknownParam, ErrInvalidValue, ErrNameTooLong},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.params.Validate()
+			if (err != nil) != (len(tt.wantErr) > 0) {
+				t.Fatalf("Params.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !errors.Is(err, want) {
+					t.Errorf("Params.Validate() error = %v, want %v", err, want)
+				}
+			}
+			if err == nil {
+				return
+			}
+			if n := len(err.(interface{ Unwrap() []error }).Unwrap()); n != len(tt.wantErr) {
+				t.Errorf("Params.Validate() returned %d errors, want %d", n, len(tt.wantErr))
+			}
+		})
+	}
+}
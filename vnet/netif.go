@@ -0,0 +1,165 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+package vnet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/netip"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Netif performs the network interface operations used to provision
+// the network of a jail. Operations taking a JID are made in the
+// jail's network stack, or the host's if it is 0.
+type Netif interface {
+	// CreateEpair creates an epair(4) returning the names of its
+	// two ends.
+	CreateEpair(ctx context.Context) (a, b string, err error)
+
+	// Destroy destroys the interface. Destroying either end of an
+	// epair destroys both.
+	Destroy(ctx context.Context, iface string) error
+
+	// AddMember adds the interface to the bridge.
+	AddMember(ctx context.Context, bridge, iface string) error
+
+	// MoveToJail moves the interface in to the jail's network stack.
+	MoveToJail(ctx context.Context, iface string, jid int32) error
+
+	// Rename renames the interface.
+	Rename(ctx context.Context, jid int32, iface, name string) error
+
+	// AddAddr assigns the address to the interface.
+	AddAddr(ctx context.Context, jid int32, iface string, addr netip.Prefix) error
+
+	// Up brings the interface up.
+	Up(ctx context.Context, jid int32, iface string) error
+
+	// AddDefaultRoute adds the default route through the gateway,
+	// for the gateway's address family.
+	AddDefaultRoute(ctx context.Context, jid int32, gw netip.Addr) error
+}
+
+// ExecNetif is the Netif used by default. It runs ifconfig(8) and
+// route(8), using their -j flag for operations made in a jail.
+type ExecNetif struct{}
+
+// CreateEpair creates an epair.
+func (ExecNetif) CreateEpair(ctx context.Context) (string, string, error) {
+	out, err := run(ctx, "/sbin/ifconfig", "epair", "create")
+	if err != nil {
+		return "", "", err
+	}
+
+	a := strings.TrimSpace(out)
+	if !strings.HasSuffix(a, "a") {
+		return "", "", fmt.Errorf("ifconfig epair create: unexpected interface %q", a)
+	}
+
+	return a, strings.TrimSuffix(a, "a") + "b", nil
+}
+
+// Destroy destroys the interface.
+func (ExecNetif) Destroy(ctx context.Context, iface string) error {
+	_, err := run(ctx, "/sbin/ifconfig", iface, "destroy")
+	return err
+}
+
+// AddMember adds the interface to the bridge.
+func (ExecNetif) AddMember(ctx context.Context, bridge, iface string) error {
+	_, err := run(ctx, "/sbin/ifconfig", bridge, "addm", iface)
+	return err
+}
+
+// MoveToJail moves the interface in to the jail.
+func (ExecNetif) MoveToJail(ctx context.Context, iface string, jid int32) error {
+	_, err := run(ctx, "/sbin/ifconfig", iface, "vnet", strconv.Itoa(int(jid)))
+	return err
+}
+
+// Rename renames the interface.
+func (ExecNetif) Rename(ctx context.Context, jid int32, iface, name string) error {
+	_, err := run(ctx, "/sbin/ifconfig", jailArgs(jid, iface, "name", name)...)
+	return err
+}
+
+// AddAddr assigns the address to the interface as an alias.
+func (ExecNetif) AddAddr(ctx context.Context, jid int32, iface string, addr netip.Prefix) error {
+	af := "inet"
+	if addr.Addr().Is6() {
+		af = "inet6"
+	}
+	_, err := run(ctx, "/sbin/ifconfig", jailArgs(jid, iface, af, addr.String(), "alias")...)
+
+	return err
+}
+
+// Up brings the interface up.
+func (ExecNetif) Up(ctx context.Context, jid int32, iface string) error {
+	_, err := run(ctx, "/sbin/ifconfig", jailArgs(jid, iface, "up")...)
+	return err
+}
+
+// AddDefaultRoute adds the default route through the gateway.
+func (ExecNetif) AddDefaultRoute(ctx context.Context, jid int32, gw netip.Addr) error {
+	af := "-inet"
+	if gw.Is6() {
+		af = "-inet6"
+	}
+	_, err := run(ctx, "/sbin/route", jailArgs(jid, "-q", "add", af, "default", gw.String())...)
+
+	return err
+}
+
+// jailArgs prefixes the arguments with -j for commands run in a jail.
+func jailArgs(jid int32, args ...string) []string {
+	if jid == 0 {
+		return args
+	}
+
+	return append([]string{"-j", strconv.Itoa(int(jid))}, args...)
+}
+
+// run runs the command, returning its output or an error holding its
+// standard error.
+func run(ctx context.Context, name string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return string(out), nil
+}
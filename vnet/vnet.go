@@ -0,0 +1,183 @@
+/*-
+ * SPDX-License-Identifier: BSD-2-Clause
+ *
+ * Copyright (c) 2026 Brian J. Downs
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE REGENTS AND CONTRIBUTORS ``AS IS'' AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED.  IN NO EVENT SHALL THE REGENTS OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+// Package vnet provisions the network of VNET jails, those created
+// with vnet set to new, using an epair(4) for each jail. One end of
+// the epair is moved in to the jail, as the vnet.interface parameter
+// of jail.conf(5) does, and given the jail's addresses and default
+// routes, while the other is left on the host and added to a bridge.
+//
+// The interfaces are configured through a Netif so the sequence can
+// be exercised off of a FreeBSD host.
+package vnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+
+	"github.com/briandowns/jail"
+)
+
+// ErrNotVNet is returned for jails without their own network stack.
+var ErrNotVNet = errors.New("jail does not have vnet set to new")
+
+// Config is the network of a jail.
+type Config struct {
+	// Bridge is the bridge(4) the host end of the epair is added
+	// to, if any.
+	Bridge string
+
+	// Name is the name given to the interface in the jail, e.g.
+	// eth0. It keeps its epair name if empty.
+	Name string
+
+	// Addrs are the addresses assigned to the interface in the jail.
+	Addrs []netip.Prefix
+
+	// Gateways are the routers the default routes of the jail go
+	// through, at most one per address family.
+	Gateways []netip.Addr
+}
+
+// validate checks the addresses of the configuration.
+func (c *Config) validate() error {
+	for _, p := range c.Addrs {
+		if !p.IsValid() {
+			return fmt.Errorf("%w: address %s", jail.ErrInvalidValue, p)
+		}
+	}
+
+	var has4, has6 bool
+	for _, gw := range c.Gateways {
+		switch {
+		case !gw.IsValid():
+			return fmt.Errorf("%w: gateway %s", jail.ErrInvalidValue, gw)
+		case gw.Is4() && has4, gw.Is6() && has6:
+			return fmt.Errorf("%w: more than one gateway for %s", jail.ErrInvalidValue, gw)
+		}
+		has4, has6 = has4 || gw.Is4(), has6 || gw.Is6()
+	}
+
+	return nil
+}
+
+// Link is the epair provisioned for a jail.
+type Link struct {
+	JID int32
+
+	// Host is the end of the epair left on the host.
+	Host string
+
+	// Jail is the name of the end moved in to the jail.
+	Jail string
+}
+
+// Network provisions the network of jails.
+type Network struct {
+	Netif Netif
+}
+
+// New creates a Network that runs ifconfig(8) and route(8).
+func New() *Network {
+	return &Network{Netif: ExecNetif{}}
+}
+
+// Setup creates an epair for the jail, adds its host end to the
+// bridge and moves the other end in to the jail, where it is renamed,
+// given the addresses and brought up along with lo0 before the default
+// routes are added. The epair is destroyed if a step fails.
+func (n *Network) Setup(ctx context.Context, jid int32, cfg *Config) (_ *Link, err error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	params := jail.Params{"jid": jid, "vnet": nil}
+	if err := jail.Get(params, 0); err != nil {
+		return nil, err
+	}
+	if params["vnet"] != jail.JailSysNew {
+		return nil, fmt.Errorf("%w: %d", ErrNotVNet, jid)
+	}
+
+	a, b, err := n.Netif.CreateEpair(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			if derr := n.Netif.Destroy(context.WithoutCancel(ctx), a); derr != nil {
+				err = errors.Join(err, derr)
+			}
+		}
+	}()
+
+	l := &Link{JID: jid, Host: a, Jail: b}
+	if cfg.Bridge != "" {
+		if err := n.Netif.AddMember(ctx, cfg.Bridge, a); err != nil {
+			return nil, err
+		}
+	}
+	if err := n.Netif.Up(ctx, 0, a); err != nil {
+		return nil, err
+	}
+
+	if err := n.Netif.MoveToJail(ctx, b, jid); err != nil {
+		return nil, err
+	}
+	if cfg.Name != "" {
+		if err := n.Netif.Rename(ctx, jid, b, cfg.Name); err != nil {
+			return nil, err
+		}
+		l.Jail = cfg.Name
+	}
+	for _, p := range cfg.Addrs {
+		if err := n.Netif.AddAddr(ctx, jid, l.Jail, p); err != nil {
+			return nil, err
+		}
+	}
+	for _, iface := range []string{"lo0", l.Jail} {
+		if err := n.Netif.Up(ctx, jid, iface); err != nil {
+			return nil, err
+		}
+	}
+	for _, gw := range cfg.Gateways {
+		if err := n.Netif.AddDefaultRoute(ctx, jid, gw); err != nil {
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+// Teardown destroys the epair of the link, removing it from the
+// bridge. It can be called once the jail has been removed, when the
+// end in the jail returns to the host.
+func (n *Network) Teardown(ctx context.Context, l *Link) error {
+	return n.Netif.Destroy(ctx, l.Host)
+}
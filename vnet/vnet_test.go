@@ -0,0 +1,214 @@
+package vnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/briandowns/jail"
+	"github.com/briandowns/jail/jailtest"
+)
+
+// recorder is a Netif that records the operations made, failing the
+// one given.
+type recorder struct {
+	calls []string
+	fail  string
+}
+
+func (r *recorder) record(format string, args ...interface{}) error {
+	call := fmt.Sprintf(format, args...)
+	r.calls = append(r.calls, call)
+	if call == r.fail {
+		return errors.New("failed")
+	}
+
+	return nil
+}
+
+func (r *recorder) CreateEpair(context.Context) (string, string, error) {
+	return "epair3a", "epair3b", r.record("create")
+}
+
+func (r *recorder) Destroy(_ context.Context, iface string) error {
+	return r.record("destroy %s", iface)
+}
+
+func (r *recorder) AddMember(_ context.Context, bridge, iface string) error {
+	return r.record("addm %s %s", bridge, iface)
+}
+
+func (r *recorder) MoveToJail(_ context.Context, iface string, jid int32) error {
+	return r.record("vnet %s %d", iface, jid)
+}
+
+func (r *recorder) Rename(_ context.Context, jid int32, iface, name string) error {
+	return r.record("%d: name %s %s", jid, iface, name)
+}
+
+func (r *recorder) AddAddr(_ context.Context, jid int32, iface string, addr netip.Prefix) error {
+	return r.record("%d: addr %s %s", jid, iface, addr)
+}
+
+func (r *recorder) Up(_ context.Context, jid int32, iface string) error {
+	return r.record("%d: up %s", jid, iface)
+}
+
+func (r *recorder) AddDefaultRoute(_ context.Context, jid int32, gw netip.Addr) error {
+	return r.record("%d: route default %s", jid, gw)
+}
+
+func TestNetwork_Setup(t *testing.T) {
+	b := jailtest.New()
+	defer jail.SetBackend(jail.SetBackend(b))
+
+	for _, p := range []jail.Params{
+		{"name": "web", "persist": true, "vnet": jail.JailSysNew},
+		{"name": "db", "persist": true},
+	} {
+		if _, err := b.Set(p, jail.CreateFlag); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &Config{
+		Bridge:   "bridge0",
+		Name:     "eth0",
+		Addrs:    []netip.Prefix{netip.MustParsePrefix("192.0.2.10/24"), netip.MustParsePrefix("2001:db8::10/64")},
+		Gateways: []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("2001:db8::1")},
+	}
+	setup := []string{
+		"create",
+		"addm bridge0 epair3a",
+		"0: up epair3a",
+		"vnet epair3b 1",
+		"1: name epair3b eth0",
+		"1: addr eth0 192.0.2.10/24",
+		"1: addr eth0 2001:db8::10/64",
+		"1: up lo0",
+		"1: up eth0",
+		"1: route default 192.0.2.1",
+		"1: route default 2001:db8::1",
+	}
+
+	tests := []struct {
+		name      string
+		jid       int32
+		cfg       *Config
+		fail      string
+		want      *Link
+		wantCalls []string
+		wantErr   error
+	}{
+		{
+			name:      "bridged",
+			jid:       1,
+			cfg:       cfg,
+			want:      &Link{JID: 1, Host: "epair3a", Jail: "eth0"},
+			wantCalls: setup,
+		},
+		{
+			name: "minimal",
+			jid:  1,
+			cfg:  &Config{},
+			want: &Link{JID: 1, Host: "epair3a", Jail: "epair3b"},
+			wantCalls: []string{
+				"create",
+				"0: up epair3a",
+				"vnet epair3b 1",
+				"1: up lo0",
+				"1: up epair3b",
+			},
+		},
+		{
+			name:      "route fails",
+			jid:       1,
+			cfg:       cfg,
+			fail:      "1: route default 2001:db8::1",
+			wantCalls: append(append([]string(nil), setup...), "destroy epair3a"),
+			wantErr:   errors.New("failed"),
+		},
+		{
+			name:      "create fails",
+			jid:       1,
+			cfg:       cfg,
+			fail:      "create",
+			wantCalls: []string{"create"},
+			wantErr:   errors.New("failed"),
+		},
+		{
+			name:    "not vnet",
+			jid:     2,
+			cfg:     cfg,
+			wantErr: ErrNotVNet,
+		},
+		{
+			name:    "no jail",
+			jid:     3,
+			cfg:     cfg,
+			wantErr: jail.ErrNotFound,
+		},
+		{
+			name:    "two gateways for a family",
+			jid:     1,
+			cfg:     &Config{Gateways: []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("192.0.2.2")}},
+			wantErr: jail.ErrInvalidValue,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{fail: tt.fail}
+			n := &Network{Netif: r}
+
+			got, err := n.Setup(context.Background(), tt.jid, tt.cfg)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("Network.Setup() error = %v", err)
+			case tt.wantErr != nil && err == nil:
+				t.Fatalf("Network.Setup() error = nil, want %v", tt.wantErr)
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr) && err.Error() != tt.wantErr.Error():
+				t.Fatalf("Network.Setup() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Network.Setup() = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(r.calls, tt.wantCalls) {
+				t.Errorf("calls = %q, want %q", r.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestNetwork_Teardown(t *testing.T) {
+	r := &recorder{}
+	n := &Network{Netif: r}
+
+	if err := n.Teardown(context.Background(), &Link{JID: 1, Host: "epair3a", Jail: "eth0"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"destroy epair3a"}; !reflect.DeepEqual(r.calls, want) {
+		t.Errorf("calls = %q, want %q", r.calls, want)
+	}
+}
+
+func Test_jailArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		jid  int32
+		args []string
+		want []string
+	}{
+		{"host", 0, []string{"epair0a", "up"}, []string{"epair0a", "up"}},
+		{"jail", 7, []string{"eth0", "up"}, []string{"-j", "7", "eth0", "up"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jailArgs(tt.jid, tt.args...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("jailArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}